@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	"github.com/ernilsson/gatekeeper/internal/replication"
+	grpcgo "google.golang.org/grpc"
+	"io"
+	"os"
+	"time"
+)
+
+const dbUsage = `usage: gatekeeper db <command> [flags]
+
+commands:
+  inspect    print the metadata of a gaslight file, a single page or the tree of a collection
+  export     write collections of a gaslight file as JSON Lines
+  import     read JSON Lines written by export into an empty gaslight file
+  backup     write a full or incremental backup of a gaslight file, or of the file of a running server
+  restore    create a gaslight file from a full backup and a chain of incremental backups`
+
+func db(args []string) error {
+	if len(args) == 0 {
+		return errors.New(dbUsage)
+	}
+	switch args[0] {
+	case "inspect":
+		return inspect(args[1:])
+	case "export":
+		return export(args[1:])
+	case "import":
+		return load(args[1:])
+	case "backup":
+		return backup(args[1:])
+	case "restore":
+		return restore(args[1:])
+	default:
+		return fmt.Errorf("unknown db command %q\n\n%s", args[0], dbUsage)
+	}
+}
+
+func inspect(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	file := flags.String("file", "gatekeeper.db", "path to the gaslight file")
+	page := flags.Int64("page", -1, "dump the page with this id decoded as a tree node")
+	dot := flags.String("dot", "", "render the tree of the named collection as Graphviz DOT")
+	compress := flags.Bool("compress", false, "decompress pages compressed with snappy")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	g, err := openReadOnly(*file, compression(*compress)...)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	switch {
+	case *dot != "":
+		c, err := g.Collection(*dot)
+		if err != nil {
+			return err
+		}
+		return c.WriteDOT(os.Stdout)
+	case *page >= 0:
+		return g.DumpPage(os.Stdout, uint64(*page))
+	default:
+		return g.DumpMetadata(os.Stdout)
+	}
+}
+
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := flags.String("file", "gatekeeper.db", "path to the gaslight file")
+	collection := flags.String("collection", "", "export only the named collection")
+	out := flags.String("out", "", "path to write the export to, defaults to stdout")
+	compress := flags.Bool("compress", false, "decompress pages compressed with snappy")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	g, err := openReadOnly(*file, compression(*compress)...)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	if *collection != "" {
+		return g.Export(w, *collection)
+	}
+	return g.Export(w)
+}
+
+// load implements the import command, which cannot be named after the command as import is a keyword.
+func load(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := flags.String("file", "gatekeeper.db", "path to the gaslight file, which must be empty if it exists")
+	in := flags.String("in", "", "path to read the export from, defaults to stdin")
+	compress := flags.Bool("compress", false, "compress the pages of the gaslight file with snappy")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	r := os.Stdin
+	if *in != "" {
+		var err error
+		if r, err = os.Open(*in); err != nil {
+			return err
+		}
+		defer r.Close()
+	}
+	g, err := gaslight.Open(*file, compression(*compress)...)
+	if err != nil {
+		return err
+	}
+	if err := g.Import(r); err != nil {
+		_ = g.Close()
+		return err
+	}
+	return g.Close()
+}
+
+// backup implements the backup command. The file is opened read only, which fails while a server has it open, in which
+// case the server is backed up through its replication listener instead.
+func backup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	file := flags.String("file", "gatekeeper.db", "path to the gaslight file, which must not be opened by a server")
+	out := flags.String("out", "", "path to write the backup to")
+	since := flags.String("since", "", "path to a previous backup, which makes the backup incremental")
+	from := flags.String("from", "",
+		"replication address of a running server to back up instead of the file, such as localhost:9090")
+	ca := flags.String("replicate-ca", "", "path to a PEM encoded certificate authority verifying the server")
+	cert := flags.String("replicate-cert", "",
+		"path to a PEM encoded client certificate authenticating to the server, as a follower would")
+	key := flags.String("replicate-key", "", "path to the PEM encoded private key of the client certificate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("backup: -out is required")
+	}
+	var txid uint64
+	if *since != "" {
+		previous, err := os.Open(*since)
+		if err != nil {
+			return err
+		}
+		header, err := gaslight.ReadBackupHeader(previous)
+		_ = previous.Close()
+		if err != nil {
+			return err
+		}
+		txid = header.TxID
+	}
+	take := func(w io.Writer) error {
+		g, err := gaslight.OpenReadOnly(*file)
+		if errors.Is(err, gaslight.ErrLocked) {
+			return fmt.Errorf("%w, back up the server holding it with -from", err)
+		}
+		if err != nil {
+			return err
+		}
+		defer g.Close()
+		_, err = g.Backup(w, txid)
+		return err
+	}
+	if *from != "" {
+		if *ca == "" || *cert == "" || *key == "" {
+			return errors.New("backup: -replicate-ca, -replicate-cert and -replicate-key are required with -from")
+		}
+		creds, err := followerCreds(*cert, *key, *ca)
+		if err != nil {
+			return err
+		}
+		take = func(w io.Writer) error {
+			conn, err := grpcgo.NewClient(*from, grpcgo.WithTransportCredentials(creds))
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return replication.Backup(context.Background(), conn, w, txid)
+		}
+	}
+	w, err := os.OpenFile(*out, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if err = errors.Join(take(w), w.Sync()); err != nil {
+		_ = w.Close()
+		_ = os.Remove(*out)
+		return err
+	}
+	// The header is read back from the backup as it is written by the server when backing up through -from
+	_, err = w.Seek(0, io.SeekStart)
+	var header gaslight.BackupHeader
+	if err == nil {
+		header, err = gaslight.ReadBackupHeader(w)
+	}
+	if err = errors.Join(err, w.Close()); err != nil {
+		return err
+	}
+	fmt.Printf("backed up %d pages up to transaction %d\n", header.Pages, header.TxID)
+	return nil
+}
+
+func restore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	out := flags.String("out", "gatekeeper.db", "path to the gaslight file to create")
+	until := flags.String("until", "", "restore the last backup taken at or before this RFC 3339 time, defaults to now")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: gatekeeper db restore [flags] <full backup> [incremental backup...]")
+	}
+	t := time.Now()
+	if *until != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, *until); err != nil {
+			return err
+		}
+	}
+	backups := make([]io.Reader, flags.NArg())
+	for i, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		backups[i] = f
+	}
+	header, err := gaslight.Restore(*out, t, backups...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored transaction %d backed up at %s\n", header.TxID, header.Time.Format(time.RFC3339))
+	return nil
+}
+
+// openReadOnly opens the gaslight file at the provided path without writing to it or its write-ahead log, which keeps
+// the commands reading a file from changing it. It fails with gaslight.ErrLocked while a server has the file open.
+func openReadOnly(path string, opts ...gaslight.Option) (*gaslight.DB, error) {
+	g, err := gaslight.OpenReadOnly(path, opts...)
+	if errors.Is(err, gaslight.ErrLocked) {
+		return nil, fmt.Errorf("%w, such as a running server", err)
+	}
+	return g, err
+}
+
+// compression returns the options which make a gaslight file compress its pages with snappy if enabled.
+func compression(enabled bool) []gaslight.Option {
+	if !enabled {
+		return nil
+	}
+	return []gaslight.Option{gaslight.WithCompressor(gaslight.Snappy())}
+}
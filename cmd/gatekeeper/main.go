@@ -1,9 +1,164 @@
 package main
 
-import "github.com/ernilsson/gatekeeper/pkg/grpc"
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	"github.com/ernilsson/gatekeeper/internal/store"
+	"github.com/ernilsson/gatekeeper/pkg/grpc"
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"os"
+)
 
 func main() {
-	if err := grpc.Start(":8080"); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "db" {
+		if err := db(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		if err := schemas(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	port := flag.String("port", "8080", "port to serve the gRPC API on")
+	kind := flag.String("store", store.KindGaslight, "storage backend, either gaslight or memory")
+	file := flag.String("db", "gatekeeper.db", "path to the gaslight file used by the gaslight store")
+	compress := flag.Bool("compress", false, "compress the pages of the gaslight file with snappy")
+	cert := flag.String("tls-cert", "", "path to a PEM encoded certificate, serves the API over TLS along with -tls-key")
+	key := flag.String("tls-key", "", "path to the PEM encoded private key of the certificate")
+	algorithm := flag.String("combining-algorithm", string(gatekeeper.DenyOverrides),
+		"how policies are combined, either deny-overrides, permit-overrides or first-applicable")
+	listen := flag.String("replicate-listen", "",
+		"address to stream the commits of the gaslight file to followers on, such as :9090, which requires -tls-cert, "+
+			"-tls-key and -replicate-client-ca as followers are authenticated by their client certificates")
+	clients := flag.String("replicate-client-ca", "",
+		"path to a PEM encoded certificate authority issuing the client certificates of followers")
+	from := flag.String("replicate-from", "",
+		"address of a primary to follow, which makes the gaslight file a read only replica of it and requires "+
+			"-replicate-ca, -replicate-cert and -replicate-key")
+	ca := flag.String("replicate-ca", "", "path to a PEM encoded certificate authority verifying the primary")
+	followerCert := flag.String("replicate-cert", "",
+		"path to a PEM encoded client certificate authenticating the follower to the primary")
+	followerKey := flag.String("replicate-key", "", "path to the PEM encoded private key of the client certificate")
+	plaintext := flag.Bool("insecure", false, "serve the API in plaintext when no certificate is provided")
+	flag.Parse()
+	var opts []grpcgo.ServerOption
+	switch {
+	case *cert != "" || *key != "":
+		creds, err := credentials.NewServerTLSFromFile(*cert, *key)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		opts = append(opts, grpcgo.Creds(creds))
+	case *plaintext:
+		fmt.Fprintln(os.Stderr, "WARNING: serving the API in plaintext, anyone on the network may read and forge its "+
+			"requests and decisions")
+	default:
+		fmt.Fprintln(os.Stderr, "-tls-cert and -tls-key are required to serve the API, or -insecure to serve it in "+
+			"plaintext")
+		os.Exit(2)
+	}
+	// Followers receive every page of the gaslight file, which is why replication always uses mutual TLS
+	var primary, follower credentials.TransportCredentials
+	if *listen != "" {
+		if *cert == "" || *key == "" || *clients == "" {
+			fmt.Fprintln(os.Stderr, "-tls-cert, -tls-key and -replicate-client-ca are required to stream commits to "+
+				"followers")
+			os.Exit(2)
+		}
+		var err error
+		if primary, err = primaryCreds(*cert, *key, *clients); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *from != "" {
+		if *ca == "" || *followerCert == "" || *followerKey == "" {
+			fmt.Fprintln(os.Stderr, "-replicate-ca, -replicate-cert and -replicate-key are required to follow a "+
+				"primary")
+			os.Exit(2)
+		}
+		var err error
+		if follower, err = followerCreds(*followerCert, *followerKey, *ca); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, db, err := open(ctx, *kind, *file, *compress, *listen, primary, *from, follower)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer s.Close()
+	r, err := newReloader(ctx, s)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if db != nil {
+		go r.watch(ctx, db)
+	}
+	policies, err := gatekeeper.NewPolicies(s, r.checker, gatekeeper.CombiningAlgorithm(*algorithm))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := grpc.Start(*port, s, r.checker, policies, opts...); err != nil {
 		panic(err)
 	}
 }
+
+// open opens the store of the provided kind, along with the DB of a gaslight store, which is nil for other stores. A
+// gaslight store may additionally stream its commits to followers connecting to the listen address, over the primary
+// credentials, and follow the primary at the from address over the follower credentials, in which case it is read
+// only.
+func open(ctx context.Context, kind, file string, compress bool, listen string,
+	primary credentials.TransportCredentials, from string, follower credentials.TransportCredentials) (
+	gatekeeper.Store, *gaslight.DB, error) {
+	if kind != store.KindGaslight {
+		if listen != "" || from != "" {
+			return nil, nil, fmt.Errorf("replication requires the %s store", store.KindGaslight)
+		}
+		s, err := store.Open(kind, file)
+		return s, nil, err
+	}
+	dbopts := compression(compress)
+	if from != "" {
+		dbopts = append(dbopts, gaslight.ReadOnly())
+	}
+	db, err := gaslight.Open(file, dbopts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if listen != "" {
+		if err := replicate(db, listen, primary); err != nil {
+			_ = db.Close()
+			return nil, nil, err
+		}
+	}
+	if from == "" {
+		s, err := store.NewGaslight(db)
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, err
+		}
+		return s, db, nil
+	}
+	s, err := follow(ctx, db, from, follower)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	return s, db, nil
+}
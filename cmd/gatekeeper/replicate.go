@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	replicationv1 "github.com/ernilsson/gatekeeper/internal/pb/replication/v1"
+	"github.com/ernilsson/gatekeeper/internal/replication"
+	"github.com/ernilsson/gatekeeper/internal/store"
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"net"
+	"os"
+	"time"
+)
+
+// replicate serves the commits of the DB to followers connecting to the provided address in the background, over the
+// provided credentials which are expected to authenticate followers as returned by primaryCreds. The process exits if
+// the listener fails.
+func replicate(db *gaslight.DB, addr string, creds credentials.TransportCredentials) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := grpcgo.NewServer(grpcgo.Creds(creds))
+	replicationv1.RegisterReplicationServer(srv, replication.NewPrimary(db))
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			fmt.Fprintln(os.Stderr, "replication:", err)
+			os.Exit(1)
+		}
+	}()
+	return nil
+}
+
+// follow keeps the DB, which must be opened read only, up to date with the primary at the provided address in the
+// background and returns a store on top of it. The collections of the store are created by the primary, which means
+// that a new follower waits for them to be replicated before the store is returned.
+func follow(ctx context.Context, db *gaslight.DB, addr string, creds credentials.TransportCredentials) (*store.Gaslight, error) {
+	conn, err := grpcgo.NewClient(addr, grpcgo.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_ = replication.NewFollower(db, conn).Run(ctx)
+	}()
+	for waited := false; ; waited = true {
+		s, err := store.NewGaslight(db)
+		if !errors.Is(err, gaslight.ErrReadOnly) {
+			return s, err
+		}
+		if !waited {
+			fmt.Fprintf(os.Stderr, "waiting for the collections of the store to be replicated from %s\n", addr)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// primaryCreds returns the credentials of the replication listener of a primary, which serves the PEM encoded
+// certificate and key and requires every follower to present a client certificate issued by the certificate authority
+// at the clients path. Followers receive every page of the gaslight file, which is why they are always authenticated.
+func primaryCreds(cert, key, clients string) (credentials.TransportCredentials, error) {
+	certificate, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := certPool(clients)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// followerCreds returns the credentials a follower connects to its primary with, verifying the primary through the
+// certificate authority at the ca path and presenting the PEM encoded certificate and key as its client certificate.
+func followerCreds(cert, key, ca string) (credentials.TransportCredentials, error) {
+	certificate, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := certPool(ca)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// certPool reads the PEM encoded certificates of a certificate authority from the file at the provided path.
+func certPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("%s: no PEM encoded certificates", path)
+	}
+	return pool, nil
+}
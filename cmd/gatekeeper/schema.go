@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	"github.com/ernilsson/gatekeeper/internal/schema"
+	"github.com/ernilsson/gatekeeper/internal/store"
+	"os"
+)
+
+const schemaUsage = `usage: gatekeeper schema <command> [flags]
+
+commands:
+  apply    compile a schema and store it as the latest version, refusing unsafe changes unless forced, which the
+           server of the file loads when it is started and its followers as soon as it is replicated to them
+  show     print a stored version of the schema`
+
+func schemas(args []string) error {
+	if len(args) == 0 {
+		return errors.New(schemaUsage)
+	}
+	switch args[0] {
+	case "apply":
+		return apply(args[1:])
+	case "show":
+		return show(args[1:])
+	default:
+		return fmt.Errorf("unknown schema command %q\n\n%s", args[0], schemaUsage)
+	}
+}
+
+func apply(args []string) error {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	file := flags.String("db", "gatekeeper.db", "path to the gaslight file")
+	compress := flags.Bool("compress", false, "compress the pages of the gaslight file with snappy")
+	force := flags.Bool("force", false, "apply the schema in spite of changes which are unsafe for the stored tuples")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: gatekeeper schema apply [flags] <schema file>")
+	}
+	src, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	s, err := store.Open(store.KindGaslight, *file, compression(*compress)...)
+	if errors.Is(err, gaslight.ErrLocked) {
+		return fmt.Errorf("%w, stop the server holding it to apply the schema", err)
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	v, changes, err := schema.Apply(context.Background(), s, string(src), *force)
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if errors.Is(err, schema.ErrInvalidSchema) {
+		return fmt.Errorf("%s: %w", flags.Arg(0), err)
+	}
+	if errors.Is(err, schema.ErrUnsafeChange) {
+		return fmt.Errorf("%w, apply with -force to apply them anyway", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("applied schema version %d\n", v.Version)
+	return nil
+}
+
+func show(args []string) error {
+	flags := flag.NewFlagSet("show", flag.ContinueOnError)
+	file := flags.String("db", "gatekeeper.db", "path to the gaslight file")
+	version := flags.Uint("version", 0, "the version to print, defaults to the latest version")
+	compress := flags.Bool("compress", false, "decompress pages compressed with snappy")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*file); err != nil {
+		return err
+	}
+	s, err := store.Open(store.KindGaslight, *file, compression(*compress)...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	v, err := s.Schema(context.Background(), *version)
+	if err != nil {
+		return err
+	}
+	fmt.Print(v.Source)
+	return nil
+}
+
+// reloader keeps a checker following the inheritances of the permissions of the latest version of the stored schema
+// and the conditions of its relations. Without a stored schema nothing defines relations implying other relations,
+// which leaves the checker to follow tuples and usersets only.
+type reloader struct {
+	store   gatekeeper.Store
+	checker *gatekeeper.Checker
+	// version is the version of the schema the checker follows, or zero if there is none.
+	version uint
+}
+
+// newReloader creates a checker on top of the store which follows the latest version of the stored schema.
+func newReloader(ctx context.Context, s gatekeeper.Store) (*reloader, error) {
+	checker, err := gatekeeper.NewChecker(s, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &reloader{store: s, checker: checker}
+	return r, r.reload(ctx)
+}
+
+// reload reloads the checker if the latest version of the stored schema is not the one it follows. The conditions are
+// taken from the schema rather than from the stored relationships, as schema.Apply only persists the relationships of
+// a version once the version itself is stored.
+func (r *reloader) reload(ctx context.Context) error {
+	v, err := r.store.Schema(ctx, 0)
+	if errors.Is(err, gatekeeper.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if v.Version == r.version {
+		return nil
+	}
+	compiled, err := schema.Compile(v.Source)
+	if err != nil {
+		return fmt.Errorf("schema version %d: %w", v.Version, err)
+	}
+	relationships := compiled.Relationships()
+	conditioned := make([]*gatekeeper.Relationship, 0, len(relationships))
+	for i := range relationships {
+		conditioned = append(conditioned, &relationships[i])
+	}
+	if err := r.checker.Reload(compiled.Inheritances(), conditioned...); err != nil {
+		return fmt.Errorf("schema version %d: %w", v.Version, err)
+	}
+	r.version = v.Version
+	return nil
+}
+
+// watch reloads the checker whenever transactions are committed to the DB, whether by the server itself or replicated
+// from its primary, until the context is cancelled. A version which fails to load is reported and leaves the checker
+// following the version it followed before.
+func (r *reloader) watch(ctx context.Context, db *gaslight.DB) {
+	for {
+		// The schema is reloaded right after subscribing, which accounts for the commits made before the subscription
+		// as well as for those missed by a subscription which fell behind
+		subscription := db.Subscribe(16)
+		for open := true; open; {
+			if err := r.reload(ctx); err != nil && ctx.Err() == nil {
+				fmt.Fprintln(os.Stderr, "reloading the schema:", err)
+			}
+			select {
+			case <-ctx.Done():
+				subscription.Close()
+				return
+			case _, open = <-subscription.C:
+			}
+			// Commits received while reloading are covered by a single reload
+			for drained := false; open && !drained; {
+				select {
+				case _, open = <-subscription.C:
+				default:
+					drained = true
+				}
+			}
+		}
+	}
+}
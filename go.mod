@@ -3,6 +3,7 @@ module github.com/ernilsson/gatekeeper
 go 1.22.4
 
 require (
+	github.com/golang/snappy v0.0.4
 	google.golang.org/grpc v1.65.0
 	google.golang.org/protobuf v1.34.1
 )
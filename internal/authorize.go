@@ -0,0 +1,80 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+	"strings"
+)
+
+// PrincipalNamespace is the namespace principals are subjects of tuples in, the principal with the ID alice is the
+// subject principal:alice.
+const PrincipalNamespace = "principal"
+
+// Decision is the outcome of Authorize.
+type Decision struct {
+	Granted bool
+	// Challenge is the relationship with a condition the principal would be granted the operation through if its
+	// condition held, which is only set when the operation is not granted.
+	Challenge *Relationship
+}
+
+// Authorize decides whether the principal with the provided ID may perform an operation, which is the qualified name
+// of a relationship such as "documents:viewer", on the resource, which is the ID of an object within the namespace of
+// the operation. The subject of the principal, in the PrincipalNamespace, is first decided on by the policies, which
+// means that the operation is only denied without a challenge when a policy denies the principal the operation.
+// Without a policy applying to the principal it may perform the operation if it holds the relation on the object, along
+// with the conditions of the relationships it is held through holding for the attributes. If it would hold the relation
+// were it not for the condition of a single relationship, such as one requiring a step-up authentication of the
+// request, then the operation is denied with that relationship as the challenge. ErrNoExplicitPolicy is returned if
+// nothing permits the operation and ErrNoOperationFound if there is no such relationship. The errors of evaluating
+// conditions are returned as is.
+func Authorize(ctx context.Context, store Store, policies *Policies, principalID, operation, resource string,
+	attrs condition.Attributes) (Decision, error) {
+	namespace, name, ok := strings.Cut(operation, ":")
+	if !ok || namespace == "" || name == "" {
+		return Decision{}, fmt.Errorf("%w: %q is not of the form namespace:relation", ErrNoOperationFound, operation)
+	}
+	if resource == "" {
+		return Decision{}, fmt.Errorf("%w: authorizing requires a resource", ErrMalformedTuple)
+	}
+	principal, err := store.Principal(ctx, principalID)
+	if err != nil {
+		return Decision{}, err
+	}
+	relationships, err := store.Relationships(ctx, namespace)
+	if err != nil {
+		return Decision{}, err
+	}
+	var found bool
+	for _, r := range relationships {
+		if r.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return Decision{}, fmt.Errorf("%w: %s", ErrNoOperationFound, operation)
+	}
+	subject := Subject{Namespace: PrincipalNamespace, Object: principal.ID}
+	permitted, err := policies.Decide(ctx, subject, operation, resource, attrs)
+	if !errors.Is(err, ErrNoExplicitPolicy) {
+		return Decision{Granted: permitted}, err
+	}
+	granted, err := policies.checker.Check(ctx, namespace, resource, name, subject, attrs)
+	if err != nil {
+		return Decision{}, err
+	}
+	if granted {
+		return Decision{Granted: true}, nil
+	}
+	challenge, err := policies.checker.Challenge(ctx, namespace, resource, name, subject, attrs)
+	if err != nil {
+		return Decision{}, err
+	}
+	if challenge == nil {
+		return Decision{}, fmt.Errorf("%w: %s for %s on %s", ErrNoExplicitPolicy, subject, operation, resource)
+	}
+	return Decision{Challenge: challenge}, nil
+}
@@ -0,0 +1,316 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultMaxDepth is the number of tuples and inheritance edges a check follows from the checked relation before it
+	// gives up with ErrMaxDepth.
+	DefaultMaxDepth = 25
+	// DefaultConcurrency is the number of goroutines a checker runs lookups in at most, in addition to the goroutines of
+	// its callers.
+	DefaultConcurrency = 32
+)
+
+var (
+	ErrMaxDepth           = errors.New("maximum check depth exceeded")
+	ErrInvalidInheritance = errors.New("invalid inheritance")
+	// ErrConditionalRelation is returned by lookups and expansions which depend on a relation with a condition, as they
+	// are made without the attributes of a single resource and request to evaluate it against.
+	ErrConditionalRelation = errors.New("relation has a condition")
+)
+
+// CheckOption configures optional behaviour of a Checker.
+type CheckOption func(*Checker)
+
+// WithMaxDepth overrides DefaultMaxDepth.
+func WithMaxDepth(depth int) CheckOption {
+	return func(c *Checker) {
+		c.maxDepth = depth
+	}
+}
+
+// WithConcurrency overrides DefaultConcurrency. A concurrency of zero makes the checker run every lookup in the
+// goroutine of its caller.
+func WithConcurrency(n int) CheckOption {
+	return func(c *Checker) {
+		c.slots = make(chan struct{}, n)
+	}
+}
+
+// WithConditions makes the relationships with a condition only hold for the attributes of a check if their condition
+// holds for them.
+func WithConditions(relationships ...*Relationship) CheckOption {
+	return func(c *Checker) {
+		for _, r := range relationships {
+			if r.Condition != "" {
+				c.sources[r.QualifiedName()] = r.Condition
+			}
+		}
+	}
+}
+
+// Checker decides whether a subject holds a relation on an object by following the tuples of the object, the usersets
+// named by those tuples and the relations inheriting the relation. The lookups at each step are made concurrently and
+// the remaining lookups are cancelled as soon as one of them finds the subject.
+type Checker struct {
+	tuples TupleStore
+	// rules are replaced as a whole by Reload, every check, expansion and lookup follows the rules it started with.
+	rules atomic.Pointer[rules]
+	// sources maps the qualified name of a relation to the source of its condition, which is compiled into the rules
+	// by NewChecker.
+	sources  map[string]string
+	maxDepth int
+	// slots limits the number of goroutines running lookups, a lookup is run in the goroutine of its caller when no
+	// slot is free, which guarantees progress without holding a slot while waiting for another.
+	slots chan struct{}
+}
+
+// rules are the inheritances and conditions a checker follows.
+type rules struct {
+	// inherited maps the qualified name of a relation to the names of the relations within the same namespace that
+	// imply it, such as documents:viewer to editor if every editor of a document is a viewer of it as well.
+	inherited map[string][]string
+	// implied is the reverse of inherited, mapping the qualified name of a relation to the names of the relations it
+	// implies, such as documents:editor to viewer.
+	implied    map[string][]string
+	conditions map[string]*condition.Program
+}
+
+// NewChecker creates a checker on top of the provided tuples. Each inheritance makes the holders of its parent relation
+// holders of its child relation as well, which requires both relations to be of the same namespace.
+func NewChecker(tuples TupleStore, inheritances []Inheritance, opts ...CheckOption) (*Checker, error) {
+	c := &Checker{
+		tuples:   tuples,
+		sources:  make(map[string]string),
+		maxDepth: DefaultMaxDepth,
+		slots:    make(chan struct{}, DefaultConcurrency),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	r, err := newRules(inheritances, c.sources)
+	if err != nil {
+		return nil, err
+	}
+	c.rules.Store(r)
+	return c, nil
+}
+
+// Reload replaces the inheritances of the checker, along with the conditions of the relationships as set by
+// WithConditions, such as when a new version of the schema is applied. Checks, expansions and lookups already being
+// made keep following the previous inheritances and conditions. The checker is left as it is if an error is returned.
+func (c *Checker) Reload(inheritances []Inheritance, relationships ...*Relationship) error {
+	sources := make(map[string]string)
+	for _, r := range relationships {
+		if r.Condition != "" {
+			sources[r.QualifiedName()] = r.Condition
+		}
+	}
+	r, err := newRules(inheritances, sources)
+	if err != nil {
+		return err
+	}
+	c.rules.Store(r)
+	return nil
+}
+
+func newRules(inheritances []Inheritance, sources map[string]string) (*rules, error) {
+	r := &rules{
+		inherited:  make(map[string][]string),
+		implied:    make(map[string][]string),
+		conditions: make(map[string]*condition.Program),
+	}
+	for _, i := range inheritances {
+		if i.Parent.Namespace.Name != i.Child.Namespace.Name {
+			return nil, fmt.Errorf("%w: %s cannot imply %s of another namespace", ErrInvalidInheritance,
+				i.Parent.QualifiedName(), i.Child.QualifiedName())
+		}
+		child := i.Child.QualifiedName()
+		r.inherited[child] = append(r.inherited[child], i.Parent.Name)
+		parent := i.Parent.QualifiedName()
+		r.implied[parent] = append(r.implied[parent], i.Child.Name)
+	}
+	for relation, src := range sources {
+		program, err := condition.Compile(src)
+		if err != nil {
+			return nil, fmt.Errorf("relationship %s: %w", relation, err)
+		}
+		r.conditions[relation] = program
+	}
+	return r, nil
+}
+
+// Check returns true if the subject holds the relation on the object of the namespace, either through a tuple naming
+// the subject, through a userset the subject is a member of or through a relation implying the relation. Cycles of
+// usersets or inheritance are not followed more than once. ErrMaxDepth is returned if the subject could not be found
+// without following more than the maximum depth of tuples and inheritance edges. A relation with a condition is only
+// held, directly or through the usersets and relations it is followed through, if the condition holds for the
+// attributes. The errors of evaluating a condition are returned as is unless the subject is found another way.
+func (c *Checker) Check(ctx context.Context, namespace, object, relation string, subject Subject,
+	attrs condition.Attributes) (bool, error) {
+	userset := Subject{Namespace: namespace, Object: object, Relation: relation}
+	return c.check(ctx, c.rules.Load(), userset, subject, attrs, nil)
+}
+
+// Challenge returns the relationship with a condition which the subject would hold the relation on the object through
+// if its condition held for the attributes, such as a relationship conditioned on a step-up authentication of the
+// request. The relationships whose condition does not hold are tried one at a time in order of their qualified names,
+// and nil is returned if the subject would not hold the relation through any single one of them. Conditions which
+// cannot be evaluated against the attributes are not challenged.
+func (c *Checker) Challenge(ctx context.Context, namespace, object, relation string, subject Subject,
+	attrs condition.Attributes) (*Relationship, error) {
+	r := c.rules.Load()
+	userset := Subject{Namespace: namespace, Object: object, Relation: relation}
+	names := make([]string, 0, len(r.conditions))
+	for name := range r.conditions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if holds, err := r.conditions[name].Eval(attrs); err != nil || holds {
+			continue
+		}
+		found, err := c.check(ctx, r.waive(name), userset, subject, attrs, nil)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			ns, rel, _ := strings.Cut(name, ":")
+			return &Relationship{Namespace: Namespace{Name: ns}, Name: rel}, nil
+		}
+	}
+	return nil, nil
+}
+
+// path is the chain of usersets leading from the checked relation to the userset being checked, used to detect cycles
+// and to limit the depth of a check.
+type path struct {
+	userset Subject
+	parent  *path
+	depth   int
+}
+
+func (p *path) contains(userset Subject) bool {
+	for ; p != nil; p = p.parent {
+		if p.userset == userset {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns true if the subject is a member of the userset, which is a relation on an object.
+func (c *Checker) check(ctx context.Context, r *rules, userset, subject Subject, attrs condition.Attributes,
+	parent *path) (bool, error) {
+	if parent.contains(userset) {
+		return false, nil
+	}
+	current := &path{userset: userset, parent: parent}
+	if parent != nil {
+		current.depth = parent.depth + 1
+	}
+	if current.depth > c.maxDepth {
+		return false, fmt.Errorf("%w: %s at depth %d", ErrMaxDepth, userset, current.depth)
+	}
+	if program, ok := r.conditions[userset.Namespace+":"+userset.Relation]; ok {
+		holds, err := program.Eval(attrs)
+		if err != nil || !holds {
+			return false, err
+		}
+	}
+	tuples, err := c.tuples.ObjectTuples(ctx, userset.Namespace, userset.Object, userset.Relation)
+	if err != nil {
+		return false, err
+	}
+	var next []Subject
+	for _, t := range tuples {
+		if t.Subject == subject {
+			return true, nil
+		}
+		if t.Subject.Userset() {
+			next = append(next, t.Subject)
+		}
+	}
+	for _, relation := range r.inherited[userset.Namespace+":"+userset.Relation] {
+		next = append(next, Subject{Namespace: userset.Namespace, Object: userset.Object, Relation: relation})
+	}
+	return c.any(ctx, r, next, subject, attrs, current)
+}
+
+// any checks every userset concurrently and returns true as soon as the subject is found in one of them, cancelling the
+// remaining checks. An error is only returned if the subject is not found in any of the usersets.
+func (c *Checker) any(ctx context.Context, r *rules, usersets []Subject, subject Subject, attrs condition.Attributes,
+	parent *path) (bool, error) {
+	if len(usersets) == 0 {
+		return false, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		found bool
+		err   error
+	}
+	results := make(chan result, len(usersets))
+	var wg sync.WaitGroup
+	for _, userset := range usersets {
+		select {
+		case c.slots <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-c.slots }()
+				found, err := c.check(ctx, r, userset, subject, attrs, parent)
+				results <- result{found: found, err: err}
+			}()
+		default:
+			found, err := c.check(ctx, r, userset, subject, attrs, parent)
+			if found {
+				return true, nil
+			}
+			results <- result{err: err}
+		}
+	}
+	// The results channel has room for every result, which lets the goroutines finish once the lookups are cancelled
+	// even if the results are no longer received
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	var errs []error
+	for res := range results {
+		if res.found {
+			return true, nil
+		}
+		if res.err != nil {
+			errs = append(errs, res.err)
+		}
+	}
+	return false, errors.Join(errs...)
+}
+
+// waive returns a copy of the rules without the condition of the relation with the provided qualified name.
+func (r *rules) waive(relation string) *rules {
+	waived := *r
+	waived.conditions = maps.Clone(r.conditions)
+	delete(waived.conditions, relation)
+	return &waived
+}
+
+// conditional returns an error wrapping ErrConditionalRelation if the relation of the userset has a condition.
+func (r *rules) conditional(userset Subject) error {
+	relation := userset.Namespace + ":" + userset.Relation
+	if _, ok := r.conditions[relation]; ok {
+		return fmt.Errorf("%w: %s", ErrConditionalRelation, relation)
+	}
+	return nil
+}
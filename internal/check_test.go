@@ -0,0 +1,361 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+	"reflect"
+	"testing"
+)
+
+// tuples is a read-only TupleStore of the tuples written as strings, not supporting RelationTuples.
+type tuples []string
+
+func (ts tuples) ObjectTuples(ctx context.Context, namespace, object, relation string) ([]RelationTuple, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var found []RelationTuple
+	for _, s := range ts {
+		t, err := ParseTuple(s)
+		if err != nil {
+			return nil, err
+		}
+		if t.Namespace == namespace && t.Object == object && t.Relation == relation {
+			found = append(found, t)
+		}
+	}
+	return found, nil
+}
+
+func (ts tuples) WriteTuples(context.Context, ...RelationTuple) error {
+	return errors.New("not supported")
+}
+
+func (ts tuples) DeleteTuples(context.Context, ...RelationTuple) error {
+	return errors.New("not supported")
+}
+
+func (ts tuples) SubjectTuples(ctx context.Context, subject Subject, namespace string) ([]RelationTuple, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var found []RelationTuple
+	for _, s := range ts {
+		t, err := ParseTuple(s)
+		if err != nil {
+			return nil, err
+		}
+		if t.Subject == subject && (namespace == "" || t.Namespace == namespace) {
+			found = append(found, t)
+		}
+	}
+	return found, nil
+}
+
+func (ts tuples) RelationTuples(context.Context, string, string) ([]RelationTuple, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestChecker_Check(t *testing.T) {
+	inherits := func(parent, child string) Inheritance {
+		documents := Namespace{Name: "documents"}
+		return Inheritance{
+			Parent: Relationship{Namespace: documents, Name: parent},
+			Child:  Relationship{Namespace: documents, Name: child},
+		}
+	}
+	chain := tuples{"documents:doc_1#viewer@group:g0#member"}
+	for i := 0; i < 5; i++ {
+		chain = append(chain, fmt.Sprintf("group:g%d#member@group:g%d#member", i, i+1))
+	}
+	chain = append(chain, "group:g5#member@user:alice")
+	internal := WithConditions(&Relationship{
+		Namespace: Namespace{Name: "group"},
+		Name:      "member",
+		Condition: `request.ip in cidr("10.0.0.0/8")`,
+	})
+	from := func(ip string) condition.Attributes {
+		return condition.Attributes{Request: map[string]string{"ip": ip}}
+	}
+	matrix := []struct {
+		name         string
+		tuples       tuples
+		inheritances []Inheritance
+		opts         []CheckOption
+		attrs        condition.Attributes
+		ctx          func() context.Context
+		subject      string
+		expected     bool
+		err          error
+	}{
+		{
+			name:     "given direct tuple",
+			tuples:   tuples{"documents:doc_1#viewer@user:alice"},
+			subject:  "alice",
+			expected: true,
+		},
+		{
+			name:     "given tuple of another object",
+			tuples:   tuples{"documents:doc_2#viewer@user:alice"},
+			subject:  "alice",
+			expected: false,
+		},
+		{
+			name: "given nested usersets",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@group:platform#member",
+				"group:platform#member@user:alice",
+			},
+			subject:  "alice",
+			expected: true,
+		},
+		{
+			name: "given inherited relation",
+			tuples: tuples{
+				"documents:doc_1#owner@group:eng#member",
+				"group:eng#member@user:alice",
+			},
+			inheritances: []Inheritance{inherits("owner", "editor"), inherits("editor", "viewer")},
+			subject:      "alice",
+			expected:     true,
+		},
+		{
+			name: "given cycle of usersets",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:a#member",
+				"group:a#member@group:b#member",
+				"group:b#member@group:a#member",
+				"group:b#member@user:bob",
+			},
+			inheritances: []Inheritance{inherits("viewer", "editor"), inherits("editor", "viewer")},
+			subject:      "alice",
+			expected:     false,
+		},
+		{
+			name:     "given chain within max depth",
+			tuples:   chain,
+			subject:  "alice",
+			expected: true,
+		},
+		{
+			name:    "given chain exceeding max depth",
+			tuples:  chain,
+			opts:    []CheckOption{WithMaxDepth(3)},
+			subject: "alice",
+			err:     ErrMaxDepth,
+		},
+		{
+			name: "given userset with condition holding for attributes",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			attrs:    from("10.0.0.1"),
+			subject:  "alice",
+			expected: true,
+		},
+		{
+			name: "given userset with condition not holding for attributes",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			attrs:    from("192.168.0.1"),
+			subject:  "alice",
+			expected: false,
+		},
+		{
+			name: "given userset with condition missing attribute",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@user:alice",
+			},
+			opts:    []CheckOption{internal},
+			subject: "alice",
+			err:     condition.ErrMissingAttribute,
+		},
+		{
+			name: "given userset with condition missing attribute and direct tuple",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"documents:doc_1#viewer@user:alice",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			subject:  "alice",
+			expected: true,
+		},
+		{
+			name:   "given cancelled context",
+			tuples: tuples{"documents:doc_1#viewer@user:alice"},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			subject: "alice",
+			err:     context.Canceled,
+		},
+	}
+	for _, concurrency := range []int{0, DefaultConcurrency} {
+		for _, m := range matrix {
+			t.Run(fmt.Sprintf("%s with concurrency %d", m.name, concurrency), func(t *testing.T) {
+				c, err := NewChecker(m.tuples, m.inheritances, append(m.opts, WithConcurrency(concurrency))...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ctx := context.Background()
+				if m.ctx != nil {
+					ctx = m.ctx()
+				}
+				subject := Subject{Namespace: "user", Object: m.subject}
+				found, err := c.Check(ctx, "documents", "doc_1", "viewer", subject, m.attrs)
+				if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+					t.Fatalf("got %v; want %v", err, m.err)
+				}
+				if found != m.expected {
+					t.Fatalf("got %t; want %t", found, m.expected)
+				}
+			})
+		}
+	}
+}
+
+func TestNewChecker(t *testing.T) {
+	inheritance := Inheritance{
+		Parent: Relationship{Namespace: Namespace{Name: "folders"}, Name: "viewer"},
+		Child:  Relationship{Namespace: Namespace{Name: "documents"}, Name: "viewer"},
+	}
+	if _, err := NewChecker(tuples{}, []Inheritance{inheritance}); !errors.Is(err, ErrInvalidInheritance) {
+		t.Fatalf("got %v; want %v", err, ErrInvalidInheritance)
+	}
+	invalid := &Relationship{Namespace: Namespace{Name: "documents"}, Name: "viewer", Condition: "request.ip"}
+	if _, err := NewChecker(tuples{}, nil, WithConditions(invalid)); !errors.Is(err, condition.ErrInvalidCondition) {
+		t.Fatalf("got %v; want %v", err, condition.ErrInvalidCondition)
+	}
+}
+
+func TestChecker_Reload(t *testing.T) {
+	ctx := context.Background()
+	documents := Namespace{Name: "documents"}
+	editor := Inheritance{
+		Parent: Relationship{Namespace: documents, Name: "editor"},
+		Child:  Relationship{Namespace: documents, Name: "viewer"},
+	}
+	c, err := NewChecker(tuples{"documents:doc_1#editor@user:alice"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := Subject{Namespace: "user", Object: "alice"}
+	check := func(expected bool) {
+		t.Helper()
+		granted, err := c.Check(ctx, "documents", "doc_1", "viewer", alice, condition.Attributes{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if granted != expected {
+			t.Fatalf("got %t; want %t", granted, expected)
+		}
+	}
+	check(false)
+	if err := c.Reload([]Inheritance{editor}); err != nil {
+		t.Fatal(err)
+	}
+	check(true)
+	conditioned := &Relationship{Namespace: documents, Name: "viewer", Condition: "false"}
+	if err := c.Reload([]Inheritance{editor}, conditioned); err != nil {
+		t.Fatal(err)
+	}
+	check(false)
+	// A reload which fails leaves the checker as it was
+	invalid := &Relationship{Namespace: documents, Name: "viewer", Condition: "request.ip"}
+	if err := c.Reload(nil, invalid); !errors.Is(err, condition.ErrInvalidCondition) {
+		t.Fatalf("got %v; want %v", err, condition.ErrInvalidCondition)
+	}
+	check(false)
+}
+
+func TestChecker_Challenge(t *testing.T) {
+	// Membership of the admins group is only held by requests authenticated with a second factor
+	stepUp := &Relationship{Namespace: Namespace{Name: "group"}, Name: "admin", Condition: `request.mfa == "true"`}
+	internal := &Relationship{
+		Namespace: Namespace{Name: "documents"},
+		Name:      "viewer",
+		Condition: `request.ip in cidr("10.0.0.0/8")`,
+	}
+	request := func(mfa, ip string) condition.Attributes {
+		return condition.Attributes{Request: map[string]string{"mfa": mfa, "ip": ip}}
+	}
+	matrix := []struct {
+		name          string
+		tuples        tuples
+		relationships []*Relationship
+		attrs         condition.Attributes
+		expected      *Relationship
+	}{
+		{
+			name: "given userset with condition not holding",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:ops#admin",
+				"group:ops#admin@user:alice",
+			},
+			relationships: []*Relationship{stepUp},
+			attrs:         request("false", "10.0.0.1"),
+			expected:      &Relationship{Namespace: Namespace{Name: "group"}, Name: "admin"},
+		},
+		{
+			name: "given userset with condition holding",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:ops#admin",
+				"group:ops#admin@user:alice",
+			},
+			relationships: []*Relationship{stepUp},
+			attrs:         request("true", "10.0.0.1"),
+		},
+		{
+			name:          "given subject not holding relation through any condition",
+			tuples:        tuples{"documents:doc_1#viewer@group:ops#admin", "group:ops#admin@user:bob"},
+			relationships: []*Relationship{stepUp},
+			attrs:         request("false", "10.0.0.1"),
+		},
+		{
+			name: "given relation held only through several conditions not holding",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:ops#admin",
+				"group:ops#admin@user:alice",
+			},
+			relationships: []*Relationship{stepUp, internal},
+			attrs:         request("false", "192.168.0.1"),
+		},
+		{
+			name: "given attribute missing from condition",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:ops#admin",
+				"group:ops#admin@user:alice",
+			},
+			relationships: []*Relationship{stepUp},
+			attrs:         condition.Attributes{},
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			c, err := NewChecker(m.tuples, nil, WithConditions(m.relationships...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			alice := Subject{Namespace: "user", Object: "alice"}
+			challenge, err := c.Challenge(context.Background(), "documents", "doc_1", "viewer", alice, m.attrs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(challenge, m.expected) {
+				t.Fatalf("got %v; want %v", challenge, m.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+// Package condition implements the expressions relationships are conditioned on, such as
+//
+//	resource.classification != "secret" && request.ip in cidr("10.0.0.0/8")
+//
+// Expressions are type-checked when compiled and evaluated against the attributes of the resource and of the request
+// being authorized. Evaluation is sandboxed, expressions can only read the attributes they are evaluated against and
+// call the functions below, and they always terminate as they can neither loop nor recurse.
+//
+//	cidr(string) cidr    parses an IP prefix, such as "10.0.0.0/8"
+//	int(string) int      parses a decimal integer
+//	lower(string) string converts a string to lower case
+//
+// Attributes are strings, the operators are
+//
+//	a || b, a && b, !a   on bools
+//	a == b, a != b       on two bools, ints or strings
+//	a < b, a <= b, ...   on two ints or two strings
+//	a in b               on a string and a cidr, where the string is an IP address, or on a value and a list
+package condition
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxLength is the maximum length in bytes of the source of a condition.
+	MaxLength = 4096
+	// MaxDepth is the maximum number of operands and parentheses a part of a condition may be nested within.
+	MaxDepth = 32
+)
+
+var (
+	ErrInvalidCondition = errors.New("invalid condition")
+	ErrMissingAttribute = errors.New("missing attribute")
+	ErrInvalidAttribute = errors.New("invalid attribute")
+)
+
+// Position is the line and column of a character within a condition, both counted from one.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Error is a syntax or type error at a position of a condition. It wraps ErrInvalidCondition.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return ErrInvalidCondition
+}
+
+// Attributes are the attributes a condition is evaluated against.
+type Attributes struct {
+	// Resource holds the attributes of the resource being accessed, such as its classification.
+	Resource map[string]string
+	// Request holds the attributes of the request accessing it, such as the IP address it is made from.
+	Request map[string]string
+}
+
+// Program is a compiled condition.
+type Program struct {
+	root node
+}
+
+// Compile parses and type-checks a condition, which must evaluate to a bool.
+func Compile(src string) (*Program, error) {
+	if len(src) > MaxLength {
+		return nil, fmt.Errorf("%w: condition of %d bytes exceeds %d bytes", ErrInvalidCondition, len(src), MaxLength)
+	}
+	p := &parser{lex: &lexer{src: src, pos: Position{Line: 1, Column: 1}}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != eof {
+		return nil, p.unexpected("end of condition")
+	}
+	t, err := root.check()
+	if err != nil {
+		return nil, err
+	}
+	if t != boolType {
+		return nil, &Error{Pos: root.pos(), Msg: fmt.Sprintf("condition is of type %s, expected bool", t)}
+	}
+	return &Program{root: root}, nil
+}
+
+// Eval returns true if the condition holds for the attributes. An error wrapping ErrMissingAttribute is returned if
+// the condition reads an attribute which is not set, and one wrapping ErrInvalidAttribute if an attribute could not be
+// converted, such as an IP address which is not an address.
+func (p *Program) Eval(attrs Attributes) (bool, error) {
+	v, err := p.root.eval(attrs)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+type typ int
+
+const (
+	boolType typ = iota + 1
+	intType
+	stringType
+	cidrType
+	boolListType
+	intListType
+	stringListType
+)
+
+func (t typ) String() string {
+	switch t {
+	case boolType:
+		return "bool"
+	case intType:
+		return "int"
+	case stringType:
+		return "string"
+	case cidrType:
+		return "cidr"
+	case boolListType:
+		return "list of bool"
+	case intListType:
+		return "list of int"
+	case stringListType:
+		return "list of string"
+	default:
+		return "unknown"
+	}
+}
+
+// lists maps the type of the elements of a list to the type of the list.
+var lists = map[typ]typ{boolType: boolListType, intType: intListType, stringType: stringListType}
+
+// node is a part of a condition, evaluated to a bool, an int64, a string, a netip.Prefix or a list of values of one of
+// the former types, other than netip.Prefix.
+type node interface {
+	pos() Position
+	// check returns the type the node evaluates to, or an error if its operands are of the wrong types.
+	check() (typ, error)
+	eval(attrs Attributes) (any, error)
+}
+
+type literal struct {
+	at    Position
+	value any
+	typ   typ
+}
+
+func (n *literal) pos() Position                { return n.at }
+func (n *literal) check() (typ, error)          { return n.typ, nil }
+func (n *literal) eval(Attributes) (any, error) { return n.value, nil }
+
+type attribute struct {
+	at    Position
+	scope string
+	name  string
+}
+
+func (n *attribute) pos() Position       { return n.at }
+func (n *attribute) check() (typ, error) { return stringType, nil }
+
+func (n *attribute) eval(attrs Attributes) (any, error) {
+	values := attrs.Resource
+	if n.scope == "request" {
+		values = attrs.Request
+	}
+	v, ok := values[n.name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s.%s", ErrMissingAttribute, n.scope, n.name)
+	}
+	return v, nil
+}
+
+type list struct {
+	at    Position
+	elems []node
+}
+
+func (n *list) pos() Position { return n.at }
+
+func (n *list) check() (typ, error) {
+	if len(n.elems) == 0 {
+		return 0, &Error{Pos: n.at, Msg: "empty list"}
+	}
+	var elem typ
+	for i, e := range n.elems {
+		t, err := e.check()
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			elem = t
+		}
+		if _, ok := lists[t]; !ok || t != elem {
+			return 0, &Error{Pos: e.pos(), Msg: fmt.Sprintf("list element of type %s, expected %s", t, elem)}
+		}
+	}
+	return lists[elem], nil
+}
+
+func (n *list) eval(attrs Attributes) (any, error) {
+	values := make([]any, 0, len(n.elems))
+	for _, e := range n.elems {
+		v, err := e.eval(attrs)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+type call struct {
+	at   Position
+	name string
+	args []node
+}
+
+func (n *call) pos() Position { return n.at }
+
+func (n *call) check() (typ, error) {
+	results := map[string]typ{"cidr": cidrType, "int": intType, "lower": stringType}
+	result, ok := results[n.name]
+	if !ok {
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("unknown function %s", n.name)}
+	}
+	if len(n.args) != 1 {
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("%s takes 1 argument, got %d", n.name, len(n.args))}
+	}
+	t, err := n.args[0].check()
+	if err != nil {
+		return 0, err
+	}
+	if t != stringType {
+		return 0, &Error{Pos: n.args[0].pos(), Msg: fmt.Sprintf("argument of %s is of type %s, expected string", n.name, t)}
+	}
+	// Literal arguments are converted up front, which reports a malformed literal when compiling rather than evaluating
+	if lit, ok := n.args[0].(*literal); ok {
+		if _, err := n.apply(lit.value.(string)); err != nil {
+			return 0, &Error{Pos: lit.at, Msg: err.Error()}
+		}
+	}
+	return result, nil
+}
+
+func (n *call) eval(attrs Attributes) (any, error) {
+	arg, err := n.args[0].eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	v, err := n.apply(arg.(string))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAttribute, err)
+	}
+	return v, nil
+}
+
+func (n *call) apply(arg string) (any, error) {
+	switch n.name {
+	case "cidr":
+		prefix, err := netip.ParsePrefix(arg)
+		if err != nil {
+			return nil, fmt.Errorf("malformed cidr %q", arg)
+		}
+		return prefix.Masked(), nil
+	case "int":
+		i, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed int %q", arg)
+		}
+		return i, nil
+	default:
+		return strings.ToLower(arg), nil
+	}
+}
+
+type not struct {
+	at      Position
+	operand node
+}
+
+func (n *not) pos() Position { return n.at }
+
+func (n *not) check() (typ, error) {
+	t, err := n.operand.check()
+	if err != nil {
+		return 0, err
+	}
+	if t != boolType {
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("operand of ! is of type %s, expected bool", t)}
+	}
+	return boolType, nil
+}
+
+func (n *not) eval(attrs Attributes) (any, error) {
+	v, err := n.operand.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+type binary struct {
+	at          Position
+	op          string
+	left, right node
+}
+
+func (n *binary) pos() Position { return n.at }
+
+func (n *binary) check() (typ, error) {
+	left, err := n.left.check()
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.check()
+	if err != nil {
+		return 0, err
+	}
+	var ok bool
+	switch n.op {
+	case "&&", "||":
+		ok = left == boolType && right == boolType
+	case "==", "!=":
+		// Only bools, ints and strings are comparable, which are the types of the elements of lists
+		_, scalar := lists[left]
+		ok = left == right && scalar
+	case "<", "<=", ">", ">=":
+		ok = left == right && (left == intType || left == stringType)
+	case "in":
+		ok = (left == stringType && right == cidrType) || lists[left] == right
+	}
+	if !ok {
+		return 0, &Error{Pos: n.at, Msg: fmt.Sprintf("operator %s does not apply to %s and %s", n.op, left, right)}
+	}
+	return boolType, nil
+}
+
+func (n *binary) eval(attrs Attributes) (any, error) {
+	left, err := n.left.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	// The right operand of && and || is only evaluated if it decides the result, which lets it read attributes the
+	// left operand has made sure are set
+	switch {
+	case n.op == "&&" && !left.(bool):
+		return false, nil
+	case n.op == "||" && left.(bool):
+		return true, nil
+	}
+	right, err := n.right.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "&&", "||":
+		return right.(bool), nil
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "in":
+		if prefix, ok := right.(netip.Prefix); ok {
+			addr, err := netip.ParseAddr(left.(string))
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed ip %q", ErrInvalidAttribute, left)
+			}
+			return prefix.Contains(addr.Unmap()), nil
+		}
+		for _, v := range right.([]any) {
+			if v == left {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	var c int
+	switch l := left.(type) {
+	case int64:
+		c = cmp.Compare(l, right.(int64))
+	case string:
+		c = strings.Compare(l, right.(string))
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
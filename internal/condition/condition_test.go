@@ -0,0 +1,176 @@
+package condition
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	matrix := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{
+			name: "given valid condition",
+			src:  `resource.classification != "secret" && request.ip in cidr("10.0.0.0/8")`,
+		},
+		{
+			name: "given lists and functions",
+			src:  `lower(resource.region) in ["eu", "us"] && !(int(resource.size) > 10)`,
+		},
+		{
+			name: "given condition which is not a bool",
+			src:  `resource.classification`,
+			err:  "1:1: condition is of type string, expected bool",
+		},
+		{
+			name: "given mismatched operands",
+			src:  `resource.size > 10`,
+			err:  "1:15: operator > does not apply to string and int",
+		},
+		{
+			name: "given malformed cidr literal",
+			src:  `request.ip in cidr("10.0.0.0/33")`,
+			err:  `1:20: malformed cidr "10.0.0.0/33"`,
+		},
+		{
+			name: "given unknown function",
+			src:  `upper(resource.region) == "EU"`,
+			err:  "1:1: unknown function upper",
+		},
+		{
+			name: "given unknown identifier",
+			src:  "principal.id == \"alice\"",
+			err:  "1:1: unknown identifier principal, expected resource or request",
+		},
+		{
+			name: "given equality of lists",
+			src:  `["eu"] == ["eu"]`,
+			err:  "1:8: operator == does not apply to list of string and list of string",
+		},
+		{
+			name: "given inequality of lists",
+			src:  `[1, 2] != [2]`,
+			err:  "1:8: operator != does not apply to list of int and list of int",
+		},
+		{
+			name: "given list of mixed types",
+			src:  `resource.region in ["eu", 1]`,
+			err:  "1:27: list element of type int, expected string",
+		},
+		{
+			name: "given syntax error on second line",
+			src:  "resource.a == \"x\" &&\n  resource.b ==",
+			err:  "2:16: expected operand, found end of condition",
+		},
+		{
+			name: "given unterminated string",
+			src:  `resource.a == "x`,
+			err:  "1:15: unterminated string",
+		},
+		{
+			name: "given condition nested too deeply",
+			src:  strings.Repeat("!", MaxDepth+1) + "true",
+			err:  "1:33: condition is nested deeper than 32 levels",
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			_, err := Compile(m.src)
+			if m.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != m.err {
+				t.Fatalf("got %v; want %s", err, m.err)
+			}
+			if !errors.Is(err, ErrInvalidCondition) {
+				t.Fatalf("got %v; want error wrapping %v", err, ErrInvalidCondition)
+			}
+		})
+	}
+}
+
+func TestProgram_Eval(t *testing.T) {
+	matrix := []struct {
+		name     string
+		src      string
+		attrs    Attributes
+		expected bool
+		err      error
+	}{
+		{
+			name: "given matching attributes",
+			src:  `resource.classification != "secret" && request.ip in cidr("10.0.0.0/8")`,
+			attrs: Attributes{
+				Resource: map[string]string{"classification": "internal"},
+				Request:  map[string]string{"ip": "10.1.2.3"},
+			},
+			expected: true,
+		},
+		{
+			name: "given ip outside of cidr",
+			src:  `request.ip in cidr("10.0.0.0/8")`,
+			attrs: Attributes{
+				Request: map[string]string{"ip": "192.168.1.1"},
+			},
+			expected: false,
+		},
+		{
+			name: "given short circuit past missing attribute",
+			src:  `resource.classification == "public" || request.ip in cidr("10.0.0.0/8")`,
+			attrs: Attributes{
+				Resource: map[string]string{"classification": "public"},
+			},
+			expected: true,
+		},
+		{
+			name: "given integer comparison and list",
+			src:  `int(resource.size) <= 10 && lower(resource.region) in ["eu", "us"]`,
+			attrs: Attributes{
+				Resource: map[string]string{"size": "9", "region": "EU"},
+			},
+			expected: true,
+		},
+		{
+			name: "given missing attribute",
+			src:  `resource.classification != "secret"`,
+			err:  ErrMissingAttribute,
+		},
+		{
+			name: "given malformed ip",
+			src:  `request.ip in cidr("10.0.0.0/8")`,
+			attrs: Attributes{
+				Request: map[string]string{"ip": "localhost"},
+			},
+			err: ErrInvalidAttribute,
+		},
+		{
+			name: "given malformed int",
+			src:  `int(resource.size) > 10`,
+			attrs: Attributes{
+				Resource: map[string]string{"size": "ten"},
+			},
+			err: ErrInvalidAttribute,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			p, err := Compile(m.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Eval(m.attrs)
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if got != m.expected {
+				t.Fatalf("got %t; want %t", got, m.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package condition
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type kind int
+
+const (
+	eof kind = iota
+	ident
+	str
+	integer
+	operator
+)
+
+type token struct {
+	kind kind
+	text string
+	pos  Position
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case eof:
+		return "end of condition"
+	case ident, integer:
+		return t.text
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+// operators are ordered so that every operator is listed before any operator it is a prefix of.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "!", "<", ">", "(", ")", "[", "]", ",", "."}
+
+type lexer struct {
+	src    string
+	offset int
+	pos    Position
+}
+
+func (l *lexer) next() (token, error) {
+	for l.offset < len(l.src) {
+		r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.advance(1)
+	}
+	if l.offset == len(l.src) {
+		return token{kind: eof, pos: l.pos}, nil
+	}
+	start, pos := l.offset, l.pos
+	r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		l.scan(func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) })
+		return token{kind: ident, text: l.src[start:l.offset], pos: pos}, nil
+	case unicode.IsDigit(r):
+		l.scan(unicode.IsDigit)
+		return token{kind: integer, text: l.src[start:l.offset], pos: pos}, nil
+	case r == '"':
+		l.advance(1)
+		for escaped := false; ; {
+			if l.offset == len(l.src) || l.src[l.offset] == '\n' {
+				return token{}, &Error{Pos: pos, Msg: "unterminated string"}
+			}
+			c := l.src[l.offset]
+			l.advance(1)
+			if c == '"' && !escaped {
+				break
+			}
+			escaped = c == '\\' && !escaped
+		}
+		s, err := strconv.Unquote(l.src[start:l.offset])
+		if err != nil {
+			return token{}, &Error{Pos: pos, Msg: fmt.Sprintf("malformed string %s", l.src[start:l.offset])}
+		}
+		return token{kind: str, text: s, pos: pos}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.offset:], op) {
+			l.advance(len(op))
+			return token{kind: operator, text: op, pos: pos}, nil
+		}
+	}
+	return token{}, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+}
+
+func (l *lexer) scan(accept func(r rune) bool) {
+	for l.offset < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.offset:])
+		if !accept(r) {
+			return
+		}
+		l.advance(size)
+	}
+}
+
+// advance moves n bytes forward, keeping track of the position of the offset.
+func (l *lexer) advance(n int) {
+	for _, r := range l.src[l.offset : l.offset+n] {
+		if r == '\n' {
+			l.pos.Line++
+			l.pos.Column = 1
+		} else {
+			l.pos.Column++
+		}
+	}
+	l.offset += n
+}
+
+// parser is a recursive descent parser of the grammar below, where comparisons do not associate, which means that
+// a == b == c must be written as (a == b) == c.
+//
+//	or         = and { "||" and } .
+//	and        = unary { "&&" unary } .
+//	unary      = "!" unary | comparison .
+//	comparison = primary [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" ) primary ] .
+//	primary    = string | integer | "true" | "false" | attribute | call | list | "(" or ")" .
+//	attribute  = ( "resource" | "request" ) "." ident .
+//	call       = ident "(" [ or { "," or } ] ")" .
+//	list       = "[" [ or { "," or } ] "]" .
+type parser struct {
+	lex   *lexer
+	tok   token
+	depth int
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// at returns true if the current token is the operator or keyword text.
+func (p *parser) at(text string) bool {
+	return (p.tok.kind == operator || p.tok.kind == ident) && p.tok.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if !p.at(text) {
+		return p.unexpected(fmt.Sprintf("'%s'", text))
+	}
+	return p.advance()
+}
+
+func (p *parser) unexpected(expected string) error {
+	return &Error{Pos: p.tok.pos, Msg: fmt.Sprintf("expected %s, found %s", expected, p.tok)}
+}
+
+// nest guards against conditions nested deeply enough to exhaust the stack of the parser or the evaluator.
+func (p *parser) nest() error {
+	p.depth++
+	if p.depth > MaxDepth {
+		return &Error{Pos: p.tok.pos, Msg: fmt.Sprintf("condition is nested deeper than %d levels", MaxDepth)}
+	}
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	return p.binary([]string{"||"}, p.and)
+}
+
+func (p *parser) and() (node, error) {
+	return p.binary([]string{"&&"}, p.unary)
+}
+
+// binary parses operands separated by any of the left associative operators.
+func (p *parser) binary(ops []string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == operator && slices.Contains(ops, p.tok.text) {
+		b := &binary{at: p.tok.pos, op: p.tok.text, left: left}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if b.right, err = operand(); err != nil {
+			return nil, err
+		}
+		left = b
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if !p.at("!") {
+		return p.comparison()
+	}
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	u := &not{at: p.tok.pos}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	u.operand, err = p.unary()
+	return u, err
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !(p.tok.kind == operator && slices.Contains(comparisons, p.tok.text)) && !p.at("in") {
+		return left, nil
+	}
+	b := &binary{at: p.tok.pos, op: p.tok.text, left: left}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	b.right, err = p.primary()
+	return b, err
+}
+
+var comparisons = []string{"==", "!=", "<", "<=", ">", ">="}
+
+func (p *parser) primary() (node, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	tok := p.tok
+	switch {
+	case tok.kind == str:
+		return &literal{at: tok.pos, value: tok.text, typ: stringType}, p.advance()
+	case tok.kind == integer:
+		n, err := strconv.ParseInt(tok.text, 10, 64)
+		if err != nil {
+			return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("integer %s out of range", tok.text)}
+		}
+		return &literal{at: tok.pos, value: n, typ: intType}, p.advance()
+	case p.at("true"), p.at("false"):
+		return &literal{at: tok.pos, value: tok.text == "true", typ: boolType}, p.advance()
+	case p.at("("):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case p.at("["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		elems, err := p.list("]")
+		return &list{at: tok.pos, elems: elems}, err
+	case tok.kind == ident:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.at("(") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			args, err := p.list(")")
+			return &call{at: tok.pos, name: tok.text, args: args}, err
+		}
+		if tok.text != "resource" && tok.text != "request" {
+			return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unknown identifier %s, expected resource or request", tok.text)}
+		}
+		if err := p.expect("."); err != nil {
+			return nil, err
+		}
+		name := p.tok
+		if name.kind != ident {
+			return nil, p.unexpected("attribute name")
+		}
+		return &attribute{at: tok.pos, scope: tok.text, name: name.text}, p.advance()
+	default:
+		return nil, p.unexpected("operand")
+	}
+}
+
+// list parses the comma separated expressions up to and including the closing text.
+func (p *parser) list(closing string) ([]node, error) {
+	var elems []node
+	for !p.at(closing) {
+		if len(elems) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		elem, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return elems, p.advance()
+}
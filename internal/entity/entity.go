@@ -21,7 +21,7 @@ type Event struct {
 }
 
 type Entity struct {
-	mu *sync.Mutex
+	mu sync.Mutex
 
 	ID      string
 	events  []Event
@@ -41,3 +41,13 @@ func (e *Entity) Raise(event Event) {
 	e.events = append(e.events, event)
 	e.Version = event.Version
 }
+
+// Flush returns the events raised since the entity was loaded or last flushed and forgets about them. It is called by
+// stores once the events have been persisted.
+func (e *Entity) Flush() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := e.events
+	e.events = nil
+	return events
+}
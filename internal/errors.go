@@ -5,4 +5,6 @@ import "errors"
 var (
 	ErrNoExplicitPolicy = errors.New("no explicit policy")
 	ErrNoOperationFound = errors.New("no operation found")
+	ErrNotFound         = errors.New("not found")
+	ErrMissingID        = errors.New("missing entity id")
 )
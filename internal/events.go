@@ -12,3 +12,9 @@ type PolicyAllowed struct {
 type PolicyDenied struct {
 	PolicyID string `json:"policy_id"`
 }
+
+type SchemaApplied struct {
+	Version uint     `json:"version"`
+	Changes []string `json:"changes"`
+	Forced  bool     `json:"forced"`
+}
@@ -0,0 +1,75 @@
+package gatekeeper
+
+import (
+	"context"
+	"fmt"
+)
+
+// UsersetTree describes every subject holding a relation on an object and how they came to hold it.
+type UsersetTree struct {
+	// Userset is the relation on an object expanded by the tree.
+	Userset Subject
+	// Subjects are the direct subjects of the tuples of the userset.
+	Subjects []Subject
+	// Usersets are the expanded usersets named as subjects by the tuples of the userset.
+	Usersets []*UsersetTree
+	// Inherited are the expanded relations on the same object which imply the relation of the userset.
+	Inherited []*UsersetTree
+	// Cycle is true if the userset is already being expanded further up the tree, in which case it is not expanded
+	// again and holds no subjects of its own.
+	Cycle bool
+}
+
+// Expand returns the tree of subjects holding the relation on the object of the namespace, following the same tuples,
+// usersets and inheritance as Check. ErrMaxDepth is returned if the tree is deeper than the maximum depth and
+// ErrConditionalRelation if it reaches a relation with a condition.
+func (c *Checker) Expand(ctx context.Context, namespace, object, relation string) (*UsersetTree, error) {
+	if namespace == "" || object == "" || relation == "" {
+		return nil, fmt.Errorf("%w: expanding requires a namespace, an object and a relation", ErrMalformedTuple)
+	}
+	return c.expand(ctx, c.rules.Load(), Subject{Namespace: namespace, Object: object, Relation: relation}, nil)
+}
+
+func (c *Checker) expand(ctx context.Context, r *rules, userset Subject, parent *path) (*UsersetTree, error) {
+	tree := &UsersetTree{
+		Userset: userset,
+	}
+	if parent.contains(userset) {
+		tree.Cycle = true
+		return tree, nil
+	}
+	current := &path{userset: userset, parent: parent}
+	if parent != nil {
+		current.depth = parent.depth + 1
+	}
+	if current.depth > c.maxDepth {
+		return nil, fmt.Errorf("%w: %s at depth %d", ErrMaxDepth, userset, current.depth)
+	}
+	if err := r.conditional(userset); err != nil {
+		return nil, err
+	}
+	tuples, err := c.tuples.ObjectTuples(ctx, userset.Namespace, userset.Object, userset.Relation)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tuples {
+		if !t.Subject.Userset() {
+			tree.Subjects = append(tree.Subjects, t.Subject)
+			continue
+		}
+		subtree, err := c.expand(ctx, r, t.Subject, current)
+		if err != nil {
+			return nil, err
+		}
+		tree.Usersets = append(tree.Usersets, subtree)
+	}
+	for _, relation := range r.inherited[userset.Namespace+":"+userset.Relation] {
+		inherited := Subject{Namespace: userset.Namespace, Object: userset.Object, Relation: relation}
+		subtree, err := c.expand(ctx, r, inherited, current)
+		if err != nil {
+			return nil, err
+		}
+		tree.Inherited = append(tree.Inherited, subtree)
+	}
+	return tree, nil
+}
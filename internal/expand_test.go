@@ -0,0 +1,117 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// render writes the tree with one userset or subject per line, indented by depth.
+func render(tree *UsersetTree) string {
+	var b strings.Builder
+	var walk func(tree *UsersetTree, indent string)
+	walk = func(tree *UsersetTree, indent string) {
+		fmt.Fprintf(&b, "%s%s", indent, tree.Userset)
+		if tree.Cycle {
+			b.WriteString(" (cycle)")
+		}
+		b.WriteString("\n")
+		for _, s := range tree.Subjects {
+			fmt.Fprintf(&b, "%s  %s\n", indent, s)
+		}
+		for _, t := range tree.Usersets {
+			walk(t, indent+"  ")
+		}
+		for _, t := range tree.Inherited {
+			walk(t, indent+"  ")
+		}
+	}
+	walk(tree, "")
+	return b.String()
+}
+
+func TestChecker_Expand(t *testing.T) {
+	editor := Inheritance{
+		Parent: Relationship{Namespace: Namespace{Name: "documents"}, Name: "editor"},
+		Child:  Relationship{Namespace: Namespace{Name: "documents"}, Name: "viewer"},
+	}
+	matrix := []struct {
+		name         string
+		tuples       tuples
+		inheritances []Inheritance
+		opts         []CheckOption
+		relation     string
+		expected     string
+		err          error
+	}{
+		{
+			name: "given usersets and inherited relation",
+			tuples: tuples{
+				"documents:doc_1#viewer@user:alice",
+				"documents:doc_1#viewer@group:eng#member",
+				"documents:doc_1#editor@user:carol",
+				"group:eng#member@user:bob",
+			},
+			inheritances: []Inheritance{editor},
+			relation:     "viewer",
+			expected: "documents:doc_1#viewer\n" +
+				"  user:alice\n" +
+				"  group:eng#member\n" +
+				"    user:bob\n" +
+				"  documents:doc_1#editor\n" +
+				"    user:carol\n",
+		},
+		{
+			name: "given cycle of usersets",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:a#member",
+				"group:a#member@group:b#member",
+				"group:b#member@group:a#member",
+			},
+			relation: "viewer",
+			expected: "documents:doc_1#viewer\n" +
+				"  group:a#member\n" +
+				"    group:b#member\n" +
+				"      group:a#member (cycle)\n",
+		},
+		{
+			name: "given userset with condition",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@user:bob",
+			},
+			opts: []CheckOption{WithConditions(&Relationship{
+				Namespace: Namespace{Name: "group"},
+				Name:      "member",
+				Condition: `request.ip in cidr("10.0.0.0/8")`,
+			})},
+			relation: "viewer",
+			err:      ErrConditionalRelation,
+		},
+		{
+			name:   "given missing relation",
+			tuples: tuples{},
+			err:    ErrMalformedTuple,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			c, err := NewChecker(m.tuples, m.inheritances, m.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree, err := c.Expand(context.Background(), "documents", "doc_1", m.relation)
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if err != nil {
+				return
+			}
+			if rendered := render(tree); rendered != m.expected {
+				t.Fatalf("got\n%s\nwant\n%s", rendered, m.expected)
+			}
+		})
+	}
+}
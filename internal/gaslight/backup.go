@@ -0,0 +1,49 @@
+package gaslight
+
+import (
+	"errors"
+	"github.com/ernilsson/gatekeeper/internal/gaslight/internal/dal"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	ErrMalformedBackup = dal.ErrMalformedBackup
+	ErrBackupChain     = dal.ErrBackupChain
+)
+
+// BackupHeader describes a backup written by DB.Backup.
+type BackupHeader = dal.BackupHeader
+
+// Backup writes a full backup of the DB to w if since is zero, or an incremental backup of the pages written after the
+// transaction with the provided id otherwise. The TxID of the returned header is the since of the next incremental
+// backup.
+func (db *DB) Backup(w io.Writer, since uint64) (BackupHeader, error) {
+	return db.dal.Backup(w, since)
+}
+
+// ReadBackupHeader reads the header at the start of a backup, which holds the id of the transaction that the next
+// incremental backup should be taken since.
+func ReadBackupHeader(r io.Reader) (BackupHeader, error) {
+	return dal.ReadBackupHeader(r)
+}
+
+// Restore creates a gaslight file at the provided path from a full backup followed by a chain of incremental backups,
+// skipping those taken after until. Neither the file nor its write-ahead log may exist, and the file is removed again
+// if the restore fails.
+func Restore(path string, until time.Time, backups ...io.Reader) (BackupHeader, error) {
+	if _, err := os.Stat(path + "-wal"); err == nil {
+		return BackupHeader{}, &os.PathError{Op: "restore", Path: path + "-wal", Err: os.ErrExist}
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return BackupHeader{}, err
+	}
+	header, err := dal.Restore(file, until, backups...)
+	if err = errors.Join(err, file.Close()); err != nil {
+		_ = os.Remove(path)
+		return header, err
+	}
+	return header, nil
+}
@@ -0,0 +1,89 @@
+package gaslight
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	source, err := Open(filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	c, err := source.CreateCollection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Insert([]byte("doc_1#viewer"), []byte("user:alice")); err != nil {
+		t.Fatal(err)
+	}
+	full := &bytes.Buffer{}
+	header, err := source.Backup(full, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Insert([]byte("doc_1#editor"), []byte("user:bob")); err != nil {
+		t.Fatal(err)
+	}
+	incremental := &bytes.Buffer{}
+	if _, err := source.Backup(incremental, header.TxID); err != nil {
+		t.Fatal(err)
+	}
+
+	matrix := []struct {
+		name     string
+		path     string
+		expected []string
+		err      error
+	}{
+		{
+			name:     "given new path",
+			path:     filepath.Join(dir, "restored.db"),
+			expected: []string{"doc_1#editor", "doc_1#viewer"},
+		},
+		{
+			name: "given existing file",
+			path: filepath.Join(dir, "source.db"),
+			err:  os.ErrExist,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			backups := []io.Reader{bytes.NewReader(full.Bytes()), bytes.NewReader(incremental.Bytes())}
+			if _, err := Restore(m.path, time.Now(), backups...); !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if m.err != nil {
+				return
+			}
+			restored, err := Open(m.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer restored.Close()
+			rc, err := restored.Collection("relationships")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			err = rc.ForEach(func(key, value []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(keys, m.expected) {
+				t.Fatalf("got %v; want %v", keys, m.expected)
+			}
+		})
+	}
+}
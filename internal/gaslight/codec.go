@@ -0,0 +1,170 @@
+package gaslight
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrMalformedEncoding = errors.New("malformed encoding")
+)
+
+// Codec converts values of type T to and from the bytes stored in a collection. Codecs used for keys must be order
+// preserving, meaning that comparing two encoded values byte by byte must give the same result as comparing the values
+// themselves.
+type Codec[T any] interface {
+	Encode(T) ([]byte, error)
+	Decode([]byte) (T, error)
+}
+
+// StringCodec is an order preserving codec which stores strings as their raw bytes.
+type StringCodec struct{}
+
+func (StringCodec) Encode(s string) ([]byte, error) {
+	return []byte(s), nil
+}
+
+func (StringCodec) Decode(buf []byte) (string, error) {
+	return string(buf), nil
+}
+
+// Uint64Codec is an order preserving codec which stores integers as eight big endian bytes.
+type Uint64Codec struct{}
+
+func (Uint64Codec) Encode(x uint64) ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, x), nil
+}
+
+func (Uint64Codec) Decode(buf []byte) (uint64, error) {
+	if len(buf) != 8 {
+		return 0, fmt.Errorf("%w: expected 8 bytes, got %d", ErrMalformedEncoding, len(buf))
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// Tuple is a composite key made up of string and uint64 elements. Tuples are ordered element by element, which makes
+// it possible to look up all keys sharing a common prefix, such as every relationship within a namespace.
+type Tuple []any
+
+const (
+	tupleUint64 = 0x01
+	tupleString = 0x02
+	// tupleEscape follows any zero byte within a string element to tell it apart from the terminator of the element.
+	tupleEscape = 0xff
+)
+
+// TupleCodec is an order preserving codec for tuples. Each element is prefixed by a type tag, integers are stored as
+// big endian and strings are terminated by a zero byte with any zero bytes within the string escaped.
+type TupleCodec struct{}
+
+func (TupleCodec) Encode(t Tuple) ([]byte, error) {
+	buf := make([]byte, 0, 16*len(t))
+	for i, element := range t {
+		switch e := element.(type) {
+		case uint64:
+			buf = append(buf, tupleUint64)
+			buf = binary.BigEndian.AppendUint64(buf, e)
+		case string:
+			buf = append(buf, tupleString)
+			for j := 0; j < len(e); j++ {
+				buf = append(buf, e[j])
+				if e[j] == 0x00 {
+					buf = append(buf, tupleEscape)
+				}
+			}
+			buf = append(buf, 0x00)
+		default:
+			return nil, fmt.Errorf("unsupported tuple element %d of type %T", i, element)
+		}
+	}
+	return buf, nil
+}
+
+func (TupleCodec) Decode(buf []byte) (Tuple, error) {
+	t := make(Tuple, 0, 4)
+	for pos := 0; pos < len(buf); {
+		tag := buf[pos]
+		pos += 1
+		switch tag {
+		case tupleUint64:
+			if len(buf)-pos < 8 {
+				return nil, fmt.Errorf("%w: truncated integer element", ErrMalformedEncoding)
+			}
+			t = append(t, binary.BigEndian.Uint64(buf[pos:]))
+			pos += 8
+		case tupleString:
+			s := make([]byte, 0, len(buf)-pos)
+			for {
+				if pos == len(buf) {
+					return nil, fmt.Errorf("%w: unterminated string element", ErrMalformedEncoding)
+				}
+				b := buf[pos]
+				pos += 1
+				if b != 0x00 {
+					s = append(s, b)
+					continue
+				}
+				if pos < len(buf) && buf[pos] == tupleEscape {
+					s = append(s, 0x00)
+					pos += 1
+					continue
+				}
+				break
+			}
+			t = append(t, string(s))
+		default:
+			return nil, fmt.Errorf("%w: unknown tuple tag %#x", ErrMalformedEncoding, tag)
+		}
+	}
+	return t, nil
+}
+
+// JSONCodec stores values as JSON documents. It is not order preserving and should only be used for values.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(buf []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(buf, &v)
+	return v, err
+}
+
+// ProtoCodec stores values as protocol buffer messages. New must return an empty message to decode into.
+type ProtoCodec[T proto.Message] struct {
+	New func() T
+}
+
+func (c ProtoCodec[T]) Encode(v T) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (c ProtoCodec[T]) Decode(buf []byte) (T, error) {
+	v := c.New()
+	err := proto.Unmarshal(buf, v)
+	return v, err
+}
+
+// BinaryCodec stores values using their own binary encoding as implemented by encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler on a pointer to the value.
+type BinaryCodec[T any, PT interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}] struct{}
+
+func (BinaryCodec[T, PT]) Encode(v T) ([]byte, error) {
+	return PT(&v).MarshalBinary()
+}
+
+func (BinaryCodec[T, PT]) Decode(buf []byte) (T, error) {
+	var v T
+	err := PT(&v).UnmarshalBinary(buf)
+	return v, err
+}
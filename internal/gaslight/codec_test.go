@@ -0,0 +1,75 @@
+package gaslight
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTupleCodec(t *testing.T) {
+	matrix := []struct {
+		name string
+		a, b Tuple
+	}{
+		{
+			name: "given strings with common prefix",
+			a:    Tuple{"documents", "a"},
+			b:    Tuple{"documents", "ab"},
+		},
+		{
+			name: "given shorter first element",
+			a:    Tuple{"doc", "z"},
+			b:    Tuple{"documents", "a"},
+		},
+		{
+			name: "given embedded zero byte",
+			a:    Tuple{"a\x00", "z"},
+			b:    Tuple{"a\x00b", "a"},
+		},
+		{
+			name: "given integers",
+			a:    Tuple{"events", uint64(255)},
+			b:    Tuple{"events", uint64(256)},
+		},
+		{
+			name: "given prefix tuple",
+			a:    Tuple{"documents"},
+			b:    Tuple{"documents", "a"},
+		},
+	}
+	codec := TupleCodec{}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			a, err := codec.Encode(m.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := codec.Encode(m.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Compare(a, b) >= 0 {
+				t.Fatalf("got %v >= %v; want %v < %v", a, b, m.a, m.b)
+			}
+			decoded, err := codec.Decode(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, m.a) {
+				t.Fatalf("got %v; want %v", decoded, m.a)
+			}
+		})
+	}
+}
+
+func TestUint64Codec(t *testing.T) {
+	codec := Uint64Codec{}
+	a, _ := codec.Encode(1)
+	b, _ := codec.Encode(1 << 8)
+	if bytes.Compare(a, b) >= 0 {
+		t.Fatalf("got %v >= %v; want order preserved", a, b)
+	}
+	if _, err := codec.Decode([]byte{1, 2}); err == nil {
+		t.Fatal("expected error when decoding truncated integer")
+	}
+}
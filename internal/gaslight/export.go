@@ -0,0 +1,196 @@
+package gaslight
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Record is a single line of a JSON Lines export. A record either describes a collection, in which case Sequence is
+// set, or an item within a collection. Keys and values are encoded as base64 by encoding/json.
+type Record struct {
+	// Path holds the names of the collections in which the collection is nested, outermost first. It is empty for top
+	// level collections.
+	Path       []string `json:"path,omitempty"`
+	Collection string   `json:"collection"`
+	Sequence   *uint64  `json:"sequence,omitempty"`
+	Key        []byte   `json:"key,omitempty"`
+	Value      []byte   `json:"value,omitempty"`
+}
+
+// Export writes the collections with the provided names, or every collection if no names are provided, to w as JSON
+// Lines. Each collection is written as a collection record followed by one record per item and then its sub-collections.
+// The export is made within a single transaction, which makes it a consistent copy of the collections as of the time it
+// started, while writes to the DB wait for it to finish.
+func (db *DB) Export(w io.Writer, names ...string) error {
+	encoder := json.NewEncoder(w)
+	return db.View(func(tx *Tx) error {
+		if len(names) == 0 {
+			var err error
+			if names, err = tx.Collections(); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			c, err := tx.Collection(name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := export(tx, encoder, nil, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func export(tx *Tx, encoder *json.Encoder, path []string, c *Collection) error {
+	sequence := tx.Sequence(c)
+	err := encoder.Encode(Record{
+		Path:       path,
+		Collection: c.Name(),
+		Sequence:   &sequence,
+	})
+	if err != nil {
+		return err
+	}
+	err = tx.ForEach(c, func(key, value []byte) error {
+		return encoder.Encode(Record{
+			Path:       path,
+			Collection: c.Name(),
+			Key:        key,
+			Value:      value,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	names, err := tx.SubCollections(c)
+	if err != nil {
+		return err
+	}
+	nested := append(path[:len(path):len(path)], c.Name())
+	for _, name := range names {
+		sub, err := tx.SubCollection(c, name)
+		if err != nil {
+			return err
+		}
+		if err := export(tx, encoder, nested, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrNotEmpty is returned by Import if the DB already holds collections.
+var ErrNotEmpty = errors.New("gaslight file is not empty")
+
+// Import reads JSON Lines as written by Export from r and stores every record in the DB, which must not hold any
+// collections or ErrNotEmpty is returned. The import is made within a single transaction, which means that either
+// every record is stored or none of them are, and the items of each collection are stored as a single batch.
+func (db *DB) Import(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	// Lines hold base64 encoded keys and values which may be considerably larger than the default token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	return db.Update(func(tx *Tx) error {
+		names, err := tx.Collections()
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return fmt.Errorf("%w: holds %d collections", ErrNotEmpty, len(names))
+		}
+		i := &importer{tx: tx}
+		for line := 1; scanner.Scan(); line++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if err := i.add(record); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return i.flush()
+	})
+}
+
+// importer collects the items of the collection currently being imported, which are written in a batch once a record
+// of another collection is read. Export writes the items of a collection right after its collection record, which
+// keeps a single collection in memory at a time.
+type importer struct {
+	tx    *Tx
+	path  []string
+	c     *Collection
+	items []*Item
+}
+
+func (i *importer) add(record Record) error {
+	path := append(record.Path[:len(record.Path):len(record.Path)], record.Collection)
+	if !slices.Equal(path, i.path) {
+		if err := i.flush(); err != nil {
+			return err
+		}
+		c, err := i.resolve(path)
+		if err != nil {
+			return err
+		}
+		i.path, i.c = path, c
+	}
+	if record.Sequence != nil {
+		if *record.Sequence > i.tx.Sequence(i.c) {
+			return i.tx.SetSequence(i.c, *record.Sequence)
+		}
+		return nil
+	}
+	i.items = append(i.items, NewItem(record.Key, record.Value))
+	return nil
+}
+
+// flush writes the collected items to the collection they were read for.
+func (i *importer) flush() error {
+	if len(i.items) == 0 {
+		return nil
+	}
+	if err := i.tx.PutBatch(i.c, i.items); err != nil {
+		return fmt.Errorf("%s: %w", strings.Join(i.path, "/"), err)
+	}
+	i.items = nil
+	return nil
+}
+
+// resolve returns the collection at the end of the provided path of names, creating any collection along the way that
+// does not already exist.
+func (i *importer) resolve(path []string) (*Collection, error) {
+	var c *Collection
+	for n, name := range path {
+		var err error
+		var next *Collection
+		if n == 0 {
+			next, err = i.tx.Collection(name)
+		} else {
+			next, err = i.tx.SubCollection(c, name)
+		}
+		if errors.Is(err, ErrCollectionNotFound) {
+			if n == 0 {
+				next, err = i.tx.CreateCollection(name)
+			} else {
+				next, err = i.tx.CreateSubCollection(c, name)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		c = next
+	}
+	return c, nil
+}
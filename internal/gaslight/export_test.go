@@ -0,0 +1,183 @@
+package gaslight
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDB_Export(t *testing.T) {
+	source, err := Open(filepath.Join(t.TempDir(), "source.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	namespaces, err := source.CreateCollection("namespaces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := namespaces.Insert([]byte("documents"), []byte{0x00, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	documents, err := namespaces.CreateSubCollection("documents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := documents.Insert([]byte("doc_1#viewer"), []byte("user:alice")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := documents.NextSequence(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.CreateCollection("events"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := source.Export(buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 5 {
+		t.Fatalf("got %d lines; want %d\n%s", lines, 5, buf.String())
+	}
+	exported := buf.String()
+
+	target, err := Open(filepath.Join(t.TempDir(), "target.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	if err := target.Import(buf); err != nil {
+		t.Fatal(err)
+	}
+	reexported := &bytes.Buffer{}
+	if err := target.Export(reexported); err != nil {
+		t.Fatal(err)
+	}
+	if reexported.String() != exported {
+		t.Fatalf("got %s; want %s", reexported.String(), exported)
+	}
+}
+
+// TestDB_ExportConcurrent exports a pair of collections while every key is written to the first and then the second of
+// them, which means that a consistent export never holds a key in the second collection that is missing in the first.
+func TestDB_ExportConcurrent(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "gaslight.db"), SyncNone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	first, err := db.CreateCollection("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.CreateCollection("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 2000; i++ {
+			key := []byte(fmt.Sprintf("key_%04d", i))
+			if err := first.Insert(key, nil); err != nil {
+				done <- err
+				return
+			}
+			if err := second.Insert(key, nil); err != nil {
+				done <- err
+				return
+			}
+		}
+		close(done)
+	}()
+	for exporting := true; exporting; {
+		select {
+		case err, ok := <-done:
+			if ok {
+				t.Fatal(err)
+			}
+			exporting = false
+		default:
+		}
+		buf := &bytes.Buffer{}
+		if err := db.Export(buf); err != nil {
+			t.Fatal(err)
+		}
+		keys := make(map[string]map[string]bool)
+		decoder := json.NewDecoder(buf)
+		for decoder.More() {
+			var record Record
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			if keys[record.Collection] == nil {
+				keys[record.Collection] = make(map[string]bool)
+			}
+			if record.Key != nil {
+				keys[record.Collection][string(record.Key)] = true
+			}
+		}
+		for key := range keys["second"] {
+			if !keys["first"][key] {
+				t.Fatalf("%s: got key in second collection which is missing in first", key)
+			}
+		}
+	}
+}
+
+func TestDB_Import(t *testing.T) {
+	matrix := []struct {
+		name     string
+		existing string
+		input    string
+		failed   bool
+		err      error
+	}{
+		{
+			name:  "given records of a collection",
+			input: `{"collection":"events","sequence":2}` + "\n" + `{"collection":"events","key":"AQ==","value":"Ag=="}`,
+		},
+		{
+			name:     "given file holding a collection",
+			existing: "principals",
+			input:    `{"collection":"events","sequence":2}`,
+			failed:   true,
+			err:      ErrNotEmpty,
+		},
+		{
+			name:   "given malformed record after a collection",
+			input:  `{"collection":"events","sequence":2}` + "\n" + `{"collection":`,
+			failed: true,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			db, err := Open(filepath.Join(t.TempDir(), "gaslight.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if m.existing != "" {
+				if _, err := db.CreateCollection(m.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = db.Import(strings.NewReader(m.input))
+			if (err != nil) != m.failed || m.err != nil && !errors.Is(err, m.err) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			_, err = db.Collection("events")
+			if !m.failed && err != nil {
+				t.Fatalf("got %v; want the imported collection", err)
+			}
+			// A failed import stores none of its records
+			if m.failed && !errors.Is(err, ErrCollectionNotFound) {
+				t.Fatalf("got %v; want %v", err, ErrCollectionNotFound)
+			}
+		})
+	}
+}
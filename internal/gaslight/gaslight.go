@@ -0,0 +1,280 @@
+package gaslight
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/gaslight/internal/dal"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+var (
+	ErrItemNotFound       = dal.ErrItemNotFound
+	ErrCollectionNotFound = dal.ErrCollectionNotFound
+	ErrCollectionExists   = dal.ErrCollectionExists
+	ErrReadOnly           = dal.ErrReadOnly
+	ErrTxGap              = dal.ErrTxGap
+	ErrPageOverflow       = dal.ErrPageOverflow
+	ErrMalformedPage      = dal.ErrMalformedPage
+	ErrMalformedNode      = dal.ErrMalformedNode
+	ErrNoCompressor       = dal.ErrNoCompressor
+	ErrLocked             = errors.New("gaslight file is locked by another process")
+)
+
+// Collection is an ordered set of keys and values stored in a gaslight file.
+type Collection = dal.Collection
+
+// Item is a key and value stored in a collection.
+type Item = dal.Item
+
+// NewItem creates an item to be stored through Collection.PutBatch.
+func NewItem(key, value []byte) *Item {
+	return dal.NewItem(key, value)
+}
+
+// Stats describes the state of a gaslight file and the I/O performed on it since it was opened.
+type Stats = dal.Stats
+
+// CollectionStats describes the shape of the tree of a single collection, as returned by Collection.Stats.
+type CollectionStats = dal.CollectionStats
+
+// Commit is a committed transaction, made up of the pages written by it.
+type Commit = dal.Commit
+
+// Frame is the content of a single page written by a commit.
+type Frame = dal.Frame
+
+// Tx gives access to the collections of a DB within a single transaction, as started by DB.View and DB.Update.
+type Tx = dal.Tx
+
+// Subscription receives every commit made to a DB after it was created.
+type Subscription = dal.Subscription
+
+// Option configures optional behaviour of a DB.
+type Option = dal.Option
+
+// ReadOnly makes the DB reject all writes with ErrReadOnly, except for commits applied through DB.Apply.
+func ReadOnly() Option {
+	return dal.ReadOnly()
+}
+
+// SyncAlways makes every commit sync the write-ahead log before it returns. This is the default.
+func SyncAlways() Option {
+	return dal.SyncAlways()
+}
+
+// SyncGroupCommit makes concurrently committing transactions share a single sync of the write-ahead log, started at
+// most latency after the first of them committed.
+func SyncGroupCommit(latency time.Duration) Option {
+	return dal.SyncGroupCommit(latency)
+}
+
+// SyncNone makes commits return without syncing the write-ahead log, trading the durability of the latest commits for
+// throughput.
+func SyncNone() Option {
+	return dal.SyncNone()
+}
+
+// Compressor compresses the nodes written to the pages of a DB.
+type Compressor = dal.Compressor
+
+// WithCompressor makes the DB compress the nodes it writes, which lets a page hold several pages worth of items that
+// compress well. A DB holding compressed pages must always be opened with the same compressor.
+func WithCompressor(c Compressor) Option {
+	return dal.WithCompressor(c)
+}
+
+// Snappy returns a Compressor using the snappy block format.
+func Snappy() Compressor {
+	return dal.Snappy()
+}
+
+// Open opens the gaslight file at the provided path, creating and initialising it if it does not already exist. Commits
+// are written to a write-ahead log next to the file, with the same path suffixed by "-wal", which is replayed by Open.
+// An exclusive lock is taken on the file until the DB is closed, Open returns ErrLocked if another process has the
+// file open, through Open or OpenReadOnly.
+func Open(path string, opts ...Option) (*DB, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	// The lock is taken before the log is opened, since opening it may replay and truncate it
+	if err := lock(file, true); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	log, err := os.OpenFile(path+"-wal", os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		_ = log.Close()
+		return nil, err
+	}
+	opts = append([]Option{dal.WithLog(log)}, opts...)
+	var d *dal.DAL
+	if info.Size() == 0 {
+		d, err = dal.New(file, opts...)
+	} else {
+		d, err = dal.Load(file, opts...)
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = log.Close()
+		return nil, err
+	}
+	return &DB{
+		dal:  d,
+		file: file,
+		log:  log,
+	}, nil
+}
+
+// OpenReadOnly opens the existing gaslight file at the provided path as of the last commit in its write-ahead log,
+// without ever writing to the file or the log. Unlike Open, and the ReadOnly option, the log is neither replayed nor
+// checkpointed, commits are kept in memory instead, which makes it safe to inspect or back up a file without changing
+// it. A shared lock is taken on the file, which means that OpenReadOnly returns ErrLocked while the file is opened by
+// Open in another process. Every write to the DB returns ErrReadOnly.
+func OpenReadOnly(path string, opts ...Option) (*DB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(file, false); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if info.Size() == 0 {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w: %s is empty", ErrMalformedPage, path)
+	}
+	opts = append(opts, dal.Frozen())
+	log, err := os.Open(path + "-wal")
+	switch {
+	case err == nil:
+		opts = append([]Option{dal.WithLog(log)}, opts...)
+	case errors.Is(err, fs.ErrNotExist):
+		log = nil
+	default:
+		_ = file.Close()
+		return nil, err
+	}
+	d, err := dal.Load(file, opts...)
+	if err != nil {
+		_ = file.Close()
+		if log != nil {
+			_ = log.Close()
+		}
+		return nil, err
+	}
+	return &DB{
+		dal:  d,
+		file: file,
+		log:  log,
+	}, nil
+}
+
+// DB is an open gaslight file. Every operation on a DB, and on the collections within it, runs in a transaction of its
+// own and operations may be called concurrently.
+type DB struct {
+	dal  *dal.DAL
+	file *os.File
+	// log is nil if the DB was opened by OpenReadOnly and there is no write-ahead log.
+	log *os.File
+}
+
+// Collection returns the collection stored under the provided name or ErrCollectionNotFound if there is none.
+func (db *DB) Collection(name string) (*Collection, error) {
+	return db.dal.Collection(name)
+}
+
+// CreateCollection creates a new collection under the provided name or returns ErrCollectionExists if the name is
+// already taken.
+func (db *DB) CreateCollection(name string) (*Collection, error) {
+	return db.dal.CreateCollection(name)
+}
+
+// DropCollection removes the collection stored under the provided name along with all of its items and sub-collections.
+func (db *DB) DropCollection(name string) error {
+	return db.dal.DropCollection(name)
+}
+
+// View calls fn with a transaction through which the collections of the DB can be read at a single point in time. The
+// methods of the DB and its collections must not be called from within fn.
+func (db *DB) View(fn func(tx *Tx) error) error {
+	return db.dal.View(fn)
+}
+
+// Update calls fn with a transaction through which the collections of the DB can be read and written. Every write made
+// through it is committed at once if fn succeeds and discarded otherwise, which makes it possible to update several
+// collections atomically. The methods of the DB and its collections must not be called from within fn.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	return db.dal.Update(fn)
+}
+
+// Stats returns the page counts of the file along with the number of pages and bytes read and written since it was
+// opened.
+func (db *DB) Stats() Stats {
+	return db.dal.Stats()
+}
+
+// DumpMetadata writes a human-readable description of the metadata and freelist pages of the file to w.
+func (db *DB) DumpMetadata(w io.Writer) error {
+	return db.dal.DumpMetadata(w)
+}
+
+// DumpPage writes the page with the provided id, decoded as a tree node, to w.
+func (db *DB) DumpPage(w io.Writer, id uint64) error {
+	return db.dal.DumpPage(w, id)
+}
+
+// TxID returns the identifier of the last transaction committed to the DB.
+func (db *DB) TxID() uint64 {
+	return db.dal.TxID()
+}
+
+// Commits returns every commit with a transaction id larger than the provided one, or a single snapshot commit if the
+// write-ahead log no longer holds all of them.
+func (db *DB) Commits(after uint64) ([]Commit, error) {
+	return db.dal.Commits(after)
+}
+
+// Subscribe returns a subscription to the commits made to the DB with room for the provided number of unreceived
+// commits. The subscription is closed if the subscriber falls further behind than that.
+func (db *DB) Subscribe(buffer int) *Subscription {
+	return db.dal.Subscribe(buffer)
+}
+
+// Apply writes a commit read from another DB through Commits or Subscribe to this DB.
+func (db *DB) Apply(c Commit) error {
+	return db.dal.Apply(c)
+}
+
+// Close flushes the metadata of the file, checkpoints the write-ahead log and closes both, releasing the lock on the
+// file. A DB opened by OpenReadOnly is closed without writing to either.
+func (db *DB) Close() error {
+	if err := db.dal.Close(); err != nil {
+		_ = db.file.Close()
+		if db.log != nil {
+			_ = db.log.Close()
+		}
+		return err
+	}
+	if db.log != nil {
+		if err := db.log.Close(); err != nil {
+			_ = db.file.Close()
+			return err
+		}
+	}
+	return db.file.Close()
+}
@@ -0,0 +1,114 @@
+package gaslight
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gaslight.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix := []struct {
+		name string
+		open func(path string, opts ...Option) (*DB, error)
+	}{
+		{name: "given open", open: Open},
+		{name: "given read only open", open: OpenReadOnly},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			if _, err := m.open(path); !errors.Is(err, ErrLocked) {
+				t.Fatalf("got %v; want %v", err, ErrLocked)
+			}
+		})
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	readers := make([]*DB, 2)
+	for i := range readers {
+		if readers[i], err = OpenReadOnly(path); err != nil {
+			t.Fatalf("got %v; want read only opens to share the file", err)
+		}
+		defer readers[i].Close()
+	}
+	if _, err := Open(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v; want %v while the file is opened read only", err, ErrLocked)
+	}
+}
+
+// TestOpenReadOnly opens a copy of a file taken while its commits were only in the write-ahead log, as they are after a
+// crash, which must be read as of the last commit without the file or the log being changed.
+func TestOpenReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "source.db"), SyncNone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c, err := db.CreateCollection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Insert([]byte("doc_1#viewer"), []byte("user:alice")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "crashed.db")
+	for _, suffix := range []string{"", "-wal"} {
+		b, err := os.ReadFile(filepath.Join(dir, "source.db") + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+suffix, b, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := os.ReadFile(path + "-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) == 0 {
+		t.Fatal("got an empty log; want the commits to be in it")
+	}
+
+	copied, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relationships, err := copied.Collection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := relationships.Find([]byte("doc_1#viewer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(item.Value()) != "user:alice" {
+		t.Fatalf("got %q; want %q", item.Value(), "user:alice")
+	}
+	if _, err := copied.CreateCollection("events"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("got %v; want %v", err, ErrReadOnly)
+	}
+	if err := copied.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for suffix, expected := range map[string][]byte{"": file, "-wal": log} {
+		b, err := os.ReadFile(path + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, expected) {
+			t.Fatalf("%s%s: got %d bytes changed by opening it read only", path, suffix, len(b))
+		}
+	}
+}
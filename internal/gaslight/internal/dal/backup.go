@@ -0,0 +1,205 @@
+package dal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// backupMagic starts every backup, followed by the backup header.
+	backupMagic = "GLBK"
+	// backupHeaderSize is the size of the header following the magic: the id of the transaction the backup is based
+	// on, the id of the last transaction in the backup, the time of the backup, the page size and the number of pages.
+	backupHeaderSize = 8 + 8 + 8 + 8 + 8
+)
+
+var (
+	ErrMalformedBackup = errors.New("malformed backup")
+	ErrBackupChain     = errors.New("broken backup chain")
+)
+
+// BackupHeader describes a backup. A full backup has a Since of zero and holds every page of the file, an incremental
+// backup holds the pages written by the transactions after Since up to and including TxID.
+type BackupHeader struct {
+	Since    uint64
+	TxID     uint64
+	Time     time.Time
+	PageSize uint64
+	Pages    uint64
+}
+
+// Incremental returns true if the backup only holds the pages written since a previous backup.
+func (h BackupHeader) Incremental() bool {
+	return h.Since > 0
+}
+
+// Backup writes every page written by a transaction after since to w, or every page of the file if since is zero. The
+// TxID of the returned header is the since of the next incremental backup in the chain. Writes are blocked while the
+// backup is taken, which guarantees that the backup is consistent. Pages are streamed to w one at a time, which means
+// that an incremental backup reads every page twice: once to count the pages included, as the count is part of the
+// header, and once to write them.
+func (d *DAL) Backup(w io.Writer, since uint64) (BackupHeader, error) {
+	var header BackupHeader
+	err := d.view(func() error {
+		if since > d.metadata.txid {
+			return fmt.Errorf("%w: backup since transaction %d is ahead of transaction %d", ErrBackupChain, since,
+				d.metadata.txid)
+		}
+		// The metadata and freelist pages are always included, as they describe the state of every other page
+		included := func(p *page) bool {
+			return since == 0 || p.id == metadataPageID || p.id == d.metadata.freelist || p.txid() > since
+		}
+		count := d.freelist.allocated + 1
+		if since > 0 {
+			count = 0
+			err := d.pages(func(p *page) error {
+				if included(p) {
+					count++
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		header = BackupHeader{
+			Since:    since,
+			TxID:     d.metadata.txid,
+			Time:     time.Now(),
+			PageSize: d.pageSize,
+			Pages:    count,
+		}
+		checksum := crc32.NewIEEE()
+		out := io.MultiWriter(w, checksum)
+		if _, err := io.WriteString(out, backupMagic); err != nil {
+			return err
+		}
+		buf := make([]byte, backupHeaderSize)
+		binary.LittleEndian.PutUint64(buf, header.Since)
+		binary.LittleEndian.PutUint64(buf[8:], header.TxID)
+		binary.LittleEndian.PutUint64(buf[16:], uint64(header.Time.UnixNano()))
+		binary.LittleEndian.PutUint64(buf[24:], header.PageSize)
+		binary.LittleEndian.PutUint64(buf[32:], header.Pages)
+		if _, err := out.Write(buf); err != nil {
+			return err
+		}
+		err := d.pages(func(p *page) error {
+			if !included(p) {
+				return nil
+			}
+			if _, err := out.Write(binary.LittleEndian.AppendUint64(nil, p.id)); err != nil {
+				return err
+			}
+			_, err := out.Write(p.data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(binary.LittleEndian.AppendUint32(nil, checksum.Sum32()))
+		return err
+	})
+	return header, err
+}
+
+// pages calls fn with every page of the file in order of their ids. Pages are read outside of any transaction, which
+// keeps them from being cached.
+func (d *DAL) pages(fn func(p *page) error) error {
+	for id := uint64(0); id <= d.freelist.allocated; id++ {
+		p, err := d.read(id)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadBackupHeader reads the header at the start of a backup.
+func ReadBackupHeader(r io.Reader) (BackupHeader, error) {
+	buf := make([]byte, len(backupMagic)+backupHeaderSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return BackupHeader{}, fmt.Errorf("%w: %w", ErrMalformedBackup, err)
+	}
+	if string(buf[:len(backupMagic)]) != backupMagic {
+		return BackupHeader{}, fmt.Errorf("%w: not a backup", ErrMalformedBackup)
+	}
+	buf = buf[len(backupMagic):]
+	return BackupHeader{
+		Since:    binary.LittleEndian.Uint64(buf),
+		TxID:     binary.LittleEndian.Uint64(buf[8:]),
+		Time:     time.Unix(0, int64(binary.LittleEndian.Uint64(buf[16:]))),
+		PageSize: binary.LittleEndian.Uint64(buf[24:]),
+		Pages:    binary.LittleEndian.Uint64(buf[32:]),
+	}, nil
+}
+
+// Restore writes a full backup followed by a chain of incremental backups, each based on the backup before it, to the
+// provided datasource, which should be empty. Backups taken after until are skipped, which restores the file as of the
+// last backup taken at or before until. The header of the last restored backup is returned. If a backup turns out to
+// be corrupt the datasource is left in an unusable state.
+func Restore(ds Datasource, until time.Time, backups ...io.Reader) (BackupHeader, error) {
+	var restored BackupHeader
+	var found bool
+	for i, r := range backups {
+		checksum := crc32.NewIEEE()
+		in := io.TeeReader(r, checksum)
+		header, err := ReadBackupHeader(in)
+		if err != nil {
+			return restored, fmt.Errorf("backup %d: %w", i, err)
+		}
+		if header.Time.After(until) {
+			break
+		}
+		switch {
+		case i == 0 && header.Incremental():
+			return restored, fmt.Errorf("%w: backup %d is incremental, restores start with a full backup",
+				ErrBackupChain, i)
+		case i > 0 && header.Since != restored.TxID:
+			return restored, fmt.Errorf("%w: backup %d is based on transaction %d, expected %d", ErrBackupChain, i,
+				header.Since, restored.TxID)
+		case i > 0 && header.PageSize != restored.PageSize:
+			return restored, fmt.Errorf("%w: backup %d has a page size of %d, expected %d", ErrBackupChain, i,
+				header.PageSize, restored.PageSize)
+		}
+		if header.PageSize != uint64(os.Getpagesize()) {
+			return restored, fmt.Errorf("backup %d has a page size of %d, expected the system page size %d", i,
+				header.PageSize, os.Getpagesize())
+		}
+		buf := make([]byte, 8+header.PageSize)
+		for n := uint64(0); n < header.Pages; n++ {
+			if _, err := io.ReadFull(in, buf); err != nil {
+				return restored, fmt.Errorf("%w: backup %d: %w", ErrMalformedBackup, i, err)
+			}
+			id := binary.LittleEndian.Uint64(buf)
+			if _, err := ds.Seek(int64(id*header.PageSize), io.SeekStart); err != nil {
+				return restored, err
+			}
+			if _, err := ds.Write(buf[8:]); err != nil {
+				return restored, err
+			}
+		}
+		sum := make([]byte, 4)
+		if _, err := io.ReadFull(r, sum); err != nil {
+			return restored, fmt.Errorf("%w: backup %d: %w", ErrMalformedBackup, i, err)
+		}
+		if binary.LittleEndian.Uint32(sum) != checksum.Sum32() {
+			return restored, fmt.Errorf("%w: backup %d fails its checksum", ErrMalformedBackup, i)
+		}
+		restored, found = header, true
+	}
+	if !found {
+		return restored, fmt.Errorf("%w: no full backup taken at or before %s", ErrBackupChain, until)
+	}
+	if s, ok := ds.(Syncer); ok {
+		return restored, s.Sync()
+	}
+	return restored, nil
+}
@@ -0,0 +1,143 @@
+package dal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestDAL_Backup(t *testing.T) {
+	d, err := New(&file{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert := func(prefix string, n int) {
+		for i := 0; i < n; i++ {
+			if err := c.Insert([]byte(fmt.Sprintf("%s_%04d", prefix, i)), []byte(prefix)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	backup := func(since uint64) (BackupHeader, []byte) {
+		var buf bytes.Buffer
+		header, err := d.Backup(&buf, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Backups taken in quick succession are kept apart in time to make restoring to either of them possible
+		time.Sleep(time.Millisecond)
+		return header, buf.Bytes()
+	}
+	insert("a", 500)
+	full, fullBackup := backup(0)
+	insert("b", 10)
+	first, firstBackup := backup(full.TxID)
+	insert("c", 10)
+	second, secondBackup := backup(first.TxID)
+	if first.Pages >= full.Pages {
+		t.Fatalf("got %d pages in incremental backup; want less than the %d of the full backup", first.Pages, full.Pages)
+	}
+
+	corrupt := bytes.Clone(firstBackup)
+	corrupt[len(corrupt)-1] ^= 0xff
+	matrix := []struct {
+		name     string
+		until    time.Time
+		backups  [][]byte
+		txid     uint64
+		expected map[string]int
+		err      error
+	}{
+		{
+			name:     "given full backup",
+			until:    time.Now(),
+			backups:  [][]byte{fullBackup},
+			txid:     full.TxID,
+			expected: map[string]int{"a": 500},
+		},
+		{
+			name:     "given full backup and chain of incremental backups",
+			until:    time.Now(),
+			backups:  [][]byte{fullBackup, firstBackup, secondBackup},
+			txid:     second.TxID,
+			expected: map[string]int{"a": 500, "b": 10, "c": 10},
+		},
+		{
+			name:     "given point in time between incremental backups",
+			until:    first.Time,
+			backups:  [][]byte{fullBackup, firstBackup, secondBackup},
+			txid:     first.TxID,
+			expected: map[string]int{"a": 500, "b": 10},
+		},
+		{
+			name:    "given point in time before full backup",
+			until:   full.Time.Add(-time.Second),
+			backups: [][]byte{fullBackup, firstBackup},
+			err:     ErrBackupChain,
+		},
+		{
+			name:    "given incremental backup first",
+			until:   time.Now(),
+			backups: [][]byte{firstBackup, secondBackup},
+			err:     ErrBackupChain,
+		},
+		{
+			name:    "given gap in chain",
+			until:   time.Now(),
+			backups: [][]byte{fullBackup, secondBackup},
+			err:     ErrBackupChain,
+		},
+		{
+			name:    "given corrupt backup",
+			until:   time.Now(),
+			backups: [][]byte{fullBackup, corrupt},
+			err:     ErrMalformedBackup,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			readers := make([]io.Reader, len(m.backups))
+			for i, b := range m.backups {
+				readers[i] = bytes.NewReader(b)
+			}
+			ds := &file{}
+			header, err := Restore(ds, m.until, readers...)
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if err != nil {
+				return
+			}
+			if header.TxID != m.txid {
+				t.Fatalf("got transaction %d; want %d", header.TxID, m.txid)
+			}
+			restored, err := Load(ds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := restored.Collection("relationships")
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := make(map[string]int)
+			err = rc.ForEach(func(key, value []byte) error {
+				found[string(value)]++
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(found, m.expected) {
+				t.Fatalf("got %v; want %v", found, m.expected)
+			}
+		})
+	}
+}
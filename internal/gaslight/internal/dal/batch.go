@@ -0,0 +1,96 @@
+package dal
+
+import (
+	"slices"
+)
+
+// NewItem creates an item to be stored through PutBatch.
+func NewItem(key, value []byte) *Item {
+	return &Item{
+		key:   key,
+		value: value,
+	}
+}
+
+// PutBatch stores every item in a single transaction. The items are sorted by key before being inserted, which means
+// that consecutive inserts follow the same path from the root, and since the transaction keeps every page it reads or
+// writes in memory each page is read from and written to the datasource at most once. If the same key occurs more than
+// once the last of the items is stored.
+func (c *Collection) PutBatch(items []*Item) error {
+	return c.dal.update(func() error {
+		return c.putBatch(items)
+	})
+}
+
+func (c *Collection) putBatch(items []*Item) error {
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b *Item) int {
+		return Compare(a.key, b.key)
+	})
+	for _, item := range sorted {
+		if err := c.put(item.key, item.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMany looks up every provided key in a single traversal of the tree, reading each node at most once. The returned
+// items are in the same order as the keys, with nil in place of any key that was not found.
+func (c *Collection) GetMany(keys [][]byte) ([]*Item, error) {
+	var found []*Item
+	err := c.dal.view(func() error {
+		var err error
+		found, err = c.getItems(keys)
+		return err
+	})
+	return found, err
+}
+
+func (c *Collection) getItems(keys [][]byte) ([]*Item, error) {
+	found := make([]*Item, len(keys))
+	// Lookups work on the indexes of the keys, sorted by key, to be able to place each item at the index of its key
+	indexes := make([]int, len(keys))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortFunc(indexes, func(a, b int) int {
+		return Compare(keys[a], keys[b])
+	})
+	if err := c.getMany(c.root, keys, indexes, found); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (c *Collection) getMany(id uint64, keys [][]byte, indexes []int, found []*Item) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	node, err := c.load(id)
+	if err != nil {
+		return err
+	}
+	if node.Leaf() {
+		for _, i := range indexes {
+			if item, ok := node.Find(keys[i]); ok {
+				found[i] = item
+			}
+		}
+		return nil
+	}
+	// As the indexes are sorted by key the keys belonging to each child form a contiguous run
+	start := 0
+	for start < len(indexes) {
+		child := node.childIndex(keys[indexes[start]])
+		end := start + 1
+		for end < len(indexes) && node.childIndex(keys[indexes[end]]) == child {
+			end++
+		}
+		if err := c.getMany(node.children[child], keys, indexes[start:end], found); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package dal
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollection_PutBatch(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make([]*Item, 0, 3001)
+	for _, k := range rand.New(rand.NewSource(4)).Perm(3000) {
+		items = append(items, NewItem([]byte(fmt.Sprintf("key_%04d", k)), []byte(fmt.Sprintf("value_%04d", k))))
+	}
+	items = append(items, NewItem([]byte("key_0007"), []byte("replaced")))
+	txid := d.TxID()
+	if err := c.PutBatch(items); err != nil {
+		t.Fatal(err)
+	}
+	if d.TxID() != txid+1 {
+		t.Fatalf("got transaction %d; want batch to be committed as %d", d.TxID(), txid+1)
+	}
+
+	keys := [][]byte{[]byte("key_2999"), []byte("missing"), []byte("key_0007"), []byte("key_0000")}
+	found, err := c.GetMany(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"value_2999", "", "replaced", "value_0000"}
+	for i := range keys {
+		if want[i] == "" {
+			if found[i] != nil {
+				t.Fatalf("%s: got %s; want no item", keys[i], found[i].value)
+			}
+			continue
+		}
+		if found[i] == nil || string(found[i].value) != want[i] {
+			t.Fatalf("%s: got %v; want %s", keys[i], found[i], want[i])
+		}
+	}
+	all := make([][]byte, 0, 3000)
+	for k := 0; k < 3000; k++ {
+		all = append(all, []byte(fmt.Sprintf("key_%04d", k)))
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := d.Stats().PagesRead
+	found, err = c.GetMany(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range found {
+		if item == nil {
+			t.Fatalf("%s: got no item", all[i])
+		}
+	}
+	if read := d.Stats().PagesRead - before; read > uint64(stats.Nodes) {
+		t.Fatalf("got %d pages read; want at most one read of each of the %d nodes", read, stats.Nodes)
+	}
+}
@@ -1,8 +1,9 @@
 package dal
 
 import (
-	"encoding/binary"
 	"errors"
+	"fmt"
+	"math"
 )
 
 const (
@@ -10,8 +11,10 @@ const (
 )
 
 var (
-	ErrItemNotFound = errors.New("item not found")
-	ErrNodeIsRoot   = errors.New("node is root")
+	ErrItemNotFound       = errors.New("item not found")
+	ErrNodeIsRoot         = errors.New("node is root")
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrCollectionExists   = errors.New("collection already exists")
 )
 
 type Collection struct {
@@ -19,70 +22,206 @@ type Collection struct {
 	name string
 	root uint64
 	dal  *DAL
+	// parent is the collection in which the header of this collection is stored. It is nil for the root collection
+	// and for collections that are stored directly on a page of their own.
+	parent *Collection
+	// sequence is the last value handed out by NextSequence.
+	sequence uint64
+	// collections is the root page of the tree holding the headers of the sub-collections of this collection, it is
+	// EmptyNodeID until the first sub-collection is created.
+	collections uint64
+	// registry is the collection backed by the collections tree, owner is set on registries to point back at the
+	// collection owning them. Sub-collections that have been opened are kept in open.
+	registry *Collection
+	owner    *Collection
+	open     map[string]*Collection
 }
 
-func (c *Collection) Serialize(buf []byte) {
+// Name returns the name under which the collection is registered.
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// size returns the number of bytes required to serialize the collection header.
+func (c *Collection) size() int {
+	return 8 + 8 + 8 + 2 + len(c.name)
+}
+
+// persist makes sure that the current root of the collection is recorded wherever the collection is referenced from.
+func (c *Collection) persist() error {
+	switch {
+	case c == c.dal.collections:
+		c.dal.metadata.root = c.root
+		return c.dal.Serialize(c.dal.metadata, metadataPageID)
+	case c.owner != nil:
+		c.owner.collections = c.root
+		return c.owner.persist()
+	case c.parent != nil:
+		buf := make([]byte, c.size())
+		if err := c.Serialize(buf); err != nil {
+			return err
+		}
+		return c.parent.put([]byte(c.name), buf)
+	default:
+		return nil
+	}
+}
+
+// NextSequence increments the sequence of the collection and returns the new value, the first value returned for a
+// collection being 1. The sequence is stored in the header of the collection, which is written before the value is
+// returned. If the header cannot be written the sequence is left unchanged.
+func (c *Collection) NextSequence() (uint64, error) {
+	var sequence uint64
+	err := c.dal.update(func() error {
+		c.sequence += 1
+		if err := c.persist(); err != nil {
+			c.sequence -= 1
+			return err
+		}
+		sequence = c.sequence
+		return nil
+	})
+	return sequence, err
+}
+
+// SetSequence sets the sequence of the collection, the next call to NextSequence returns the provided value plus one.
+func (c *Collection) SetSequence(sequence uint64) error {
+	return c.dal.update(func() error {
+		return c.setSequence(sequence)
+	})
+}
+
+func (c *Collection) setSequence(sequence uint64) error {
+	previous := c.sequence
+	c.sequence = sequence
+	if err := c.persist(); err != nil {
+		c.sequence = previous
+		return err
+	}
+	return nil
+}
+
+// Sequence returns the last value handed out by NextSequence without incrementing it.
+func (c *Collection) Sequence() uint64 {
+	var sequence uint64
+	_ = c.dal.view(func() error {
+		sequence = c.sequence
+		return nil
+	})
+	return sequence
+}
+
+func (c *Collection) Serialize(buf []byte) error {
+	if len(c.name) > math.MaxUint16 {
+		return fmt.Errorf("%w: collection name of %d bytes", ErrPageOverflow, len(c.name))
+	}
 	head := serializer{
 		direction: forwards,
 		buffer:    buf,
 	}
 	head.PutUint64(c.root)
+	head.PutUint64(c.collections)
+	head.PutUint64(c.sequence)
 	head.PutUint16(uint16(len(c.name)))
 	head.Put([]byte(c.name))
+	return head.err
 }
 
-func (c *Collection) Deserialize(buf []byte) {
-	head := 0
-	c.root = binary.LittleEndian.Uint64(buf[head:])
-	head += 8
-	length := binary.LittleEndian.Uint16(buf[head:])
-	head += 2
-	name := make([]byte, length)
-	copy(name, buf[head:])
-	c.name = string(name)
+func (c *Collection) Deserialize(buf []byte) error {
+	head := deserializer{
+		buffer:    buf,
+		malformed: ErrMalformedPage,
+	}
+	c.root = head.Uint64()
+	c.collections = head.Uint64()
+	c.sequence = head.Uint64()
+	length := head.Uint16()
+	c.name = string(head.Bytes(int(length)))
+	return head.err
 }
 
 func (c *Collection) Find(key []byte) (*Item, error) {
-	return c.find(key, c.root)
+	var item *Item
+	err := c.dal.view(func() error {
+		var err error
+		item, err = c.find(key, c.root)
+		return err
+	})
+	return item, err
 }
 
 func (c *Collection) find(key []byte, id uint64) (*Item, error) {
-	node := &Node{}
-	if err := c.dal.Deserialize(node, id); err != nil {
+	node, err := c.load(id)
+	if err != nil {
 		return nil, err
 	}
-	item, found := node.Find(key)
-	if found {
-		return item, nil
+	if node.Parent() {
+		return c.find(key, node.Child(key))
 	}
-	if node.Leaf() {
+	item, found := node.Find(key)
+	if !found {
 		return nil, ErrItemNotFound
 	}
-	return c.find(key, node.Child(key))
+	return item, nil
 }
 
+// leaf returns the leaf in which the provided key is stored, or would be stored if it is not present in the
+// collection. The parent of every node along the way is set from the path taken rather than read from its page.
+func (c *Collection) leaf(key []byte) (*Node, error) {
+	node, err := c.load(c.root)
+	if err != nil {
+		return nil, err
+	}
+	node.parent = EmptyNodeID
+	for node.Parent() {
+		parent := node.id
+		if node, err = c.load(node.Child(key)); err != nil {
+			return nil, err
+		}
+		node.parent = parent
+	}
+	return node, nil
+}
+
+// Insert stores the value under the provided key. If the key is already present in the collection its value is
+// replaced.
 func (c *Collection) Insert(key, val []byte) error {
+	return c.dal.update(func() error {
+		return c.put(key, val)
+	})
+}
+
+// put inserts the item and records any change of the root of the collection.
+func (c *Collection) put(key, val []byte) error {
+	root := c.root
+	if err := c.insert(key, val); err != nil {
+		return err
+	}
+	if c.root != root {
+		return c.persist()
+	}
+	return nil
+}
+
+func (c *Collection) insert(key, val []byte) error {
 	item := &Item{
 		key:   key,
 		value: val,
 	}
-	node := &Node{
-		id: c.root,
+	if size := item.size(); size > c.dal.maxItemSize() {
+		return fmt.Errorf("%w: item of %d bytes exceeds limit of %d bytes", ErrPageOverflow, size,
+			c.dal.maxItemSize())
 	}
-	if err := c.dal.Deserialize(node, c.root); err != nil {
+	node, err := c.leaf(key)
+	if err != nil {
 		return err
 	}
-	for node.Parent() {
-		parent := node.id
-		child := node.Child(item.key)
-		if err := c.dal.Deserialize(node, node.Child(item.key)); err != nil {
-			return err
-		}
-		node.id = child
-		node.parent = parent
+	if existing, found := node.Find(item.key); found {
+		existing.value = item.value
+	} else {
+		node.Insert(item)
 	}
-	node.Insert(item)
-	if node.Overpopulated() {
+	if c.dal.overpopulated(node) {
 		return c.Split(node)
 	}
 	return c.dal.Serialize(node, node.id)
@@ -109,14 +248,29 @@ func (c *Collection) Split(n *Node) error {
 	}
 	a, b, promoted := Split(n)
 	ptr := parent.Insert(promoted)
-	// Since two new nodes are created by split we shall release the page on which the split node was stored on
-	c.dal.freelist.release(n.id)
-
+	// The first segment stays on the page of the split node, which means that only the children of the second segment
+	// need their parent reference updated
+	a.id, b.id = n.id, c.dal.freelist.id()
 	a.parent, b.parent = parent.id, parent.id
-	a.id, b.id = c.dal.freelist.id(), c.dal.freelist.id()
-	// The page identifiers need to be added to the parent at the correct index to ensure traversal of the tree
+	if n.Leaf() {
+		// The second segment is linked in between the first segment and the leaf which used to follow the split node
+		a.prev, a.next = n.prev, b.id
+		b.prev, b.next = a.id, n.next
+		if n.next != EmptyNodeID {
+			next, err := c.load(n.next)
+			if err != nil {
+				return err
+			}
+			next.prev = b.id
+			if err := c.dal.Serialize(next, next.id); err != nil {
+				return err
+			}
+		}
+	}
+	// The page identifiers need to be added to the parent at the correct index to ensure traversal of the tree. The
+	// first segment takes the place of the split node while the second is inserted directly after it.
 	parent.AddChild(ptr, a.id)
-	parent.AddChild(ptr+1, b.id)
+	parent.InsertChild(ptr+1, b.id)
 
 	if err := c.dal.Serialize(parent, parent.id); err != nil {
 		return err
@@ -127,14 +281,32 @@ func (c *Collection) Split(n *Node) error {
 	if err := c.dal.Serialize(b, b.id); err != nil {
 		return err
 	}
+	if err := c.adopt(b); err != nil {
+		return err
+	}
 	// If adding another key to the parent caused it to overpopulate we need to recursively apply the same operation to
 	// the parent, either until the parent is no longer overpopulated or until the root has been split.
-	if parent.Overpopulated() {
+	if c.dal.overpopulated(parent) {
 		return c.Split(parent)
 	}
 	return nil
 }
 
+// adopt updates the parent reference of every child of the provided node to point at the node.
+func (c *Collection) adopt(n *Node) error {
+	for _, id := range n.children {
+		child := &Node{}
+		if err := c.dal.Deserialize(child, id); err != nil {
+			return err
+		}
+		child.parent = n.id
+		if err := c.dal.Serialize(child, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Collection) Parent(n *Node) (*Node, error) {
 	if n.parent == EmptyNodeID {
 		return nil, ErrNodeIsRoot
@@ -147,6 +319,119 @@ func (c *Collection) Parent(n *Node) (*Node, error) {
 	return parent, nil
 }
 
+// Delete removes the item stored under the provided key or returns ErrItemNotFound if there is none. Leaves are only
+// removed from the tree once they are empty, underpopulated nodes are not merged with their siblings.
 func (c *Collection) Delete(key []byte) error {
-	panic("not implemented")
+	return c.dal.update(func() error {
+		return c.remove(key)
+	})
+}
+
+// remove deletes the item and records any change of the root of the collection.
+func (c *Collection) remove(key []byte) error {
+	root := c.root
+	if err := c.delete(key); err != nil {
+		return err
+	}
+	if c.root != root {
+		return c.persist()
+	}
+	return nil
+}
+
+func (c *Collection) delete(key []byte) error {
+	// The path from the root down to the leaf holding the key is kept along with the index at which each node is found
+	// in the children of its parent, indexes[i] being the index of path[i+1].
+	var path []*Node
+	var indexes []int
+	node, err := c.load(c.root)
+	if err != nil {
+		return err
+	}
+	for node.Parent() {
+		path = append(path, node)
+		index := node.childIndex(key)
+		indexes = append(indexes, index)
+		if node, err = c.load(node.children[index]); err != nil {
+			return err
+		}
+	}
+	i, found := node.index(key)
+	if !found {
+		return ErrItemNotFound
+	}
+	node.items = append(node.items[:i], node.items[i+1:]...)
+	if len(node.items) > 0 || len(path) == 0 {
+		return c.dal.Serialize(node, node.id)
+	}
+	// The leaf is now empty and must be removed from the tree, along with any ancestors which only exist to point at it
+	if err := c.unlink(node); err != nil {
+		return err
+	}
+	c.dal.freelist.release(node.id)
+	j := len(path) - 1
+	for j > 0 && len(path[j].items) == 0 {
+		c.dal.freelist.release(path[j].id)
+		j--
+	}
+	ancestor, index := path[j], indexes[j]
+	// Removing a child also requires removing one of the separators surrounding it, which leaves the range of keys of
+	// the removed child to one of its siblings
+	if len(ancestor.items) > 0 {
+		separator := max(index-1, 0)
+		ancestor.items = append(ancestor.items[:separator], ancestor.items[separator+1:]...)
+	}
+	ancestor.children = append(ancestor.children[:index], ancestor.children[index+1:]...)
+	if ancestor.id != c.root || len(ancestor.items) > 0 {
+		return c.dal.Serialize(ancestor, ancestor.id)
+	}
+	// The root is left with at most one child, which takes its place and makes the tree one level shorter
+	root := ancestor
+	for len(root.items) == 0 && root.Parent() {
+		c.dal.freelist.release(root.id)
+		if root, err = c.load(root.children[0]); err != nil {
+			return err
+		}
+	}
+	root.parent = EmptyNodeID
+	if err := c.dal.Serialize(root, root.id); err != nil {
+		return err
+	}
+	c.root = root.id
+	return nil
+}
+
+// unlink removes the provided leaf from the chain of leaves by linking its siblings to each other.
+func (c *Collection) unlink(leaf *Node) error {
+	if leaf.prev != EmptyNodeID {
+		prev, err := c.load(leaf.prev)
+		if err != nil {
+			return err
+		}
+		prev.next = leaf.next
+		if err := c.dal.Serialize(prev, prev.id); err != nil {
+			return err
+		}
+	}
+	if leaf.next != EmptyNodeID {
+		next, err := c.load(leaf.next)
+		if err != nil {
+			return err
+		}
+		next.prev = leaf.prev
+		if err := c.dal.Serialize(next, next.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the node stored on the page with the provided id.
+func (c *Collection) load(id uint64) (*Node, error) {
+	node := &Node{}
+	if err := c.dal.Deserialize(node, id); err != nil {
+		return nil, err
+	}
+	node.id = id
+	return node, nil
 }
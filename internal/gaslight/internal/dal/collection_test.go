@@ -0,0 +1,175 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCollection_Insert(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Inserting in random order causes splits in the middle of internal nodes and not only at their right edge
+	keys := rand.New(rand.NewSource(1)).Perm(5000)
+	for _, k := range keys {
+		if err := c.Insert([]byte(fmt.Sprintf("key_%05d", k)), []byte(fmt.Sprintf("value_%05d", k))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Insert([]byte("key_00042"), []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = d.Collection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range keys {
+		item, err := c.Find([]byte(fmt.Sprintf("key_%05d", k)))
+		if err != nil {
+			t.Fatalf("key_%05d: %v", k, err)
+		}
+		want := fmt.Sprintf("value_%05d", k)
+		if k == 42 {
+			want = "replaced"
+		}
+		if string(item.value) != want {
+			t.Fatalf("got %s; want %s", item.value, want)
+		}
+	}
+	if _, err := d.CreateCollection("relationships"); err != ErrCollectionExists {
+		t.Fatalf("got %v; want %v", err, ErrCollectionExists)
+	}
+	if err := c.Insert([]byte("large"), make([]byte, os.Getpagesize())); !errors.Is(err, ErrPageOverflow) {
+		t.Fatalf("got %v; want %v", err, ErrPageOverflow)
+	}
+}
+
+func TestCollection_Delete(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(2))
+	keys := random.Perm(3000)
+	for _, k := range keys {
+		if err := c.Insert([]byte(fmt.Sprintf("key_%05d", k)), []byte(fmt.Sprintf("value_%05d", k))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Deleting every key but the last few in random order exercises the removal of emptied leaves from the chain of
+	// leaves as well as the removal of the separators pointing at them
+	deleted := random.Perm(3000)[:2990]
+	for _, k := range deleted {
+		if err := c.Delete([]byte(fmt.Sprintf("key_%05d", k))); err != nil {
+			t.Fatalf("key_%05d: %v", k, err)
+		}
+	}
+	gone := make(map[int]bool)
+	for _, k := range deleted {
+		gone[k] = true
+	}
+	for k := 0; k < 3000; k++ {
+		_, err := c.Find([]byte(fmt.Sprintf("key_%05d", k)))
+		if gone[k] && err != ErrItemNotFound {
+			t.Fatalf("key_%05d: got %v; want %v", k, err, ErrItemNotFound)
+		}
+		if !gone[k] && err != nil {
+			t.Fatalf("key_%05d: %v", k, err)
+		}
+	}
+	if err := c.Delete([]byte("key_missing")); err != ErrItemNotFound {
+		t.Fatalf("got %v; want %v", err, ErrItemNotFound)
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Items != 10 {
+		t.Fatalf("got %d items; want %d", stats.Items, 10)
+	}
+	var remaining []string
+	err = c.ForEachReverse(func(key, _ []byte) error {
+		remaining = append(remaining, string(key))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 10 || !slices.IsSortedFunc(remaining, func(a, b string) int { return strings.Compare(b, a) }) {
+		t.Fatalf("got %v; want the 10 remaining keys in descending order", remaining)
+	}
+}
+
+func TestCollection_NextSequence(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for want := uint64(1); want <= 3; want++ {
+		got, err := c.NextSequence()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("got %d; want %d", got, want)
+		}
+	}
+	// The file is loaded again without being closed first, the sequence must already be on disk
+	d, err = Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = d.Collection("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.NextSequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 4 {
+		t.Fatalf("got %d; want %d", got, 4)
+	}
+}
@@ -0,0 +1,119 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"math"
+)
+
+const (
+	// pageCompressed is set in the flags of a page holding a compressed node. The compressed node is preceded by its
+	// length as a uint16.
+	pageCompressed = 1 << 0
+	// maxCompressedNodePages is the number of pages worth of items a node may hold once compressed. It bounds the
+	// memory required to decompress a page and keeps item offsets addressable by a uint16.
+	maxCompressedNodePages = 4
+)
+
+var (
+	ErrNoCompressor = errors.New("page is compressed but no compressor is configured")
+)
+
+// Compressor compresses the nodes written to the pages of a DAL. Both methods may use dst, if it is large enough, to
+// hold the returned bytes. A page compressed by one instance of a compressor must be possible to decompress with any
+// other instance of it, including instances in other processes.
+type Compressor interface {
+	Compress(dst, src []byte) ([]byte, error)
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// WithCompressor makes the DAL compress every node it writes with the provided compressor. Nodes are then split once
+// their compressed, rather than their uncompressed, size fills a page, which lets a page hold several pages worth of
+// items that compress well. Files holding compressed pages must always be loaded with the same compressor.
+func WithCompressor(c Compressor) Option {
+	return func(d *DAL) {
+		d.compressor = c
+	}
+}
+
+// Snappy returns a Compressor using the snappy block format.
+func Snappy() Compressor {
+	return snappyCompressor{}
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Compress(dst, src []byte) ([]byte, error) {
+	return snappy.Encode(dst, src), nil
+}
+
+func (snappyCompressor) Decompress(dst, src []byte) ([]byte, error) {
+	return snappy.Decode(dst, src)
+}
+
+// flags returns the flags of the page, which describe how the remainder of the page is encoded.
+func (p *page) flags() uint8 {
+	return p.data[8]
+}
+
+func (p *page) setFlags(flags uint8) {
+	p.data[8] = flags
+}
+
+// maxCompressedNodeSize returns the largest number of bytes a node may occupy uncompressed when it is written to a page
+// compressed.
+func (d *DAL) maxCompressedNodeSize() int {
+	return min(maxCompressedNodePages*int(d.pageSize), math.MaxUint16+1)
+}
+
+// compress serializes the node and compresses it. Serialize leaves the last byte of the buffer unused, which is why the
+// buffer is one byte larger than the node.
+func (d *DAL) compress(n *Node) ([]byte, error) {
+	buf := make([]byte, n.size()+1)
+	if err := n.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return d.compressor.Compress(nil, buf)
+}
+
+// overpopulated returns true if the node should be split. Without a compressor this is decided by Overpopulated,
+// otherwise by the size of the compressed node.
+func (d *DAL) overpopulated(n *Node) bool {
+	if d.compressor == nil || !n.Overpopulated() {
+		return n.Overpopulated()
+	}
+	if n.size() >= d.maxCompressedNodeSize() {
+		return true
+	}
+	compressed, err := d.compress(n)
+	if err != nil {
+		return true
+	}
+	return float64(pageHeaderSize+2+len(compressed)) >= float64(d.pageSize)*MaxNodeSizeMultiplier
+}
+
+// decompress returns the node held by the compressed page.
+func (d *DAL) decompress(p *page) ([]byte, error) {
+	if d.compressor == nil {
+		return nil, ErrNoCompressor
+	}
+	head := deserializer{
+		cursor:    pageHeaderSize,
+		buffer:    p.data,
+		malformed: ErrMalformedPage,
+	}
+	compressed := head.Bytes(int(head.Uint16()))
+	if head.err != nil {
+		return nil, head.err
+	}
+	buf, err := d.compressor.Decompress(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedPage, err)
+	}
+	if len(buf) > d.maxCompressedNodeSize() {
+		return nil, fmt.Errorf("%w: compressed node of %d bytes exceeds limit of %d bytes", ErrMalformedPage, len(buf),
+			d.maxCompressedNodeSize())
+	}
+	return buf, nil
+}
@@ -0,0 +1,110 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestDAL_Compression(t *testing.T) {
+	const items = 2000
+	event := func(random *rand.Rand, i int) []byte {
+		return []byte(fmt.Sprintf(`{"type":"relationship_created","namespace":"documents","object":"doc_%d",`+
+			`"relation":"viewer","subject":"user:%d","version":%d}`, i, i%50, i))
+	}
+	noise := func(random *rand.Rand, i int) []byte {
+		value := make([]byte, 100)
+		random.Read(value)
+		return value
+	}
+	matrix := []struct {
+		name  string
+		value func(random *rand.Rand, i int) []byte
+		// ratio is the largest fraction of the pages of an uncompressed file that the compressed file may occupy
+		ratio float64
+	}{
+		{
+			name:  "given compressible values",
+			value: event,
+			ratio: 0.5,
+		},
+		{
+			name:  "given incompressible values",
+			value: noise,
+			ratio: 1.1,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			pages := make([]uint64, 2)
+			for i, opts := range [][]Option{nil, {WithCompressor(Snappy())}} {
+				random := rand.New(rand.NewSource(1))
+				ds := &file{}
+				d, err := New(ds, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				c, err := d.CreateCollection("events")
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := make(map[string][]byte, items)
+				for j := 0; j < items; j++ {
+					key, value := fmt.Sprintf("event_%05d", j), m.value(random, j)
+					if err := c.Insert([]byte(key), value); err != nil {
+						t.Fatal(err)
+					}
+					expected[key] = value
+				}
+				if err := d.Close(); err != nil {
+					t.Fatal(err)
+				}
+				pages[i] = d.Stats().Pages
+				if d, err = Load(ds, opts...); err != nil {
+					t.Fatal(err)
+				}
+				if c, err = d.Collection("events"); err != nil {
+					t.Fatal(err)
+				}
+				for key, value := range expected {
+					item, err := c.Find([]byte(key))
+					if err != nil {
+						t.Fatalf("%s: %v", key, err)
+					}
+					if string(item.value) != string(value) {
+						t.Fatalf("%s: got %q; want %q", key, item.value, value)
+					}
+				}
+			}
+			if ratio := float64(pages[1]) / float64(pages[0]); ratio > m.ratio {
+				t.Fatalf("got %d compressed pages for %d uncompressed pages; want at most %.0f%%", pages[1], pages[0],
+					m.ratio*100)
+			}
+		})
+	}
+}
+
+func TestDAL_Compression_NoCompressor(t *testing.T) {
+	ds := &file{}
+	d, err := New(ds, WithCompressor(Snappy()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Insert([]byte("event_1"), []byte("relationship_created")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = Load(ds); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Collection("events"); !errors.Is(err, ErrNoCompressor) {
+		t.Fatalf("got %v; want %v", err, ErrNoCompressor)
+	}
+}
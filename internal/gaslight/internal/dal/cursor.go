@@ -0,0 +1,95 @@
+package dal
+
+import (
+	"bytes"
+)
+
+// ForEach calls fn for every item in the collection in ascending key order. Iteration stops at the first error returned
+// by fn, which is then returned by ForEach. The collection must not be modified from within fn.
+func (c *Collection) ForEach(fn func(key, value []byte) error) error {
+	return c.dal.view(func() error {
+		return c.forEach(forwards, fn)
+	})
+}
+
+// ForEachReverse is like ForEach but calls fn for every item in descending key order.
+func (c *Collection) ForEachReverse(fn func(key, value []byte) error) error {
+	return c.dal.view(func() error {
+		return c.forEach(backwards, fn)
+	})
+}
+
+// ForEachPrefix calls fn for every item whose key starts with the provided prefix in ascending key order. Only the path
+// down to the first matching leaf and the leaves holding matching items are read.
+func (c *Collection) ForEachPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	return c.dal.view(func() error {
+		return c.forEachPrefix(prefix, fn)
+	})
+}
+
+func (c *Collection) forEachPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	node, err := c.leaf(prefix)
+	if err != nil {
+		return err
+	}
+	for {
+		for _, item := range node.items {
+			if Compare(item.key, prefix) < 0 {
+				continue
+			}
+			if !bytes.HasPrefix(item.key, prefix) {
+				return nil
+			}
+			if err := fn(item.key, item.value); err != nil {
+				return err
+			}
+		}
+		if node.next == EmptyNodeID {
+			return nil
+		}
+		if node, err = c.load(node.next); err != nil {
+			return err
+		}
+	}
+}
+
+// forEach descends to the first or last leaf of the tree, depending on the direction, and from there follows the links
+// between the leaves. Internal nodes are only read on the way down to the first leaf.
+func (c *Collection) forEach(direction int, fn func(key, value []byte) error) error {
+	node, err := c.load(c.root)
+	if err != nil {
+		return err
+	}
+	for node.Parent() {
+		child := node.children[0]
+		if direction == backwards {
+			child = node.children[len(node.children)-1]
+		}
+		if node, err = c.load(child); err != nil {
+			return err
+		}
+	}
+	for {
+		next := node.next
+		if direction == forwards {
+			for _, item := range node.items {
+				if err := fn(item.key, item.value); err != nil {
+					return err
+				}
+			}
+		} else {
+			next = node.prev
+			for i := len(node.items) - 1; i >= 0; i-- {
+				if err := fn(node.items[i].key, node.items[i].value); err != nil {
+					return err
+				}
+			}
+		}
+		if next == EmptyNodeID {
+			return nil
+		}
+		if node, err = c.load(next); err != nil {
+			return err
+		}
+	}
+}
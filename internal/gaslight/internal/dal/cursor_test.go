@@ -0,0 +1,145 @@
+package dal
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollection_ForEach(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"principals", "events", "namespaces"} {
+		if _, err := d.CreateCollection(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := d.Collections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[events namespaces principals]" {
+		t.Fatalf("got %v; want collections in ascending order", names)
+	}
+	c, err := d.Collection("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range rand.New(rand.NewSource(3)).Perm(2000) {
+		if err := c.Insert([]byte(fmt.Sprintf("key_%04d", k)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matrix := []struct {
+		name    string
+		forEach func(fn func(key, value []byte) error) error
+		order   int
+	}{
+		{
+			name:    "given forwards iteration",
+			forEach: c.ForEach,
+			order:   -1,
+		},
+		{
+			name:    "given backwards iteration",
+			forEach: c.ForEachReverse,
+			order:   1,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			var previous []byte
+			var count int
+			err := m.forEach(func(key, _ []byte) error {
+				if previous != nil && Compare(previous, key) != m.order {
+					return fmt.Errorf("got %s after %s", key, previous)
+				}
+				previous = key
+				count++
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 2000 {
+				t.Fatalf("got %d items; want %d", count, 2000)
+			}
+		})
+	}
+}
+
+func TestCollection_ForEachPrefix(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range rand.New(rand.NewSource(4)).Perm(3000) {
+		key := fmt.Sprintf("entity_%02d/%04d", k%30, k)
+		if err := c.Insert([]byte(key), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matrix := []struct {
+		name     string
+		prefix   string
+		expected int
+	}{
+		{
+			name:     "given prefix of many leaves",
+			prefix:   "entity_1",
+			expected: 1000,
+		},
+		{
+			name:     "given prefix of single entity",
+			prefix:   "entity_07/",
+			expected: 100,
+		},
+		{
+			name:     "given prefix without matches",
+			prefix:   "entity_07/x",
+			expected: 0,
+		},
+		{
+			name:     "given empty prefix",
+			prefix:   "",
+			expected: 3000,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			var count int
+			err := c.ForEachPrefix([]byte(m.prefix), func(key, _ []byte) error {
+				if !strings.HasPrefix(string(key), m.prefix) {
+					return fmt.Errorf("got %s; want prefix %s", key, m.prefix)
+				}
+				count++
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != m.expected {
+				t.Fatalf("got %d items; want %d", count, m.expected)
+			}
+		})
+	}
+}
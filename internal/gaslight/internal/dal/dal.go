@@ -2,25 +2,75 @@ package dal
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
 const (
 	metadataPageID = 0
 	forwards       = 1
 	backwards      = -1
+	// pageHeaderSize is the size of the header at the start of every page, made up of the id of the transaction which
+	// last wrote the page and a byte of flags. The remainder of the page is passed to serializers.
+	pageHeaderSize = 8 + 1
 )
 
+// Serializer writes a value to a buffer, usually the data of a page. ErrPageOverflow must be returned if the value
+// does not fit in the buffer.
 type Serializer interface {
-	Serialize([]byte)
+	Serialize([]byte) error
 }
 
+// Deserializer reads a value from a buffer, usually the data of a page. An error wrapping ErrMalformedPage must be
+// returned if the buffer does not hold a valid value.
 type Deserializer interface {
-	Deserialize([]byte)
+	Deserialize([]byte) error
 }
 
-func New(ds Datasource) (*DAL, error) {
+var (
+	ErrReadOnly      = errors.New("read only")
+	ErrPageOverflow  = errors.New("page overflow")
+	ErrMalformedPage = errors.New("malformed page")
+	ErrMalformedNode = fmt.Errorf("%w: malformed node", ErrMalformedPage)
+)
+
+// Option configures optional behaviour of a DAL.
+type Option func(*DAL)
+
+// WithLog makes the DAL write every committed transaction to the provided write-ahead log before writing its pages to
+// the datasource. The log is replayed when the DAL is loaded, which makes commits atomic.
+func WithLog(log Log) Option {
+	return func(d *DAL) {
+		d.wal = &wal{
+			log:      log,
+			pageSize: d.pageSize,
+		}
+	}
+}
+
+// ReadOnly makes the DAL reject every write with ErrReadOnly. Commits received from another DAL may still be applied
+// through Apply, which is how followers of a replicated DAL are kept up to date.
+func ReadOnly() Option {
+	return func(d *DAL) {
+		d.readOnly = true
+	}
+}
+
+// Frozen makes the DAL read only, like ReadOnly, and keeps it from writing to its datasource and write-ahead log
+// altogether. Load holds the commits found in the log in memory rather than replaying them, which makes the DAL observe
+// the last commit without checkpointing the log. Apply returns ErrReadOnly and Close leaves the datasource and log as
+// they are.
+func Frozen() Option {
+	return func(d *DAL) {
+		d.readOnly = true
+		d.frozen = true
+	}
+}
+
+func New(ds Datasource, opts ...Option) (*DAL, error) {
 	dal := &DAL{
 		ds: ds,
 		freelist: &freelist{
@@ -29,24 +79,64 @@ func New(ds Datasource) (*DAL, error) {
 		metadata: &metadata{},
 		pageSize: uint64(os.Getpagesize()),
 	}
+	for _, opt := range opts {
+		opt(dal)
+	}
+	if dal.wal != nil {
+		// Anything left in the log belongs to a previous file and must not be replayed on top of the new one
+		if err := dal.checkpoint(); err != nil {
+			return nil, err
+		}
+	}
 	id := dal.freelist.id()
 	if err := dal.Serialize(dal.freelist, id); err != nil {
 		return nil, err
 	}
 	dal.metadata.freelist = id
+	// The root collection is a tree of its own which maps the name of every collection in the file to the page
+	// holding the root node of that collection
+	root := &Node{
+		id: dal.freelist.id(),
+	}
+	if err := dal.Serialize(root, root.id); err != nil {
+		return nil, err
+	}
+	dal.metadata.root = root.id
 	if err := dal.Serialize(dal.metadata, metadataPageID); err != nil {
 		return nil, err
 	}
+	// The pages above are written directly to the datasource rather than through the log, which means that they must
+	// be synced to survive a crash before the first commit
+	if err := dal.fsync(dal.ds); err != nil {
+		return nil, err
+	}
+	dal.collections = &Collection{
+		root: root.id,
+		dal:  dal,
+	}
 	return dal, nil
 }
 
-func Load(ds Datasource) (*DAL, error) {
+func Load(ds Datasource, opts ...Option) (*DAL, error) {
 	dal := &DAL{
 		ds:       ds,
 		freelist: &freelist{},
 		metadata: &metadata{},
 		pageSize: uint64(os.Getpagesize()),
 	}
+	for _, opt := range opts {
+		opt(dal)
+	}
+	switch {
+	case dal.wal != nil && dal.frozen:
+		if err := dal.overlay(); err != nil {
+			return nil, err
+		}
+	case dal.wal != nil:
+		if err := dal.recover(); err != nil {
+			return nil, err
+		}
+	}
 	err := dal.Deserialize(dal.metadata, metadataPageID)
 	if err != nil {
 		return nil, err
@@ -55,6 +145,10 @@ func Load(ds Datasource) (*DAL, error) {
 	if err != nil {
 		return nil, err
 	}
+	dal.collections = &Collection{
+		root: dal.metadata.root,
+		dal:  dal,
+	}
 	return dal, nil
 }
 
@@ -64,10 +158,28 @@ type Datasource interface {
 }
 
 type DAL struct {
+	// mu is held by every exported operation for its full duration, which serializes transactions and prevents reads
+	// from observing pages of a transaction that has yet to commit.
+	mu sync.Mutex
 	ds Datasource
 	*freelist
 	*metadata
 	pageSize uint64
+	// collections is the root collection, which holds the serialized headers of all other collections keyed by name.
+	collections *Collection
+	counters    counters
+	tx          *tx
+	wal         *wal
+	readOnly    bool
+	frozen      bool
+	subscribers map[*Subscription]struct{}
+	durability  durability
+	group       group
+	compressor  Compressor
+	// pending holds the commits which are in the write-ahead log but have yet to be written to the datasource, in the
+	// order they were committed, and dirty the latest version of every page written by them.
+	pending []Commit
+	dirty   map[uint64][]byte
 }
 
 type freelist struct {
@@ -81,9 +193,7 @@ func (f *freelist) id() uint64 {
 		return f.allocated
 	}
 	next := f.released[len(f.released)-1]
-	released := make([]uint64, 0, len(f.released)-1)
-	copy(released, f.released[:len(f.released)-1])
-	f.released = released
+	f.released = f.released[:len(f.released)-1]
 	return next
 }
 
@@ -91,40 +201,63 @@ func (f *freelist) release(id uint64) {
 	f.released = append(f.released, id)
 }
 
-func (f *freelist) Serialize(buf []byte) {
-	pos := 0
-	binary.LittleEndian.PutUint64(buf[pos:], f.allocated)
-	pos += 8
-	binary.LittleEndian.PutUint64(buf[pos:], uint64(len(f.released)))
-	pos += 8
+func (f *freelist) Serialize(buf []byte) error {
+	head := serializer{
+		direction: forwards,
+		buffer:    buf,
+	}
+	head.PutUint64(f.allocated)
+	head.PutUint64(uint64(len(f.released)))
 	for _, id := range f.released {
-		binary.LittleEndian.PutUint64(buf[pos:], id)
-		pos += 8
+		head.PutUint64(id)
 	}
+	return head.err
 }
 
-func (f *freelist) Deserialize(buf []byte) {
-	pos := 0
-	f.allocated = binary.LittleEndian.Uint64(buf[pos:])
-	pos += 8
-	f.released = make([]uint64, 0, binary.LittleEndian.Uint64(buf[pos:]))
-	pos += 8
-	for i := 0; i < cap(f.released); i++ {
-		f.released = append(f.released, binary.LittleEndian.Uint64(buf[pos:]))
-		pos += 8
+func (f *freelist) Deserialize(buf []byte) error {
+	head := deserializer{
+		buffer:    buf,
+		malformed: ErrMalformedPage,
+	}
+	f.allocated = head.Uint64()
+	count := head.Uint64()
+	if count > uint64(len(buf)/8) {
+		return fmt.Errorf("%w: freelist of %d pages exceeds page", ErrMalformedPage, count)
 	}
+	f.released = make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		f.released = append(f.released, head.Uint64())
+	}
+	return head.err
 }
 
 type metadata struct {
 	freelist uint64
+	root     uint64
+	// txid is the identifier of the last committed transaction.
+	txid uint64
 }
 
-func (m *metadata) Serialize(buf []byte) {
-	binary.LittleEndian.PutUint64(buf, m.freelist)
+func (m *metadata) Serialize(buf []byte) error {
+	head := serializer{
+		direction: forwards,
+		buffer:    buf,
+	}
+	head.PutUint64(m.freelist)
+	head.PutUint64(m.root)
+	head.PutUint64(m.txid)
+	return head.err
 }
 
-func (m *metadata) Deserialize(buf []byte) {
-	m.freelist = binary.LittleEndian.Uint64(buf)
+func (m *metadata) Deserialize(buf []byte) error {
+	head := deserializer{
+		buffer:    buf,
+		malformed: ErrMalformedPage,
+	}
+	m.freelist = head.Uint64()
+	m.root = head.Uint64()
+	m.txid = head.Uint64()
+	return head.err
 }
 
 type page struct {
@@ -132,40 +265,90 @@ type page struct {
 	data []byte
 }
 
+// txid returns the id of the transaction which last wrote the page.
+func (p *page) txid() uint64 {
+	return binary.LittleEndian.Uint64(p.data)
+}
+
+func (p *page) setTxID(txid uint64) {
+	binary.LittleEndian.PutUint64(p.data, txid)
+}
+
 func (d *DAL) allocate() *page {
 	return &page{
 		data: make([]byte, d.pageSize),
 	}
 }
 
+// maxItemSize returns the largest number of bytes an item may occupy within a node. Items are limited to a quarter of
+// a page so that an overpopulated node always holds enough items to be split into two nodes that fit within a page.
+func (d *DAL) maxItemSize() int {
+	return int(d.pageSize) / 4
+}
+
 func (d *DAL) read(id uint64) (*page, error) {
 	p := d.allocate()
+	if data, ok := d.tx.page(id); ok {
+		copy(p.data, data)
+		return p, nil
+	}
+	if data, ok := d.dirty[id]; ok {
+		copy(p.data, data)
+		p.id = id
+		d.tx.cache(p)
+		return p, nil
+	}
 	offset := id * d.pageSize
 	_, err := d.ds.Seek(int64(offset), io.SeekStart)
 	if err != nil {
 		return nil, err
 	}
-	_, err = d.ds.Read(p.data)
+	n, err := d.ds.Read(p.data)
+	d.counters.read(n)
 	if err != nil {
 		return nil, err
 	}
+	p.id = id
+	d.tx.cache(p)
 	return p, nil
 }
 
 func (d *DAL) write(p *page) error {
+	if d.tx != nil {
+		d.tx.stage(p)
+		return nil
+	}
 	offset := p.id * d.pageSize
 	_, err := d.ds.Seek(int64(offset), io.SeekStart)
 	if err != nil {
 		return err
 	}
-	_, err = d.ds.Write(p.data)
+	n, err := d.ds.Write(p.data)
+	d.counters.write(n)
 	return err
 }
 
 func (d *DAL) Serialize(serializable Serializer, id uint64) error {
 	p := d.allocate()
 	p.id = id
-	serializable.Serialize(p.data)
+	// Pages written within a transaction are stamped with the id of the transaction once it commits
+	p.setTxID(d.metadata.txid)
+	if node, ok := serializable.(*Node); ok && d.compressor != nil {
+		compressed, err := d.compress(node)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", id, err)
+		}
+		// Nodes of items that do not compress are written uncompressed, in which case they must fit the page as is
+		if pageHeaderSize+2+len(compressed) <= len(p.data) {
+			p.setFlags(pageCompressed)
+			binary.LittleEndian.PutUint16(p.data[pageHeaderSize:], uint16(len(compressed)))
+			copy(p.data[pageHeaderSize+2:], compressed)
+			return d.write(p)
+		}
+	}
+	if err := serializable.Serialize(p.data[pageHeaderSize:]); err != nil {
+		return fmt.Errorf("page %d: %w", id, err)
+	}
 	err := d.write(p)
 	if err != nil {
 		return err
@@ -178,32 +361,83 @@ func (d *DAL) Deserialize(deserializer Deserializer, id uint64) error {
 	if err != nil {
 		return err
 	}
-	deserializer.Deserialize(p.data)
+	buf, err := d.payload(p)
+	if err != nil {
+		return fmt.Errorf("page %d: %w", id, err)
+	}
+	if err := deserializer.Deserialize(buf); err != nil {
+		return fmt.Errorf("page %d: %w", id, err)
+	}
 	return nil
 }
 
+// payload returns the part of the page following the page header, decompressed if the page is compressed.
+func (d *DAL) payload(p *page) ([]byte, error) {
+	if p.flags()&pageCompressed != 0 {
+		return d.decompress(p)
+	}
+	return p.data[pageHeaderSize:], nil
+}
+
 func (d *DAL) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if d.ds == nil {
 		return nil
 	}
+	for s := range d.subscribers {
+		s.close()
+	}
+	if d.frozen {
+		return nil
+	}
+	if d.readOnly {
+		return d.checkpoint()
+	}
+	// Held commits are written first as they would otherwise overwrite the pages written below
+	if err := d.drain(); err != nil {
+		return err
+	}
 	if err := d.Serialize(d.freelist, d.metadata.freelist); err != nil {
 		return err
 	}
 	if err := d.Serialize(d.metadata, metadataPageID); err != nil {
 		return err
 	}
-	return nil
+	return d.checkpoint()
 }
 
 // serializer is a small utility that aids in serializing complex values to byte slices, it keeps track of the current
-// cursor being written to and which direction the cursor should move after each write (forwards or backwards).
+// cursor being written to and which direction the cursor should move after each write (forwards or backwards). Writes
+// that do not fit within the buffer are skipped and cause err to be set to ErrPageOverflow.
 type serializer struct {
 	cursor    int
 	direction int
 	buffer    []byte
+	err       error
+}
+
+// fits positions the cursor for a write of n bytes and returns true if the write fits within the buffer.
+func (s *serializer) fits(n int) bool {
+	if s.err != nil {
+		return false
+	}
+	start := s.cursor
+	if s.direction < 0 {
+		start -= n
+	}
+	if start < 0 || start+n > len(s.buffer) {
+		s.err = fmt.Errorf("%w: write of %d bytes at offset %d exceeds buffer of %d bytes", ErrPageOverflow, n, start,
+			len(s.buffer))
+		return false
+	}
+	return true
 }
 
 func (s *serializer) PutUint64(x uint64) {
+	if !s.fits(8) {
+		return
+	}
 	if s.direction < 0 {
 		s.cursor += 8 * s.direction
 	}
@@ -214,6 +448,9 @@ func (s *serializer) PutUint64(x uint64) {
 }
 
 func (s *serializer) PutUint16(x uint16) {
+	if !s.fits(2) {
+		return
+	}
 	if s.direction < 0 {
 		s.cursor += 2 * s.direction
 	}
@@ -224,6 +461,9 @@ func (s *serializer) PutUint16(x uint16) {
 }
 
 func (s *serializer) PutUint8(x uint8) {
+	if !s.fits(1) {
+		return
+	}
 	if s.direction < 0 {
 		s.cursor += 1 * s.direction
 	}
@@ -234,6 +474,9 @@ func (s *serializer) PutUint8(x uint8) {
 }
 
 func (s *serializer) Put(bytes []byte) {
+	if !s.fits(len(bytes)) {
+		return
+	}
 	if s.direction < 0 {
 		s.cursor += len(bytes) * s.direction
 	}
@@ -242,3 +485,63 @@ func (s *serializer) Put(bytes []byte) {
 		s.cursor += len(bytes) * s.direction
 	}
 }
+
+// deserializer is the counterpart of serializer, it reads values from a byte slice while moving its cursor forwards.
+// Reads outside the buffer return zero values and cause err to be set, wrapping the malformed error.
+type deserializer struct {
+	cursor    int
+	buffer    []byte
+	malformed error
+	err       error
+}
+
+// has returns true if n bytes can be read at the cursor.
+func (d *deserializer) has(n int) bool {
+	if d.err != nil {
+		return false
+	}
+	if n < 0 || d.cursor < 0 || d.cursor+n > len(d.buffer) {
+		d.err = fmt.Errorf("%w: read of %d bytes at offset %d exceeds buffer of %d bytes", d.malformed, n, d.cursor,
+			len(d.buffer))
+		return false
+	}
+	return true
+}
+
+func (d *deserializer) Uint64() uint64 {
+	if !d.has(8) {
+		return 0
+	}
+	x := binary.LittleEndian.Uint64(d.buffer[d.cursor:])
+	d.cursor += 8
+	return x
+}
+
+func (d *deserializer) Uint16() uint16 {
+	if !d.has(2) {
+		return 0
+	}
+	x := binary.LittleEndian.Uint16(d.buffer[d.cursor:])
+	d.cursor += 2
+	return x
+}
+
+func (d *deserializer) Uint8() uint8 {
+	if !d.has(1) {
+		return 0
+	}
+	x := d.buffer[d.cursor]
+	d.cursor += 1
+	return x
+}
+
+// Bytes returns a copy of the next n bytes.
+func (d *deserializer) Bytes(n int) []byte {
+	if !d.has(n) {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.buffer[d.cursor:])
+	d.cursor += n
+	return b
+}
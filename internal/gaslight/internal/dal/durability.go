@@ -0,0 +1,179 @@
+package dal
+
+import (
+	"sync"
+	"time"
+)
+
+// Syncer is implemented by datasources and logs which buffer writes, such as *os.File. Sync must not return until every
+// write made before it was called is stored durably. Datasources and logs which do not implement Syncer are never
+// synced.
+type Syncer interface {
+	Sync() error
+}
+
+type durability int
+
+const (
+	syncAlways durability = iota
+	syncGroupCommit
+	syncNone
+)
+
+// SyncAlways makes every commit sync the write-ahead log, or the datasource if there is no log, before it returns. This
+// is the default.
+func SyncAlways() Option {
+	return func(d *DAL) {
+		d.durability = syncAlways
+	}
+}
+
+// SyncGroupCommit makes commits wait for a sync shared with other concurrently committing transactions. The sync is
+// started at most latency after the first commit waiting for it, and covers every commit made until it is started. The
+// lock of the DAL is not held while waiting, which means that a commit is visible to other transactions, and published
+// to subscribers, before it is durable. The pages of a commit are written to the datasource once the sync completes.
+func SyncGroupCommit(latency time.Duration) Option {
+	return func(d *DAL) {
+		d.durability = syncGroupCommit
+		d.group.latency = latency
+	}
+}
+
+// SyncNone makes commits return without syncing, leaving it to the operating system to decide when the write-ahead log
+// is stored durably. Commits acknowledged shortly before a power failure may be lost, but with a log the file is never
+// left corrupt: the pages of a commit are kept in memory until the log is checkpointed, at which point it is synced
+// before the pages are written to the datasource. Without a log a power failure may leave the file corrupt.
+func SyncNone() Option {
+	return func(d *DAL) {
+		d.durability = syncNone
+	}
+}
+
+// group keeps track of which commits have been synced, allowing transactions committing at the same time to share a
+// single sync.
+type group struct {
+	latency time.Duration
+	mu      sync.Mutex
+	// written is the id of the last transaction written and synced the id of the last transaction known to be durable.
+	written uint64
+	synced  uint64
+	// syncing is closed once the sync in progress completes, it is nil while no sync is in progress.
+	syncing chan struct{}
+	// err is the error returned by a failed sync. Once a sync fails it is unknown which writes were lost, which is why
+	// every later commit fails with the same error.
+	err error
+}
+
+// commit records that the transaction with the provided id has been written.
+func (g *group) commit(txid uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.written = max(g.written, txid)
+}
+
+// advance records that every transaction up to and including the one with the provided id is durable.
+func (g *group) advance(txid uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.synced = max(g.synced, txid)
+}
+
+// wait returns once the transaction with the provided id is durable. The first caller finding no sync in progress
+// becomes the leader of the next sync, waiting for latency to pass before calling sync with the id of the last
+// transaction written by then. Other callers wait for the sync in progress, and lead the next one if it did not cover
+// them.
+func (g *group) wait(txid uint64, sync func(txid uint64) error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.err == nil && g.synced < txid {
+		if g.syncing != nil {
+			syncing := g.syncing
+			g.mu.Unlock()
+			<-syncing
+			g.mu.Lock()
+			continue
+		}
+		syncing := make(chan struct{})
+		g.syncing = syncing
+		g.mu.Unlock()
+		time.Sleep(g.latency)
+		g.mu.Lock()
+		target := g.written
+		g.mu.Unlock()
+		err := sync(target)
+		g.mu.Lock()
+		if err != nil {
+			g.err = err
+		}
+		g.synced = max(g.synced, target)
+		g.syncing = nil
+		close(syncing)
+	}
+	return g.err
+}
+
+// fsync syncs the provided datasource or log if it implements Syncer.
+func (d *DAL) fsync(ds any) error {
+	s, ok := ds.(Syncer)
+	if !ok {
+		return nil
+	}
+	d.counters.syncs.Add(1)
+	return s.Sync()
+}
+
+// sync makes every commit written so far durable by syncing the write-ahead log, which holds the commits until they are
+// checkpointed, or the datasource if there is no log.
+func (d *DAL) sync() error {
+	if d.wal != nil {
+		return d.fsync(d.wal.log)
+	}
+	return d.fsync(d.ds)
+}
+
+// durable waits for the transaction with the provided id to be synced according to the durability of the DAL. It must
+// be called without holding the lock of the DAL.
+func (d *DAL) durable(txid uint64) error {
+	if d.durability != syncGroupCommit || txid == 0 {
+		return nil
+	}
+	return d.group.wait(txid, d.settle)
+}
+
+// settle syncs the write-ahead log, or the datasource if there is no log, and then writes the held commits up to and
+// including the one with the provided id to the datasource. It must be called without holding the lock of the DAL.
+func (d *DAL) settle(txid uint64) error {
+	if err := d.sync(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeback(txid)
+}
+
+// drain syncs the write-ahead log and writes every held commit to the datasource.
+func (d *DAL) drain() error {
+	if len(d.pending) == 0 {
+		return nil
+	}
+	if err := d.sync(); err != nil {
+		return err
+	}
+	return d.writeback(d.metadata.txid)
+}
+
+// checkpoint writes every held commit to the datasource and syncs it, which makes every commit in the write-ahead log
+// durable without it, and then empties the log.
+func (d *DAL) checkpoint() error {
+	if err := d.drain(); err != nil {
+		return err
+	}
+	if err := d.fsync(d.ds); err != nil {
+		return err
+	}
+	if err := d.wal.checkpoint(); err != nil {
+		return err
+	}
+	d.group.advance(d.metadata.txid)
+	return nil
+}
@@ -0,0 +1,148 @@
+package dal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// open creates a DAL backed by a data file and a write-ahead log in a temporary directory.
+func open(tb testing.TB, opts ...Option) *DAL {
+	tb.Helper()
+	dir := tb.TempDir()
+	file, err := os.OpenFile(filepath.Join(dir, "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = file.Close() })
+	log, err := os.OpenFile(filepath.Join(dir, "gaslight.db-wal"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = log.Close() })
+	d, err := New(file, append([]Option{WithLog(log)}, opts...)...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return d
+}
+
+func TestDAL_Durability(t *testing.T) {
+	const writers, inserts = 16, 20
+	matrix := []struct {
+		name   string
+		option Option
+		valid  func(syncs uint64) bool
+	}{
+		{
+			name:   "given sync always",
+			option: SyncAlways(),
+			valid: func(syncs uint64) bool {
+				return syncs >= writers*inserts
+			},
+		},
+		{
+			name:   "given group commit",
+			option: SyncGroupCommit(2 * time.Millisecond),
+			valid: func(syncs uint64) bool {
+				return syncs > 0 && syncs < writers*inserts
+			},
+		},
+		{
+			name:   "given sync none",
+			option: SyncNone(),
+			valid: func(syncs uint64) bool {
+				return syncs == 0
+			},
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			d := open(t, m.option)
+			c, err := d.CreateCollection("events")
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := d.Stats().Syncs
+			var wg sync.WaitGroup
+			errs := make(chan error, writers)
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < inserts; i++ {
+						if err := c.Insert([]byte(fmt.Sprintf("key_%02d_%02d", w, i)), []byte("value")); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatal(err)
+			}
+			if syncs := d.Stats().Syncs - before; !m.valid(syncs) {
+				t.Fatalf("got %d syncs for %d commits", syncs, writers*inserts)
+			}
+			stats, err := c.Stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Items != writers*inserts {
+				t.Fatalf("got %d items; want %d", stats.Items, writers*inserts)
+			}
+		})
+	}
+}
+
+func BenchmarkDAL_Durability(b *testing.B) {
+	matrix := []struct {
+		name   string
+		option Option
+	}{
+		{
+			name:   "SyncAlways",
+			option: SyncAlways(),
+		},
+		{
+			name:   "SyncGroupCommit",
+			option: SyncGroupCommit(100 * time.Microsecond),
+		},
+		{
+			name:   "SyncNone",
+			option: SyncNone(),
+		},
+	}
+	for _, m := range matrix {
+		b.Run(m.name, func(b *testing.B) {
+			d := open(b, m.option)
+			c, err := d.CreateCollection("events")
+			if err != nil {
+				b.Fatal(err)
+			}
+			var mu sync.Mutex
+			var next int
+			before := d.Stats().Syncs
+			b.SetParallelism(8)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					mu.Lock()
+					next++
+					key := []byte(fmt.Sprintf("key_%09d", next))
+					mu.Unlock()
+					if err := c.Insert(key, []byte("value")); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(d.Stats().Syncs-before)/float64(b.N), "syncs/op")
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+)
+
+var (
+	ErrInjectedFault = errors.New("injected fault")
+)
+
+// FaultyDatasource wraps a Datasource, or a Log, to inject the faults a DAL has to survive. Writes are kept in memory
+// until Sync is called, at which point they are written to the wrapped datasource, which makes it possible to simulate
+// a crash by discarding everything written since the last sync. Reads observe the unsynced writes just like they would
+// when reading a file through the page cache of the operating system. Truncate is passed through to the wrapped log
+// immediately, as if it was synced.
+type FaultyDatasource struct {
+	mu      sync.Mutex
+	ds      Datasource
+	offset  int64
+	pending []pendingWrite
+	reads   int
+	writes  int
+	// failRead, failWrite and shortWrite hold the number of the read or write, counting from one, at which the fault is
+	// injected. Zero means that the fault is never injected.
+	failRead   int
+	failWrite  int
+	shortWrite int
+}
+
+type pendingWrite struct {
+	offset int64
+	data   []byte
+}
+
+// NewFaultyDatasource wraps the provided datasource, which is expected to hold the synced content of the file.
+func NewFaultyDatasource(ds Datasource) *FaultyDatasource {
+	return &FaultyDatasource{
+		ds: ds,
+	}
+}
+
+// FailRead makes the nth read from now fail with ErrInjectedFault without reading anything.
+func (f *FaultyDatasource) FailRead(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failRead = f.reads + n
+}
+
+// FailWrite makes the nth write from now fail with ErrInjectedFault without writing anything.
+func (f *FaultyDatasource) FailWrite(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failWrite = f.writes + n
+}
+
+// ShortWrite makes the nth write from now write only the first half of its bytes and fail with io.ErrShortWrite.
+func (f *FaultyDatasource) ShortWrite(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shortWrite = f.writes + n
+}
+
+// Reads and Writes return the number of reads and writes made through the datasource.
+func (f *FaultyDatasource) Reads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads
+}
+
+func (f *FaultyDatasource) Writes() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writes
+}
+
+// Crash simulates a power failure by discarding the writes made since the last sync and resetting every injected
+// fault. If random is not nil every unsynced write is instead either discarded, kept or torn, keeping only a random
+// prefix of it, which is what happens to writes that the disk was working on when the power was lost.
+func (f *FaultyDatasource) Crash(random *rand.Rand) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pending := f.pending
+	f.pending = nil
+	f.offset = 0
+	f.failRead, f.failWrite, f.shortWrite = 0, 0, 0
+	if random == nil {
+		return nil
+	}
+	for _, w := range pending {
+		switch random.Intn(3) {
+		case 0:
+			continue
+		case 1:
+		case 2:
+			w.data = w.data[:random.Intn(len(w.data)+1)]
+		}
+		if err := f.store(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FaultyDatasource) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads++
+	if f.reads == f.failRead {
+		return 0, fmt.Errorf("%w: read %d", ErrInjectedFault, f.reads)
+	}
+	size, err := f.size()
+	if err != nil {
+		return 0, err
+	}
+	if f.offset >= size {
+		return 0, io.EOF
+	}
+	p = p[:min(int64(len(p)), size-f.offset)]
+	clear(p)
+	if _, err := f.ds.Seek(f.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(f.ds, p); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, err
+	}
+	// The unsynced writes are laid on top of the synced content in the order they were made
+	for _, w := range f.pending {
+		start, end := max(w.offset, f.offset), min(w.offset+int64(len(w.data)), f.offset+int64(len(p)))
+		if start < end {
+			copy(p[start-f.offset:end-f.offset], w.data[start-w.offset:end-w.offset])
+		}
+	}
+	f.offset += int64(len(p))
+	return len(p), nil
+}
+
+func (f *FaultyDatasource) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes++
+	if f.writes == f.failWrite {
+		return 0, fmt.Errorf("%w: write %d", ErrInjectedFault, f.writes)
+	}
+	n := len(p)
+	if f.writes == f.shortWrite {
+		n = len(p) / 2
+	}
+	f.pending = append(f.pending, pendingWrite{
+		offset: f.offset,
+		data:   append([]byte(nil), p[:n]...),
+	})
+	f.offset += int64(n)
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+func (f *FaultyDatasource) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+		f.offset = offset
+	case io.SeekCurrent:
+		f.offset += offset
+	case io.SeekEnd:
+		size, err := f.size()
+		if err != nil {
+			return 0, err
+		}
+		f.offset = size + offset
+	}
+	return f.offset, nil
+}
+
+// Sync writes every pending write to the wrapped datasource and syncs it if it implements Syncer.
+func (f *FaultyDatasource) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, w := range f.pending {
+		if err := f.store(w); err != nil {
+			return err
+		}
+	}
+	f.pending = nil
+	if s, ok := f.ds.(Syncer); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Truncate truncates the wrapped datasource, which must implement Log, along with any pending write beyond the size.
+func (f *FaultyDatasource) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	log, ok := f.ds.(Log)
+	if !ok {
+		return errors.New("truncate: wrapped datasource is not a log")
+	}
+	pending := f.pending[:0]
+	for _, w := range f.pending {
+		if w.offset >= size {
+			continue
+		}
+		w.data = w.data[:min(int64(len(w.data)), size-w.offset)]
+		pending = append(pending, w)
+	}
+	f.pending = pending
+	return log.Truncate(size)
+}
+
+func (f *FaultyDatasource) Close() error {
+	return f.ds.Close()
+}
+
+// size returns the size of the file including unsynced writes.
+func (f *FaultyDatasource) size() (int64, error) {
+	size, err := f.ds.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	for _, w := range f.pending {
+		size = max(size, w.offset+int64(len(w.data)))
+	}
+	return size, nil
+}
+
+func (f *FaultyDatasource) store(w pendingWrite) error {
+	if _, err := f.ds.Seek(w.offset, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := f.ds.Write(w.data)
+	return err
+}
@@ -0,0 +1,288 @@
+package dal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"testing"
+)
+
+func TestFaultyDatasource(t *testing.T) {
+	matrix := []struct {
+		name     string
+		fault    func(f *FaultyDatasource)
+		expected []byte
+		err      error
+	}{
+		{
+			name:     "given no fault",
+			fault:    func(f *FaultyDatasource) {},
+			expected: []byte("synced-unsynced"),
+		},
+		{
+			name: "given failed write",
+			fault: func(f *FaultyDatasource) {
+				f.FailWrite(1)
+			},
+			expected: []byte("synced"),
+			err:      ErrInjectedFault,
+		},
+		{
+			name: "given short write",
+			fault: func(f *FaultyDatasource) {
+				f.ShortWrite(1)
+			},
+			expected: []byte("synced-uns"),
+			err:      io.ErrShortWrite,
+		},
+		{
+			name: "given failed read",
+			fault: func(f *FaultyDatasource) {
+				f.FailRead(1)
+			},
+			err: ErrInjectedFault,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			f := NewFaultyDatasource(&file{})
+			if _, err := f.Write([]byte("synced")); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Sync(); err != nil {
+				t.Fatal(err)
+			}
+			m.fault(f)
+			_, werr := f.Write([]byte("-unsynced"))
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 64)
+			n, rerr := f.Read(buf)
+			if err := errors.Join(werr, rerr); !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if !bytes.Equal(buf[:n], m.expected) {
+				t.Fatalf("got %q; want %q", buf[:n], m.expected)
+			}
+			// Crashing discards everything written since the sync
+			if err := f.Crash(nil); err != nil {
+				t.Fatal(err)
+			}
+			n, err := f.Read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(buf[:n]) != "synced" {
+				t.Fatalf("got %q; want %q", buf[:n], "synced")
+			}
+		})
+	}
+}
+
+// operation is a change made to a collection which the model is updated with once it succeeds.
+type operation struct {
+	key    string
+	value  string
+	delete bool
+}
+
+// TestDAL_Crash repeatedly injects a fault into the datasource or the log of a DAL while applying random changes to a
+// collection, crashes once a change fails, tearing any unsynced writes, and compares the recovered collection with a
+// model of every change that succeeded. The change that failed may or may not have been committed. Durability modes
+// which acknowledge commits before they are durable may lose the latest changes, in which case the recovered
+// collection must match the model as of one of them.
+func TestDAL_Crash(t *testing.T) {
+	matrix := []struct {
+		name    string
+		option  Option
+		durable bool
+	}{
+		{
+			name:    "given sync always",
+			option:  SyncAlways(),
+			durable: true,
+		},
+		{
+			name:    "given group commit",
+			option:  SyncGroupCommit(0),
+			durable: true,
+		},
+		{
+			name:    "given sync none",
+			option:  SyncNone(),
+			durable: false,
+		},
+	}
+	for _, m := range matrix {
+		for seed := int64(1); seed <= 20; seed++ {
+			t.Run(fmt.Sprintf("%s and seed %d", m.name, seed), func(t *testing.T) {
+				random := rand.New(rand.NewSource(seed))
+				ds, log := NewFaultyDatasource(&file{}), NewFaultyDatasource(&file{})
+				d, err := New(ds, WithLog(log), m.option)
+				if err != nil {
+					t.Fatal(err)
+				}
+				c, err := d.CreateCollection("relationships")
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Closing the DAL checkpoints it, which makes the collection survive every crash below regardless of
+				// the durability mode
+				if err := d.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if d, err = Load(ds, WithLog(log), m.option); err != nil {
+					t.Fatal(err)
+				}
+				if c, err = d.Collection("relationships"); err != nil {
+					t.Fatal(err)
+				}
+				model := make(map[string]string)
+				for round := 0; round < 5; round++ {
+					target := []*FaultyDatasource{ds, log}[random.Intn(2)]
+					switch random.Intn(3) {
+					case 0:
+						target.FailRead(1 + random.Intn(500))
+					case 1:
+						target.FailWrite(1 + random.Intn(500))
+					case 2:
+						target.ShortWrite(1 + random.Intn(500))
+					}
+					var failed *operation
+					// changes holds the changes made since the last crash, which may be lost unless commits are durable
+					before, changes := maps.Clone(model), []operation(nil)
+					for i := 0; i < 200 && failed == nil; i++ {
+						op := operation{
+							key:    fmt.Sprintf("key_%04d", random.Intn(1000)),
+							value:  fmt.Sprintf("value_%d_%d", round, i),
+							delete: random.Intn(4) == 0,
+						}
+						if op.delete {
+							err = c.Delete([]byte(op.key))
+						} else {
+							err = c.Insert([]byte(op.key), []byte(op.value))
+						}
+						_, exists := model[op.key]
+						switch {
+						case op.delete && !exists && errors.Is(err, ErrItemNotFound):
+						case err != nil:
+							failed = &op
+						case op.delete:
+							changes = append(changes, op)
+							delete(model, op.key)
+						default:
+							changes = append(changes, op)
+							model[op.key] = op.value
+						}
+					}
+					if err := ds.Crash(random); err != nil {
+						t.Fatal(err)
+					}
+					if err := log.Crash(random); err != nil {
+						t.Fatal(err)
+					}
+					if d, err = Load(ds, WithLog(log), m.option); err != nil {
+						t.Fatalf("round %d: %v", round, err)
+					}
+					if c, err = d.Collection("relationships"); err != nil {
+						t.Fatalf("round %d: %v", round, err)
+					}
+					if m.durable {
+						verify(t, c, model, failed)
+						continue
+					}
+					if failed != nil {
+						changes = append(changes, *failed)
+					}
+					clear(model)
+					maps.Copy(model, verifyPrefix(t, c, before, changes))
+				}
+			})
+		}
+	}
+}
+
+// verify compares the collection with the model and then updates the model with the outcome of the failed operation.
+func verify(t *testing.T, c *Collection, model map[string]string, failed *operation) {
+	t.Helper()
+	found := collect(t, c)
+	if failed != nil {
+		// Whichever outcome the failed operation had is accepted and recorded in the model
+		if value, ok := found[failed.key]; ok && !failed.delete && value == failed.value {
+			model[failed.key] = value
+		}
+		if _, ok := found[failed.key]; !ok && failed.delete {
+			delete(model, failed.key)
+		}
+	}
+	for key, value := range model {
+		if found[key] != value {
+			t.Fatalf("%s: got %q; want %q", key, found[key], value)
+		}
+	}
+	for key := range found {
+		if _, ok := model[key]; !ok {
+			t.Fatalf("%s: got item which was never committed", key)
+		}
+	}
+}
+
+// verifyPrefix compares the collection with the model after applying every prefix of the changes to it, failing unless
+// one of them matches, and returns the matching model.
+func verifyPrefix(t *testing.T, c *Collection, model map[string]string, changes []operation) map[string]string {
+	t.Helper()
+	found := collect(t, c)
+	expected := maps.Clone(model)
+	for i := 0; ; i++ {
+		if maps.Equal(found, expected) {
+			return expected
+		}
+		if i == len(changes) {
+			t.Fatalf("got %d items matching none of the %d changes made since the last crash", len(found), len(changes))
+		}
+		if changes[i].delete {
+			delete(expected, changes[i].key)
+		} else {
+			expected[changes[i].key] = changes[i].value
+		}
+	}
+}
+
+// collect returns every item of the collection after checking that iterating it in either direction and finding each
+// of its items agree.
+func collect(t *testing.T, c *Collection) map[string]string {
+	t.Helper()
+	found := make(map[string]string)
+	err := c.ForEach(func(key, value []byte) error {
+		found[string(key)] = string(value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reversed int
+	err = c.ForEachReverse(func(key, value []byte) error {
+		reversed++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reversed != len(found) {
+		t.Fatalf("got %d items in reverse; want %d", reversed, len(found))
+	}
+	for key, value := range found {
+		item, err := c.Find([]byte(key))
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if string(item.value) != value {
+			t.Fatalf("%s: got %q; want %q", key, item.value, value)
+		}
+	}
+	return found
+}
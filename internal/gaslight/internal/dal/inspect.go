@@ -0,0 +1,113 @@
+package dal
+
+import (
+	"fmt"
+	"io"
+)
+
+// DumpMetadata writes a human-readable description of the metadata and freelist pages to w.
+func (d *DAL) DumpMetadata(w io.Writer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := fmt.Fprintf(w,
+		"metadata (page %d)\n  page size: %d\n  freelist page: %d\n  root collection page: %d\n  transaction: %d\n",
+		metadataPageID, d.pageSize, d.metadata.freelist, d.metadata.root, d.metadata.txid)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "freelist (page %d)\n  allocated: %d\n  released: %v\n",
+		d.metadata.freelist, d.freelist.allocated, d.freelist.released)
+	return err
+}
+
+// DumpPage decodes the page with the provided id as a node and writes a human-readable description of it to w. Pages
+// which do not hold nodes, such as the metadata page, either fail to decode with ErrMalformedNode or decode to garbage.
+func (d *DAL) DumpPage(w io.Writer, id uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p, err := d.read(id)
+	if err != nil {
+		return err
+	}
+	buf, err := d.payload(p)
+	if err != nil {
+		return fmt.Errorf("page %d: %w", id, err)
+	}
+	node := &Node{}
+	if err := node.Deserialize(buf); err != nil {
+		return fmt.Errorf("page %d: %w", id, err)
+	}
+	_, err = fmt.Fprintf(w,
+		"page %d\n  transaction: %d\n  compressed: %t\n  leaf: %t\n  parent: %d\n  prev: %d\n  next: %d\n  items: %d\n",
+		id, p.txid(), p.flags()&pageCompressed != 0, node.Leaf(), node.parent, node.prev, node.next, len(node.items))
+	if err != nil {
+		return err
+	}
+	for i, item := range node.items {
+		if _, err := fmt.Fprintf(w, "    %d: %q = %q\n", i, item.key, item.value); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "  children: %v\n", node.children)
+	return err
+}
+
+// WriteDOT renders the tree of the collection in the Graphviz DOT language, with one record shaped vertex per node. The
+// links between sibling leaves are rendered as dashed edges.
+func (c *Collection) WriteDOT(w io.Writer) error {
+	return c.dal.view(func() error {
+		return c.writeDOT(w)
+	})
+}
+
+func (c *Collection) writeDOT(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "digraph %q {\n  node [shape=record];\n", c.name); err != nil {
+		return err
+	}
+	var walk func(id uint64) error
+	walk = func(id uint64) error {
+		node := &Node{}
+		if err := c.dal.Deserialize(node, id); err != nil {
+			return err
+		}
+		label := fmt.Sprintf("page %d", id)
+		for _, item := range node.items {
+			label += "|" + escapeRecord(fmt.Sprintf("%q", item.key))
+		}
+		if _, err := fmt.Fprintf(w, "  n%d [label=\"%s\"];\n", id, label); err != nil {
+			return err
+		}
+		if node.next != EmptyNodeID {
+			if _, err := fmt.Fprintf(w, "  n%d -> n%d [style=dashed];\n", id, node.next); err != nil {
+				return err
+			}
+		}
+		for _, child := range node.children {
+			if _, err := fmt.Fprintf(w, "  n%d -> n%d;\n", id, child); err != nil {
+				return err
+			}
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(c.root); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// escapeRecord escapes the characters which carry meaning within a Graphviz record label.
+func escapeRecord(s string) string {
+	escaped := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\\', '{', '}', '|', '<', '>', ' ':
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, s[i])
+	}
+	return string(escaped)
+}
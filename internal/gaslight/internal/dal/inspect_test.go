@@ -0,0 +1,47 @@
+package dal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollection_WriteDOT(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("namespaces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Insert([]byte("documents"), []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := c.WriteDOT(buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, `digraph "namespaces" {`) {
+		t.Fatalf("got %s; want digraph named after the collection", out)
+	}
+	if !strings.Contains(out, `\"documents\"`) {
+		t.Fatalf("got %s; want escaped key in record label", out)
+	}
+
+	buf.Reset()
+	if err := d.DumpPage(buf, c.root); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "leaf: true") {
+		t.Fatalf("got %s; want leaf root", buf.String())
+	}
+}
@@ -2,7 +2,7 @@ package dal
 
 import (
 	"bytes"
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -15,13 +15,33 @@ type Item struct {
 	value []byte
 }
 
+// size returns the number of bytes the item occupies within a serialized node, including its offset and lengths.
+func (i *Item) size() int {
+	return len(i.key) + len(i.value) + 6
+}
+
+// Key returns the key under which the item is stored.
+func (i *Item) Key() []byte {
+	return i.key
+}
+
+// Value returns the value stored under the key of the item.
+func (i *Item) Value() []byte {
+	return i.value
+}
+
 func Compare(a, b []byte) int {
 	return bytes.Compare(a, b)
 }
 
+// Node is a node of the B+tree of a collection. Leaves hold the items of the collection and are linked to their
+// siblings through prev and next, which allows the items to be iterated in either direction without visiting any
+// internal node. Internal nodes only hold separator keys, which have no values, to guide lookups towards the leaves.
 type Node struct {
 	id       uint64
 	parent   uint64
+	prev     uint64
+	next     uint64
 	children []uint64
 	items    []*Item
 }
@@ -38,9 +58,24 @@ func (n *Node) Find(key []byte) (*Item, bool) {
 	return nil, false
 }
 
+// index returns the index of the item holding the provided key and true if there is one, otherwise false.
+func (n *Node) index(key []byte) (int, bool) {
+	for i, item := range n.items {
+		if bytes.Equal(item.key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // Child returns the page id of the child which is assigned values under the provided key. See it as a way to find which
 // node should be traversed next in order to find the item for a given key.
 func (n *Node) Child(key []byte) uint64 {
+	return n.children[n.childIndex(key)]
+}
+
+// childIndex returns the index within the children of the node of the child returned by Child.
+func (n *Node) childIndex(key []byte) int {
 	var i int
 	// Find the first index of items where the previous key is not larger than the inserting item
 	for i = 0; i < len(n.items); i++ {
@@ -49,9 +84,9 @@ func (n *Node) Child(key []byte) uint64 {
 		}
 	}
 	if i == len(n.items) {
-		return n.children[len(n.children)-1]
+		return len(n.children) - 1
 	}
-	return n.children[i]
+	return i
 }
 
 // AddChild ensures that the provided index will be the index of the provided child id if successful. The provided index
@@ -68,13 +103,23 @@ func (n *Node) AddChild(index int, id uint64) {
 	}
 }
 
+// InsertChild places the provided child id at the provided index, shifting any children at or after the index one step
+// to the right. Like AddChild the index must not exceed the length of the children slice.
+func (n *Node) InsertChild(index int, id uint64) {
+	if index > len(n.children) {
+		panic("tried to add more than `k+1` child nodes")
+	}
+	n.children = append(n.children, 0)
+	copy(n.children[index+1:], n.children[index:])
+	n.children[index] = id
+}
+
 // Insert inserts the provided item in sorted order amongst the already existing items of the node.
 func (n *Node) Insert(item *Item) int {
 	var i int
 	// Find the first index of items where the previous key is not larger than the inserting item
 	for i = 0; i < len(n.items); i++ {
 		if i == len(n.items) || Compare(item.key, n.items[i].key) < 0 {
-			fmt.Println(Compare(n.items[i].key, item.key))
 			break
 		}
 	}
@@ -90,25 +135,44 @@ func (n *Node) Insert(item *Item) int {
 // Overpopulated returns true if the node currently takes up too much disk space and should be split into more than one
 // node.
 func (n *Node) Overpopulated() bool {
-	var size int
-	size += 1 // leaf page header
-	size += 2 // length page header
+	return float64(n.size()) >= float64(os.Getpagesize())*MaxNodeSizeMultiplier
+}
+
+// nodeHeaderSize is the number of bytes preceding the item offsets of a serialized node, made up of the leaf flag,
+// the parent, the previous and next siblings and the number of items.
+const nodeHeaderSize = 1 + 8 + 8 + 8 + 2
+
+// size returns the number of bytes the node occupies when serialized.
+func (n *Node) size() int {
+	size := nodeHeaderSize
 	for _, item := range n.items {
-		size += len(item.key)
-		size += len(item.value)
-		size += 8 // page id
-		size += 2 // offset
+		size += item.size()
 	}
-	size += 8 // final page id
-	return float64(size) >= float64(os.Getpagesize())*MaxNodeSizeMultiplier
+	size += 8 * len(n.children)
+	return size
 }
 
 // Split creates two nodes from n. The first node will contain items and children from the first half of n and the
-// second node will contain items and children from the second half. The item located directly at the split index is not
-// included in either of the new nodes. The promoted item is instead returned to the caller to be passed into a parent
-// node.
+// second node will contain items and children from the second half. The returned item is the separator to be passed
+// into the parent node. When splitting a leaf the separator is a copy of the key of the first item of the second node,
+// without a value. When splitting an internal node the separator located directly at the split index is moved to the
+// parent and is not included in either of the new nodes.
 func Split(n *Node) (*Node, *Node, *Item) {
 	point := int(float64(len(n.items)) / 2)
+	if n.Leaf() {
+		a := &Node{
+			items: make([]*Item, point, len(n.items)),
+		}
+		copy(a.items, n.items[:point])
+		b := &Node{
+			items: make([]*Item, len(n.items)-point, len(n.items)),
+		}
+		copy(b.items, n.items[point:])
+		promoted := &Item{
+			key: bytes.Clone(b.items[0].key),
+		}
+		return a, b, promoted
+	}
 	promoted := n.items[point]
 	a := &Node{
 		children: make([]uint64, 0, (len(n.items)/2)+1),
@@ -124,10 +188,6 @@ func Split(n *Node) (*Node, *Node, *Item) {
 	for _, item := range n.items[point+1:] {
 		b.Insert(item)
 	}
-	if n.Leaf() {
-		// There are no children to assign to the new nodes, hence why we can immediately return
-		return a, b, promoted
-	}
 	point = int(math.Round(float64(len(n.children)) / 2))
 	for i, child := range n.children[:point] {
 		a.AddChild(i, child)
@@ -148,7 +208,18 @@ func (n *Node) Parent() bool {
 	return len(n.children) > 0
 }
 
-func (n *Node) Serialize(buf []byte) {
+// Serialize writes the node to the provided buffer. Item offsets and children are written from the start of the
+// buffer while the items themselves are written from the end of it, if the two meet then ErrPageOverflow is returned.
+func (n *Node) Serialize(buf []byte) error {
+	if len(buf) > math.MaxUint16+1 {
+		return fmt.Errorf("%w: node offsets cannot address a buffer of %d bytes", ErrPageOverflow, len(buf))
+	}
+	if len(n.items) > math.MaxUint16 {
+		return fmt.Errorf("%w: node of %d items", ErrPageOverflow, len(n.items))
+	}
+	if n.Parent() && len(n.children) != len(n.items)+1 {
+		return fmt.Errorf("%w: node of %d items has %d children", ErrMalformedNode, len(n.items), len(n.children))
+	}
 	head := serializer{
 		direction: forwards,
 		buffer:    buf,
@@ -165,61 +236,99 @@ func (n *Node) Serialize(buf []byte) {
 	}
 	head.PutUint8(leaf)
 	head.PutUint64(n.parent)
+	head.PutUint64(n.prev)
+	head.PutUint64(n.next)
 	head.PutUint16(uint16(len(n.items)))
 
 	for i, item := range n.items {
+		if len(item.key) > math.MaxUint16 || len(item.value) > math.MaxUint16 {
+			return fmt.Errorf("%w: item of %d key bytes and %d value bytes", ErrPageOverflow, len(item.key),
+				len(item.value))
+		}
 		if n.Parent() {
 			head.PutUint64(n.children[i])
 		}
 
-		offset := tail.cursor - len(item.key) - len(item.value) - 2
+		offset := tail.cursor - len(item.key) - len(item.value) - 4
 		head.PutUint16(uint16(offset))
 
 		tail.Put(item.value)
-		tail.PutUint8(uint8(len(item.value)))
+		tail.PutUint16(uint16(len(item.value)))
 		tail.Put(item.key)
-		tail.PutUint8(uint8(len(item.key)))
+		tail.PutUint16(uint16(len(item.key)))
+		if head.err != nil || tail.err != nil {
+			break
+		}
 	}
 
 	if n.Parent() {
 		head.PutUint64(n.children[len(n.children)-1])
 	}
+	if err := errors.Join(head.err, tail.err); err != nil {
+		return err
+	}
+	if head.cursor > tail.cursor {
+		return fmt.Errorf("%w: node of %d bytes exceeds buffer of %d bytes", ErrPageOverflow, n.size(), len(buf))
+	}
+	return nil
 }
 
-func (n *Node) Deserialize(buf []byte) {
-	head := 0
-	leaf := false
-	if buf[head] != byte(0) {
-		leaf = true
+// Deserialize reads the node from the provided buffer. An error wrapping ErrMalformedNode is returned if the buffer
+// does not hold a node, such as when the header or an item offset points outside the buffer.
+func (n *Node) Deserialize(buf []byte) error {
+	head := deserializer{
+		buffer:    buf,
+		malformed: ErrMalformedNode,
+	}
+	var parent bool
+	switch flag := head.Uint8(); flag {
+	case 0:
+		parent = true
+	case 1:
+		parent = false
+	default:
+		if head.err == nil {
+			return fmt.Errorf("%w: invalid leaf flag %d", ErrMalformedNode, flag)
+		}
+	}
+	n.parent = head.Uint64()
+	n.prev = head.Uint64()
+	n.next = head.Uint64()
+	items := int(head.Uint16())
+	if head.err != nil {
+		return head.err
+	}
+	// Every item requires an offset in the header, and a child unless the node is a leaf, so the header must fit
+	// within the buffer before any item is read
+	end := head.cursor + items*2
+	if parent {
+		end += (items + 1) * 8
+	}
+	if end > len(buf) {
+		return fmt.Errorf("%w: header of %d items exceeds buffer of %d bytes", ErrMalformedNode, items, len(buf))
 	}
-	parent := !leaf
-	head += 1
-	n.parent = binary.LittleEndian.Uint64(buf[head : head+8])
-	head += 8
-	items := binary.LittleEndian.Uint16(buf[head : head+2])
-	head += 2
 
 	n.children = make([]uint64, 0, items+1)
 	n.items = make([]*Item, 0, items)
-	for i := 0; i < int(items); i++ {
+	for i := 0; i < items; i++ {
 		if parent {
-			id := binary.LittleEndian.Uint64(buf[head:])
-			n.children = append(n.children, id)
-			head += 8
+			n.children = append(n.children, head.Uint64())
+		}
+		offset := int(head.Uint16())
+		if offset < end {
+			return fmt.Errorf("%w: item %d at offset %d overlaps header", ErrMalformedNode, i, offset)
 		}
-		offset := binary.LittleEndian.Uint16(buf[head:])
-		head += 2
-
-		klen := uint16(buf[offset])
-		offset += 1
-		key := make([]byte, klen)
-		copy(key, buf[offset:offset+klen])
-		offset += klen
 
-		vlen := uint16(buf[offset])
-		offset += 1
-		value := make([]byte, vlen)
-		copy(value, buf[offset:offset+vlen])
+		body := deserializer{
+			cursor:    offset,
+			buffer:    buf,
+			malformed: ErrMalformedNode,
+		}
+		key := body.Bytes(int(body.Uint16()))
+		value := body.Bytes(int(body.Uint16()))
+		if body.err != nil {
+			return fmt.Errorf("item %d: %w", i, body.err)
+		}
 
 		n.items = append(n.items, &Item{
 			key:   key,
@@ -228,7 +337,7 @@ func (n *Node) Deserialize(buf []byte) {
 	}
 
 	if parent {
-		child := binary.LittleEndian.Uint64(buf[head:])
-		n.children = append(n.children, child)
+		n.children = append(n.children, head.Uint64())
 	}
+	return head.err
 }
@@ -2,6 +2,8 @@ package dal
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"testing"
 )
 
@@ -12,6 +14,11 @@ func TestSplit(t *testing.T) {
 			value: []byte("value_" + id),
 		}
 	}
+	separator := func(id string) *Item {
+		return &Item{
+			key: []byte("key_" + id),
+		}
+	}
 	matrix := []struct {
 		name     string
 		node     *Node
@@ -37,11 +44,12 @@ func TestSplit(t *testing.T) {
 				},
 				{
 					items: []*Item{
+						item("three"),
 						item("four"),
 					},
 				},
 			},
-			promoted: item("three"),
+			promoted: separator("three"),
 		},
 		{
 			name: "given even number of items on parent node",
@@ -88,11 +96,12 @@ func TestSplit(t *testing.T) {
 				},
 				{
 					items: []*Item{
+						item("two"),
 						item("three"),
 					},
 				},
 			},
-			promoted: item("two"),
+			promoted: separator("two"),
 		},
 		{
 			name: "given odd number of items on parent node",
@@ -156,3 +165,154 @@ func TestSplit(t *testing.T) {
 		})
 	}
 }
+
+func TestNode_Serialize(t *testing.T) {
+	item := func(key string, size int) *Item {
+		return &Item{
+			key:   []byte(key),
+			value: bytes.Repeat([]byte{'v'}, size),
+		}
+	}
+	matrix := []struct {
+		name     string
+		node     *Node
+		size     int
+		expected error
+	}{
+		{
+			name: "given leaf node which fits",
+			node: &Node{
+				items: []*Item{item("a", 10), item("b", 10)},
+			},
+			size: 128,
+		},
+		{
+			name: "given parent node which fits",
+			node: &Node{
+				items:    []*Item{item("a", 10), item("b", 10)},
+				children: []uint64{1, 2, 3},
+			},
+			size: 128,
+		},
+		{
+			name: "given item larger than buffer",
+			node: &Node{
+				items: []*Item{item("a", 200)},
+			},
+			size:     128,
+			expected: ErrPageOverflow,
+		},
+		{
+			name: "given items colliding with header",
+			node: &Node{
+				items:    []*Item{item("a", 40), item("b", 40)},
+				children: []uint64{1, 2, 3},
+			},
+			size:     128,
+			expected: ErrPageOverflow,
+		},
+		{
+			name: "given parent node with missing child",
+			node: &Node{
+				items:    []*Item{item("a", 10)},
+				children: []uint64{1},
+			},
+			size:     128,
+			expected: ErrMalformedNode,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			buf := make([]byte, m.size)
+			err := m.node.Serialize(buf)
+			if !errors.Is(err, m.expected) {
+				t.Fatalf("got %v; want %v", err, m.expected)
+			}
+			if err != nil {
+				return
+			}
+			node := &Node{}
+			if err := node.Deserialize(buf); err != nil {
+				t.Fatal(err)
+			}
+			if len(node.items) != len(m.node.items) || len(node.children) != len(m.node.children) {
+				t.Fatalf("got %d items and %d children; want %d and %d", len(node.items), len(node.children),
+					len(m.node.items), len(m.node.children))
+			}
+			for i := range node.items {
+				if !bytes.Equal(node.items[i].key, m.node.items[i].key) ||
+					!bytes.Equal(node.items[i].value, m.node.items[i].value) {
+					t.Fatalf("got %s; want %s", node.items[i].key, m.node.items[i].key)
+				}
+			}
+		})
+	}
+}
+
+func TestNode_Deserialize(t *testing.T) {
+	valid := func() []byte {
+		buf := make([]byte, 128)
+		node := &Node{
+			items: []*Item{{key: []byte("key"), value: []byte("value")}},
+		}
+		if err := node.Serialize(buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf
+	}
+	matrix := []struct {
+		name    string
+		corrupt func([]byte) []byte
+	}{
+		{
+			name: "given truncated header",
+			corrupt: func(buf []byte) []byte {
+				return buf[:nodeHeaderSize-2]
+			},
+		},
+		{
+			name: "given invalid leaf flag",
+			corrupt: func(buf []byte) []byte {
+				buf[0] = 7
+				return buf
+			},
+		},
+		{
+			name: "given item count exceeding buffer",
+			corrupt: func(buf []byte) []byte {
+				binary.LittleEndian.PutUint16(buf[nodeHeaderSize-2:], 1000)
+				return buf
+			},
+		},
+		{
+			name: "given item offset outside buffer",
+			corrupt: func(buf []byte) []byte {
+				binary.LittleEndian.PutUint16(buf[nodeHeaderSize:], 500)
+				return buf
+			},
+		},
+		{
+			name: "given item offset overlapping header",
+			corrupt: func(buf []byte) []byte {
+				binary.LittleEndian.PutUint16(buf[nodeHeaderSize:], 2)
+				return buf
+			},
+		},
+		{
+			name: "given key length exceeding buffer",
+			corrupt: func(buf []byte) []byte {
+				offset := binary.LittleEndian.Uint16(buf[nodeHeaderSize:])
+				binary.LittleEndian.PutUint16(buf[offset:], 1000)
+				return buf
+			},
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			node := &Node{}
+			if err := node.Deserialize(m.corrupt(valid())); !errors.Is(err, ErrMalformedNode) {
+				t.Fatalf("got %v; want %v", err, ErrMalformedNode)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package dal
+
+import (
+	"sync/atomic"
+)
+
+// counters keeps track of the I/O performed by the DAL since it was opened. The counters are updated atomically so
+// that they can be read by metric exporters while the DAL is in use.
+type counters struct {
+	pagesRead    atomic.Uint64
+	pagesWritten atomic.Uint64
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
+	syncs        atomic.Uint64
+}
+
+func (c *counters) read(n int) {
+	c.pagesRead.Add(1)
+	c.bytesRead.Add(uint64(n))
+}
+
+func (c *counters) write(n int) {
+	c.pagesWritten.Add(1)
+	c.bytesWritten.Add(uint64(n))
+}
+
+// Stats describes the state of a gaslight file and the I/O performed on it since it was opened.
+type Stats struct {
+	PageSize uint64
+	// Pages is the number of pages in the file, including the pages in the freelist.
+	Pages uint64
+	// FreePages is the number of released pages which are available for reuse.
+	FreePages    uint64
+	PagesRead    uint64
+	PagesWritten uint64
+	BytesRead    uint64
+	BytesWritten uint64
+	// Syncs is the number of times the datasource or the write-ahead log has been synced.
+	Syncs uint64
+}
+
+func (d *DAL) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return Stats{
+		PageSize:     d.pageSize,
+		Pages:        d.freelist.allocated + 1,
+		FreePages:    uint64(len(d.freelist.released)),
+		PagesRead:    d.counters.pagesRead.Load(),
+		PagesWritten: d.counters.pagesWritten.Load(),
+		BytesRead:    d.counters.bytesRead.Load(),
+		BytesWritten: d.counters.bytesWritten.Load(),
+		Syncs:        d.counters.syncs.Load(),
+	}
+}
+
+// CollectionStats describes the shape of the tree of a single collection.
+type CollectionStats struct {
+	// Depth is the number of levels in the tree, a collection consisting of only a root node has a depth of 1.
+	Depth int
+	Nodes int
+	// Items is the number of items stored in the leaves of the collection, separators in internal nodes are not counted.
+	Items int
+	// FillFactor is the average fraction of the page size used by the nodes of the collection. It is measured on the
+	// uncompressed nodes, which means that it exceeds 1 for collections of compressed nodes.
+	FillFactor float64
+}
+
+// Stats walks the entire tree of the collection to gather its statistics.
+func (c *Collection) Stats() (CollectionStats, error) {
+	var stats CollectionStats
+	err := c.dal.view(func() error {
+		var err error
+		stats, err = c.stats()
+		return err
+	})
+	return stats, err
+}
+
+func (c *Collection) stats() (CollectionStats, error) {
+	var stats CollectionStats
+	var used int
+	var walk func(id uint64, depth int) error
+	walk = func(id uint64, depth int) error {
+		node := &Node{}
+		if err := c.dal.Deserialize(node, id); err != nil {
+			return err
+		}
+		stats.Nodes += 1
+		if node.Leaf() {
+			stats.Items += len(node.items)
+		}
+		stats.Depth = max(stats.Depth, depth)
+		used += node.size()
+		for _, child := range node.children {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(c.root, 1); err != nil {
+		return CollectionStats{}, err
+	}
+	stats.FillFactor = float64(used) / float64(stats.Nodes) / float64(c.dal.pageSize)
+	return stats, nil
+}
@@ -0,0 +1,49 @@
+package dal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollection_Stats(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := c.Insert([]byte(fmt.Sprintf("key_%04d", i)), []byte(fmt.Sprintf("value_%04d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Items != 1000 {
+		t.Fatalf("got %d items; want %d", stats.Items, 1000)
+	}
+	if stats.Depth < 2 {
+		t.Fatalf("got depth %d; want at least 2", stats.Depth)
+	}
+	if stats.FillFactor <= 0 || stats.FillFactor > 1 {
+		t.Fatalf("got fill factor %f; want within (0, 1]", stats.FillFactor)
+	}
+	s := d.Stats()
+	if s.Pages <= uint64(stats.Nodes) {
+		t.Fatalf("got %d pages; want more than the %d nodes of the collection", s.Pages, stats.Nodes)
+	}
+	if s.BytesWritten == 0 || s.BytesRead == 0 {
+		t.Fatalf("got %d bytes written and %d read; want both to be counted", s.BytesWritten, s.BytesRead)
+	}
+}
@@ -0,0 +1,308 @@
+package dal
+
+import (
+	"errors"
+)
+
+// CreateCollection creates a new, empty, collection under the provided name and registers it in the root collection.
+func (d *DAL) CreateCollection(name string) (*Collection, error) {
+	var c *Collection
+	err := d.update(func() error {
+		var err error
+		c, err = d.collections.create(name)
+		return err
+	})
+	return c, err
+}
+
+// Collection returns the collection registered under the provided name. The same instance is returned for every call
+// using the same name to make sure that changes to the root of the collection are observed by all callers.
+func (d *DAL) Collection(name string) (*Collection, error) {
+	var c *Collection
+	err := d.view(func() error {
+		var err error
+		c, err = d.collections.lookup(name)
+		return err
+	})
+	return c, err
+}
+
+// DropCollection removes the collection registered under the provided name, releasing every page used by it and its
+// sub-collections.
+func (d *DAL) DropCollection(name string) error {
+	return d.update(func() error {
+		return d.collections.drop(name)
+	})
+}
+
+// Collections returns the names of all top level collections in ascending order.
+func (d *DAL) Collections() ([]string, error) {
+	var names []string
+	err := d.view(func() error {
+		var err error
+		names, err = d.collections.names()
+		return err
+	})
+	return names, err
+}
+
+// SubCollections returns the names of the collections nested directly within c in ascending order.
+func (c *Collection) SubCollections() ([]string, error) {
+	var names []string
+	err := c.dal.view(func() error {
+		var err error
+		names, err = c.subCollectionNames()
+		return err
+	})
+	return names, err
+}
+
+func (c *Collection) subCollectionNames() ([]string, error) {
+	if c.collections == EmptyNodeID {
+		return nil, nil
+	}
+	registry, err := c.subcollections()
+	if err != nil {
+		return nil, err
+	}
+	return registry.names()
+}
+
+// CreateSubCollection creates a new, empty, collection nested within c. Sub-collections are registered in a tree of
+// their own, which means that their names never clash with the keys of the items in c.
+func (c *Collection) CreateSubCollection(name string) (*Collection, error) {
+	var sub *Collection
+	err := c.dal.update(func() error {
+		var err error
+		sub, err = c.createSubCollection(name)
+		return err
+	})
+	return sub, err
+}
+
+func (c *Collection) createSubCollection(name string) (*Collection, error) {
+	registry, err := c.subcollections()
+	if err != nil {
+		return nil, err
+	}
+	return registry.create(name)
+}
+
+// SubCollection returns the collection nested within c under the provided name.
+func (c *Collection) SubCollection(name string) (*Collection, error) {
+	var sub *Collection
+	err := c.dal.view(func() error {
+		var err error
+		sub, err = c.subCollection(name)
+		return err
+	})
+	return sub, err
+}
+
+func (c *Collection) subCollection(name string) (*Collection, error) {
+	if c.collections == EmptyNodeID {
+		return nil, ErrCollectionNotFound
+	}
+	registry, err := c.subcollections()
+	if err != nil {
+		return nil, err
+	}
+	return registry.lookup(name)
+}
+
+// DropSubCollection removes the collection nested within c under the provided name in a single operation, releasing
+// every page used by it and any collections nested within it in turn. Instances of the dropped collection must not be
+// used after it has been dropped.
+func (c *Collection) DropSubCollection(name string) error {
+	return c.dal.update(func() error {
+		if c.collections == EmptyNodeID {
+			return ErrCollectionNotFound
+		}
+		registry, err := c.subcollections()
+		if err != nil {
+			return err
+		}
+		return registry.drop(name)
+	})
+}
+
+// subcollections returns the registry holding the headers of the sub-collections of c, creating it if needed.
+func (c *Collection) subcollections() (*Collection, error) {
+	if c.registry != nil {
+		return c.registry, nil
+	}
+	registry := &Collection{
+		root:  c.collections,
+		dal:   c.dal,
+		owner: c,
+	}
+	if c.collections == EmptyNodeID {
+		root := &Node{
+			id: c.dal.freelist.id(),
+		}
+		if err := c.dal.Serialize(root, root.id); err != nil {
+			return nil, err
+		}
+		registry.root = root.id
+		if err := registry.persist(); err != nil {
+			return nil, err
+		}
+	}
+	c.registry = registry
+	return registry, nil
+}
+
+// create creates a collection registered in c, which must be a registry.
+func (c *Collection) create(name string) (*Collection, error) {
+	if _, err := c.find([]byte(name), c.root); err == nil {
+		return nil, ErrCollectionExists
+	} else if !errors.Is(err, ErrItemNotFound) {
+		return nil, err
+	}
+	root := &Node{
+		id: c.dal.freelist.id(),
+	}
+	if err := c.dal.Serialize(root, root.id); err != nil {
+		return nil, err
+	}
+	collection := &Collection{
+		name:   name,
+		root:   root.id,
+		dal:    c.dal,
+		parent: c,
+	}
+	if err := collection.persist(); err != nil {
+		return nil, err
+	}
+	if c.open == nil {
+		c.open = make(map[string]*Collection)
+	}
+	c.open[name] = collection
+	return collection, nil
+}
+
+// lookup returns the collection registered in c, which must be a registry.
+func (c *Collection) lookup(name string) (*Collection, error) {
+	if collection, ok := c.open[name]; ok {
+		return collection, nil
+	}
+	item, err := c.find([]byte(name), c.root)
+	if err != nil {
+		if errors.Is(err, ErrItemNotFound) {
+			return nil, ErrCollectionNotFound
+		}
+		return nil, err
+	}
+	collection := &Collection{
+		dal:    c.dal,
+		parent: c,
+	}
+	if err := collection.Deserialize(item.value); err != nil {
+		return nil, err
+	}
+	if c.open == nil {
+		c.open = make(map[string]*Collection)
+	}
+	c.open[name] = collection
+	return collection, nil
+}
+
+// drop releases and unregisters the collection registered in c, which must be a registry.
+func (c *Collection) drop(name string) error {
+	collection, err := c.lookup(name)
+	if err != nil {
+		return err
+	}
+	if err := collection.release(); err != nil {
+		return err
+	}
+	delete(c.open, name)
+	return c.remove([]byte(name))
+}
+
+// refresh reads the headers of every opened collection registered in c, which must be a registry, again. Collections
+// that are no longer registered are forgotten.
+func (c *Collection) refresh() error {
+	for name, sub := range c.open {
+		item, err := c.find([]byte(name), c.root)
+		if errors.Is(err, ErrItemNotFound) {
+			delete(c.open, name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := sub.Deserialize(item.value); err != nil {
+			return err
+		}
+		if sub.registry == nil {
+			continue
+		}
+		if sub.collections == EmptyNodeID {
+			sub.registry = nil
+			continue
+		}
+		sub.registry.root = sub.collections
+		if err := sub.registry.refresh(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// names returns the names of the collections registered in c, which must be a registry.
+func (c *Collection) names() ([]string, error) {
+	var names []string
+	err := c.forEach(forwards, func(key, _ []byte) error {
+		names = append(names, string(key))
+		return nil
+	})
+	return names, err
+}
+
+// release returns every page used by the collection and its sub-collections to the freelist.
+func (c *Collection) release() error {
+	if c.collections != EmptyNodeID {
+		registry, err := c.subcollections()
+		if err != nil {
+			return err
+		}
+		names, err := registry.names()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			sub, err := registry.lookup(name)
+			if err != nil {
+				return err
+			}
+			if err := sub.release(); err != nil {
+				return err
+			}
+		}
+		if err := registry.release(); err != nil {
+			return err
+		}
+	}
+	return c.walk(c.root, func(n *Node) error {
+		c.dal.freelist.release(n.id)
+		return nil
+	})
+}
+
+// walk visits every node of the tree rooted at the page with the provided id, parents before their children.
+func (c *Collection) walk(id uint64, visit func(n *Node) error) error {
+	node, err := c.load(id)
+	if err != nil {
+		return err
+	}
+	if err := visit(node); err != nil {
+		return err
+	}
+	for _, child := range node.children {
+		if err := c.walk(child, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollection_SubCollection(t *testing.T) {
+	file, err := os.OpenFile(filepath.Join(t.TempDir(), "gaslight.db"), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespaces, err := d.CreateCollection("namespaces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Items and sub-collections may share names without interfering with each other
+	if err := namespaces.Insert([]byte("documents"), []byte("definition")); err != nil {
+		t.Fatal(err)
+	}
+	documents, err := namespaces.CreateSubCollection("documents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := documents.Insert([]byte(fmt.Sprintf("doc_%04d#viewer", i)), []byte("user:alice")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := documents.CreateSubCollection("history"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespaces, err = d.Collection("namespaces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, err = namespaces.SubCollection("documents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := documents.Find([]byte("doc_0999#viewer")); err != nil {
+		t.Fatal(err)
+	}
+	released := len(d.freelist.released)
+	if err := namespaces.DropSubCollection("documents"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := namespaces.SubCollection("documents"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("got %v; want %v", err, ErrCollectionNotFound)
+	}
+	if len(d.freelist.released) <= released {
+		t.Fatalf("got %d released pages; want more than %d", len(d.freelist.released), released)
+	}
+	item, err := namespaces.Find([]byte("documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(item.value) != "definition" {
+		t.Fatalf("got %s; want %s", item.value, "definition")
+	}
+}
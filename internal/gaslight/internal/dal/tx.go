@@ -0,0 +1,515 @@
+package dal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrTxGap = errors.New("transaction gap")
+)
+
+// tx holds the pages written by a transaction until it is committed, at which point they are written to the
+// write-ahead log and the datasource together with the metadata and freelist. Pages read by the transaction are kept
+// as well, which means that every page is read from the datasource at most once per transaction.
+type tx struct {
+	pages map[uint64][]byte
+	reads map[uint64][]byte
+}
+
+func (t *tx) page(id uint64) ([]byte, bool) {
+	if t == nil {
+		return nil, false
+	}
+	if data, ok := t.pages[id]; ok {
+		return data, true
+	}
+	data, ok := t.reads[id]
+	return data, ok
+}
+
+func (t *tx) cache(p *page) {
+	if t == nil {
+		return
+	}
+	data := make([]byte, len(p.data))
+	copy(data, p.data)
+	t.reads[p.id] = data
+}
+
+func (t *tx) stage(p *page) {
+	data := make([]byte, len(p.data))
+	copy(data, p.data)
+	t.pages[p.id] = data
+}
+
+// update runs fn within a transaction, committing it if fn succeeds and discarding every page written by it otherwise.
+// Functions called from within fn must not call update or view themselves. The lock of the DAL is released before
+// waiting for the commit to become durable.
+func (d *DAL) update(fn func() error) error {
+	txid, err := d.transact(fn)
+	if err != nil {
+		return err
+	}
+	return d.durable(txid)
+}
+
+// transact runs and commits the transaction of update while holding the lock of the DAL, returning the id of the
+// committed transaction or zero if nothing was written.
+func (d *DAL) transact(fn func() error) (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.readOnly {
+		return 0, ErrReadOnly
+	}
+	d.tx = &tx{
+		pages: make(map[uint64][]byte),
+		reads: make(map[uint64][]byte),
+	}
+	if err := fn(); err != nil {
+		return 0, d.abort(err)
+	}
+	return d.commit()
+}
+
+// view runs fn while holding the lock of the DAL, which guarantees that no transaction is in progress.
+func (d *DAL) view(fn func() error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return fn()
+}
+
+// Tx gives access to the collections of a DAL within a single transaction, started by View or Update. Every read made
+// through it observes the same state of the file. A Tx must not be used once the function it was passed to returns, and
+// the methods of the DAL and its collections must not be called from within that function as they would wait for the
+// transaction to end.
+type Tx struct {
+	dal      *DAL
+	writable bool
+}
+
+// View calls fn with a transaction through which the collections of the DAL can be read at a single point in time.
+// Writes are held back until fn returns, and writes made through the transaction fail with ErrReadOnly.
+func (d *DAL) View(fn func(tx *Tx) error) error {
+	return d.view(func() error {
+		return fn(&Tx{dal: d})
+	})
+}
+
+// Update calls fn with a transaction through which the collections of the DAL can be read and written, committing
+// every write made through it at once if fn succeeds and discarding all of them otherwise.
+func (d *DAL) Update(fn func(tx *Tx) error) error {
+	return d.update(func() error {
+		return fn(&Tx{dal: d, writable: true})
+	})
+}
+
+// Collections returns the names of all top level collections in ascending order.
+func (tx *Tx) Collections() ([]string, error) {
+	return tx.dal.collections.names()
+}
+
+// Collection returns the collection registered under the provided name.
+func (tx *Tx) Collection(name string) (*Collection, error) {
+	return tx.dal.collections.lookup(name)
+}
+
+// SubCollections returns the names of the collections nested directly within c in ascending order.
+func (tx *Tx) SubCollections(c *Collection) ([]string, error) {
+	return c.subCollectionNames()
+}
+
+// SubCollection returns the collection nested within c under the provided name.
+func (tx *Tx) SubCollection(c *Collection, name string) (*Collection, error) {
+	return c.subCollection(name)
+}
+
+// CreateCollection creates a new, empty, top level collection under the provided name or returns ErrCollectionExists if
+// the name is already taken.
+func (tx *Tx) CreateCollection(name string) (*Collection, error) {
+	if !tx.writable {
+		return nil, ErrReadOnly
+	}
+	return tx.dal.collections.create(name)
+}
+
+// CreateSubCollection creates a new, empty, collection nested within c under the provided name.
+func (tx *Tx) CreateSubCollection(c *Collection, name string) (*Collection, error) {
+	if !tx.writable {
+		return nil, ErrReadOnly
+	}
+	return c.createSubCollection(name)
+}
+
+// SetSequence sets the sequence of c, the next call to its NextSequence method returns the provided value plus one.
+func (tx *Tx) SetSequence(c *Collection, sequence uint64) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	return c.setSequence(sequence)
+}
+
+// Sequence returns the last value handed out by the NextSequence method of c.
+func (tx *Tx) Sequence(c *Collection) uint64 {
+	return c.sequence
+}
+
+// ForEach calls fn for every item in c in ascending key order. Iteration stops at the first error returned by fn.
+func (tx *Tx) ForEach(c *Collection, fn func(key, value []byte) error) error {
+	return c.forEach(forwards, fn)
+}
+
+// ForEachPrefix calls fn for every item in c whose key starts with the provided prefix in ascending key order.
+func (tx *Tx) ForEachPrefix(c *Collection, prefix []byte, fn func(key, value []byte) error) error {
+	return c.forEachPrefix(prefix, fn)
+}
+
+// Find returns the item stored in c under the provided key or ErrItemNotFound if there is none.
+func (tx *Tx) Find(c *Collection, key []byte) (*Item, error) {
+	return c.find(key, c.root)
+}
+
+// GetMany looks up every provided key in c, returning the items in the same order as the keys with nil in place of any
+// key that was not found.
+func (tx *Tx) GetMany(c *Collection, keys [][]byte) ([]*Item, error) {
+	return c.getItems(keys)
+}
+
+// Insert stores the value in c under the provided key, replacing any value already stored under it.
+func (tx *Tx) Insert(c *Collection, key, value []byte) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	return c.put(key, value)
+}
+
+// PutBatch stores every item in c. If the same key occurs more than once the last of the items is stored.
+func (tx *Tx) PutBatch(c *Collection, items []*Item) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	return c.putBatch(items)
+}
+
+// Delete removes the item stored in c under the provided key or returns ErrItemNotFound if there is none.
+func (tx *Tx) Delete(c *Collection, key []byte) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	return c.remove(key)
+}
+
+func (d *DAL) commit() (uint64, error) {
+	t := d.tx
+	if len(t.pages) == 0 {
+		d.tx = nil
+		return 0, nil
+	}
+	d.metadata.txid += 1
+	if err := d.Serialize(d.freelist, d.metadata.freelist); err != nil {
+		return 0, d.abort(err)
+	}
+	if err := d.Serialize(d.metadata, metadataPageID); err != nil {
+		return 0, d.abort(err)
+	}
+	d.tx = nil
+	c := Commit{
+		TxID:   d.metadata.txid,
+		Time:   time.Now().UnixNano(),
+		Frames: make([]Frame, 0, len(t.pages)),
+	}
+	ids := make([]uint64, 0, len(t.pages))
+	for id := range t.pages {
+		ids = append(ids, id)
+	}
+	// Writing the pages in order of their id keeps the writes to the datasource as sequential as possible
+	slices.Sort(ids)
+	for _, id := range ids {
+		(&page{data: t.pages[id]}).setTxID(c.TxID)
+		c.Frames = append(c.Frames, Frame{
+			PageID: id,
+			Data:   t.pages[id],
+		})
+	}
+	if err := d.wal.append(c); err != nil {
+		return 0, d.abort(err)
+	}
+	if err := d.store(c); err != nil {
+		return 0, err
+	}
+	d.publish(c)
+	if d.wal != nil && d.wal.size >= MaxLogSize {
+		return c.TxID, d.checkpoint()
+	}
+	return c.TxID, nil
+}
+
+// store writes the frames of a commit, which must already be in the write-ahead log, to the datasource. With SyncAlways
+// the commit is synced before store returns. With the other durability modes the frames are held in memory instead,
+// since a page written in place before the log holding it is durable could be torn by a crash without the log being
+// able to repair it. They are written once the log has been synced by a group commit or a checkpoint.
+func (d *DAL) store(c Commit) error {
+	if d.wal == nil {
+		// Without a log there is nothing to repair a torn page with, which means that holding the frames back gains
+		// nothing
+		if err := d.flush(c); err != nil {
+			return err
+		}
+		if d.durability == syncAlways {
+			if err := d.sync(); err != nil {
+				return err
+			}
+		}
+		d.group.commit(c.TxID)
+		return nil
+	}
+	// Snapshots are written right away as they replace every page of the file, including those held back
+	if d.durability != syncAlways && !c.Snapshot {
+		d.hold(c)
+		d.group.commit(c.TxID)
+		return nil
+	}
+	if err := d.sync(); err != nil {
+		return err
+	}
+	if err := d.writeback(d.metadata.txid); err != nil {
+		return err
+	}
+	// Once the commit is in the log it will survive a failure to write the pages to the datasource, since the log is
+	// replayed when the DAL is loaded
+	if err := d.flush(c); err != nil {
+		return err
+	}
+	d.group.commit(c.TxID)
+	return nil
+}
+
+// hold keeps the frames of a commit in memory until the write-ahead log holding it has been synced. Reads of the pages
+// are served from memory until then.
+func (d *DAL) hold(c Commit) {
+	if d.dirty == nil {
+		d.dirty = make(map[uint64][]byte)
+	}
+	for _, frame := range c.Frames {
+		d.dirty[frame.PageID] = frame.Data
+	}
+	d.pending = append(d.pending, c)
+}
+
+// writeback writes the frames of every held commit up to and including the one with the provided id to the datasource.
+// The write-ahead log must have been synced since those commits were appended to it.
+func (d *DAL) writeback(txid uint64) error {
+	for len(d.pending) > 0 && d.pending[0].TxID <= txid {
+		if err := d.flush(d.pending[0]); err != nil {
+			return err
+		}
+		d.pending = d.pending[1:]
+	}
+	// Pages written again by a commit that is still held must keep being served from memory
+	for id, data := range d.dirty {
+		if (&page{data: data}).txid() <= txid {
+			delete(d.dirty, id)
+		}
+	}
+	return nil
+}
+
+// abort rolls back the current transaction and returns the error causing it, joined with any error from the rollback.
+func (d *DAL) abort(err error) error {
+	if rerr := d.rollback(); rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return err
+}
+
+// rollback discards the pages of the current transaction and restores the state kept in memory from the datasource.
+func (d *DAL) rollback() error {
+	d.tx = nil
+	if err := d.reload(); err != nil {
+		return fmt.Errorf("rollback: %w", err)
+	}
+	return nil
+}
+
+// reload reads the metadata and freelist from the datasource and refreshes the headers of every opened collection.
+func (d *DAL) reload() error {
+	if err := d.Deserialize(d.metadata, metadataPageID); err != nil {
+		return err
+	}
+	if err := d.Deserialize(d.freelist, d.metadata.freelist); err != nil {
+		return err
+	}
+	d.collections.root = d.metadata.root
+	return d.collections.refresh()
+}
+
+// flush writes the frames of the commit to the datasource.
+func (d *DAL) flush(c Commit) error {
+	for _, frame := range c.Frames {
+		if err := d.write(&page{id: frame.PageID, data: frame.Data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recover writes every complete commit found in the write-ahead log to the datasource and empties the log.
+func (d *DAL) recover() error {
+	commits, err := d.wal.open()
+	if err != nil {
+		return err
+	}
+	for _, c := range commits {
+		if err := d.flush(c); err != nil {
+			return err
+		}
+	}
+	return d.checkpoint()
+}
+
+// overlay holds every complete commit found in the write-ahead log in memory, as if they had yet to be written to the
+// datasource, leaving both the log and the datasource untouched.
+func (d *DAL) overlay() error {
+	_, err := d.wal.scan(func(c Commit) error {
+		d.hold(c)
+		return nil
+	})
+	return err
+}
+
+// TxID returns the identifier of the last committed transaction.
+func (d *DAL) TxID() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.metadata.txid
+}
+
+// Commits returns every commit with a transaction id larger than the provided one. If the write-ahead log no longer
+// holds all of them a single snapshot commit holding every page of the file is returned instead.
+func (d *DAL) Commits(after uint64) ([]Commit, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if after >= d.metadata.txid {
+		return nil, nil
+	}
+	commits, ok, err := d.wal.commits(after)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return commits, nil
+	}
+	snapshot := Commit{
+		TxID:     d.metadata.txid,
+		Time:     time.Now().UnixNano(),
+		Snapshot: true,
+		Frames:   make([]Frame, 0, d.freelist.allocated+1),
+	}
+	for id := uint64(0); id <= d.freelist.allocated; id++ {
+		p, err := d.read(id)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Frames = append(snapshot.Frames, Frame{
+			PageID: id,
+			Data:   p.data,
+		})
+	}
+	return []Commit{snapshot}, nil
+}
+
+// Apply writes a commit received from another DAL, through Commits or a Subscription, to this DAL. Commits must be
+// applied in order, commits that have already been applied are ignored and ErrTxGap is returned if a commit is missing.
+// Snapshots may be applied at any time. Apply is permitted on read only DALs, but not on frozen ones.
+func (d *DAL) Apply(c Commit) error {
+	if d.frozen {
+		return ErrReadOnly
+	}
+	txid, err := d.apply(c)
+	if err != nil {
+		return err
+	}
+	return d.durable(txid)
+}
+
+// apply writes the commit while holding the lock of the DAL, returning its transaction id or zero if it was ignored.
+func (d *DAL) apply(c Commit) (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !c.Snapshot {
+		if c.TxID <= d.metadata.txid {
+			return 0, nil
+		}
+		if c.TxID != d.metadata.txid+1 {
+			return 0, fmt.Errorf("%w: expected transaction %d, got %d", ErrTxGap, d.metadata.txid+1, c.TxID)
+		}
+	}
+	if err := d.wal.append(c); err != nil {
+		return 0, err
+	}
+	if err := d.store(c); err != nil {
+		return 0, err
+	}
+	if err := d.reload(); err != nil {
+		return 0, err
+	}
+	d.publish(c)
+	if d.wal != nil && d.wal.size >= MaxLogSize {
+		return c.TxID, d.checkpoint()
+	}
+	return c.TxID, nil
+}
+
+// Subscription receives every commit made after it was created. If the subscriber falls more than the size of the
+// buffer behind the channel is closed, after which the subscriber must catch up through Commits.
+type Subscription struct {
+	C      <-chan Commit
+	c      chan Commit
+	d      *DAL
+	closed bool
+}
+
+// Subscribe creates a subscription with room for the provided number of unreceived commits.
+func (d *DAL) Subscribe(buffer int) *Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := make(chan Commit, buffer)
+	s := &Subscription{
+		C: c,
+		c: c,
+		d: d,
+	}
+	if d.subscribers == nil {
+		d.subscribers = make(map[*Subscription]struct{})
+	}
+	d.subscribers[s] = struct{}{}
+	return s
+}
+
+// Close stops the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.close()
+}
+
+func (s *Subscription) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.d.subscribers, s)
+	close(s.c)
+}
+
+func (d *DAL) publish(c Commit) {
+	for s := range d.subscribers {
+		select {
+		case s.c <- c:
+		default:
+			s.close()
+		}
+	}
+}
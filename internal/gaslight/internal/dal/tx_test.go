@@ -0,0 +1,107 @@
+package dal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDAL_Update(t *testing.T) {
+	failed := errors.New("failed")
+	matrix := []struct {
+		name     string
+		fn       func(tx *Tx, objects, subjects *Collection) error
+		err      error
+		expected bool
+	}{
+		{
+			name: "given successful update of both collections",
+			fn: func(tx *Tx, objects, subjects *Collection) error {
+				if err := tx.Insert(objects, []byte("doc_1#viewer@user:alice"), nil); err != nil {
+					return err
+				}
+				return tx.PutBatch(subjects, []*Item{NewItem([]byte("user:alice@doc_1#viewer"), nil)})
+			},
+			expected: true,
+		},
+		{
+			name: "given update failing after writing the first collection",
+			fn: func(tx *Tx, objects, subjects *Collection) error {
+				if err := tx.Insert(objects, []byte("doc_1#viewer@user:alice"), nil); err != nil {
+					return err
+				}
+				return failed
+			},
+			err:      failed,
+			expected: false,
+		},
+		{
+			name: "given update deleting a missing item after writing both collections",
+			fn: func(tx *Tx, objects, subjects *Collection) error {
+				if err := tx.Insert(objects, []byte("doc_1#viewer@user:alice"), nil); err != nil {
+					return err
+				}
+				if err := tx.Insert(subjects, []byte("user:alice@doc_1#viewer"), nil); err != nil {
+					return err
+				}
+				return tx.Delete(objects, []byte("doc_2#viewer@user:bob"))
+			},
+			err:      ErrItemNotFound,
+			expected: false,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			d := open(t)
+			objects, err := d.CreateCollection("tuples_by_object")
+			if err != nil {
+				t.Fatal(err)
+			}
+			subjects, err := d.CreateCollection("tuples_by_subject")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = d.Update(func(tx *Tx) error {
+				return m.fn(tx, objects, subjects)
+			})
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			var found []bool
+			err = d.View(func(tx *Tx) error {
+				for _, lookup := range []struct {
+					c   *Collection
+					key string
+				}{{objects, "doc_1#viewer@user:alice"}, {subjects, "user:alice@doc_1#viewer"}} {
+					_, err := tx.Find(lookup.c, []byte(lookup.key))
+					if err != nil && !errors.Is(err, ErrItemNotFound) {
+						return err
+					}
+					found = append(found, err == nil)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, ok := range found {
+				if ok != m.expected {
+					t.Fatalf("collection %d: got item %t; want %t", i, ok, m.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestDAL_View(t *testing.T) {
+	d := open(t)
+	c, err := d.CreateCollection("tuples_by_object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.View(func(tx *Tx) error {
+		return tx.Insert(c, []byte("doc_1#viewer@user:alice"), nil)
+	})
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("got %v; want %v", err, ErrReadOnly)
+	}
+}
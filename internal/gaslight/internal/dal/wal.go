@@ -0,0 +1,195 @@
+package dal
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// frameHeaderSize is the size of the header preceding the page data of every frame in the log: the transaction id,
+	// the page id, the commit time, the flags and a checksum of the header and page data.
+	frameHeaderSize = 8 + 8 + 8 + 1 + 4
+	// frameFlagsOffset and frameChecksumOffset are the offsets of the flags and the checksum within the frame header.
+	frameFlagsOffset    = 24
+	frameChecksumOffset = 25
+	// frameCommit marks the last frame of a transaction, frames of a transaction without one are never replayed.
+	frameCommit = 1 << 0
+	// frameSnapshot marks frames belonging to a snapshot of the entire file rather than a single transaction.
+	frameSnapshot = 1 << 1
+	// MaxLogSize is the size at which the log is checkpointed after a commit. Followers that have not caught up with
+	// the commits in the log at that point are sent a snapshot instead.
+	MaxLogSize = 64 << 20
+)
+
+// Log is the storage of the write-ahead log. Besides being a Datasource it must be possible to truncate it, which is
+// done once its content is known to have been written to the datasource of the DAL.
+type Log interface {
+	Datasource
+	Truncate(size int64) error
+}
+
+// Frame is the content of a single page written by a transaction.
+type Frame struct {
+	PageID uint64
+	Data   []byte
+}
+
+// Commit is a committed transaction as recorded in the write-ahead log.
+type Commit struct {
+	TxID uint64
+	// Time is the time of the commit in nanoseconds since the Unix epoch.
+	Time int64
+	// Snapshot is true if the frames of the commit hold every page of the file as of the transaction rather than just
+	// the pages written by it.
+	Snapshot bool
+	Frames   []Frame
+}
+
+type wal struct {
+	log      Log
+	pageSize uint64
+	// size is the offset at which the next frame will be written.
+	size int64
+	// first is the transaction id of the first commit in the log or zero if the log is empty.
+	first uint64
+}
+
+func (w *wal) append(c Commit) error {
+	if w == nil {
+		return nil
+	}
+	buf := make([]byte, len(c.Frames)*(frameHeaderSize+int(w.pageSize)))
+	for i, frame := range c.Frames {
+		var flags uint8
+		if i == len(c.Frames)-1 {
+			flags |= frameCommit
+		}
+		if c.Snapshot {
+			flags |= frameSnapshot
+		}
+		f := buf[i*(frameHeaderSize+int(w.pageSize)):]
+		binary.LittleEndian.PutUint64(f, c.TxID)
+		binary.LittleEndian.PutUint64(f[8:], frame.PageID)
+		binary.LittleEndian.PutUint64(f[16:], uint64(c.Time))
+		f[frameFlagsOffset] = flags
+		copy(f[frameHeaderSize:frameHeaderSize+int(w.pageSize)], frame.Data)
+		checksum := crc32.NewIEEE()
+		checksum.Write(f[:frameChecksumOffset])
+		checksum.Write(f[frameHeaderSize : frameHeaderSize+int(w.pageSize)])
+		binary.LittleEndian.PutUint32(f[frameChecksumOffset:], checksum.Sum32())
+	}
+	if _, err := w.log.Seek(w.size, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.log.Write(buf); err != nil {
+		return err
+	}
+	w.size += int64(len(buf))
+	if w.first == 0 {
+		w.first = c.TxID
+	}
+	return nil
+}
+
+// scan reads the log from the start and calls fn for every commit found in it. Reading stops at the first frame that
+// is incomplete or fails its checksum, which is how a commit that was only partially written is detected. The returned
+// offset is the end of the last complete commit.
+func (w *wal) scan(fn func(c Commit) error) (int64, error) {
+	if _, err := w.log.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var offset, end int64
+	var pending Commit
+	f := make([]byte, frameHeaderSize+int(w.pageSize))
+	for {
+		if _, err := io.ReadFull(w.log, f); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return end, nil
+			}
+			return end, err
+		}
+		offset += int64(len(f))
+		checksum := crc32.NewIEEE()
+		checksum.Write(f[:frameChecksumOffset])
+		checksum.Write(f[frameHeaderSize:])
+		if checksum.Sum32() != binary.LittleEndian.Uint32(f[frameChecksumOffset:]) {
+			return end, nil
+		}
+		txid := binary.LittleEndian.Uint64(f)
+		if len(pending.Frames) > 0 && pending.TxID != txid {
+			// A new transaction started without the previous one being committed
+			return end, nil
+		}
+		data := make([]byte, w.pageSize)
+		copy(data, f[frameHeaderSize:])
+		pending.TxID = txid
+		pending.Time = int64(binary.LittleEndian.Uint64(f[16:]))
+		pending.Snapshot = f[frameFlagsOffset]&frameSnapshot != 0
+		pending.Frames = append(pending.Frames, Frame{
+			PageID: binary.LittleEndian.Uint64(f[8:]),
+			Data:   data,
+		})
+		if f[frameFlagsOffset]&frameCommit != 0 {
+			if err := fn(pending); err != nil {
+				return end, err
+			}
+			pending = Commit{}
+			end = offset
+		}
+	}
+}
+
+// open scans the log for complete commits, discarding anything written after the last of them, and returns them.
+func (w *wal) open() ([]Commit, error) {
+	var commits []Commit
+	end, err := w.scan(func(c Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.log.Truncate(end); err != nil {
+		return nil, err
+	}
+	w.size = end
+	w.first = 0
+	if len(commits) > 0 {
+		w.first = commits[0].TxID
+	}
+	return commits, nil
+}
+
+// commits returns every commit in the log with a transaction id larger than the provided one. The returned boolean is
+// false if the log no longer holds all of those commits.
+func (w *wal) commits(after uint64) ([]Commit, bool, error) {
+	if w == nil || w.first == 0 || w.first > after+1 {
+		return nil, false, nil
+	}
+	var commits []Commit
+	_, err := w.scan(func(c Commit) error {
+		if c.TxID > after {
+			commits = append(commits, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return commits, true, nil
+}
+
+// checkpoint empties the log, which must only be done once every commit in it has been written to the datasource.
+func (w *wal) checkpoint() error {
+	if w == nil {
+		return nil
+	}
+	if err := w.log.Truncate(0); err != nil {
+		return err
+	}
+	w.size = 0
+	w.first = 0
+	return nil
+}
@@ -0,0 +1,175 @@
+package dal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// file is an in-memory Log.
+type file struct {
+	data   []byte
+	offset int64
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if end := f.offset + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	n := copy(f.data[f.offset:], p)
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.offset = offset
+	case io.SeekCurrent:
+		f.offset += offset
+	case io.SeekEnd:
+		f.offset = int64(len(f.data)) + offset
+	}
+	return f.offset, nil
+}
+
+func (f *file) Truncate(size int64) error {
+	if size < int64(len(f.data)) {
+		f.data = f.data[:size]
+	}
+	return nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func TestWAL_Recover(t *testing.T) {
+	ds, log := &file{}, &file{}
+	d, err := New(ds, WithLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := d.CreateCollection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The datasource is copied before the inserts, loading the copy with the log is the same as crashing after the
+	// commits were logged but before any of their pages reached the datasource
+	before := bytes.Clone(ds.data)
+	for i := 0; i < 500; i++ {
+		if err := c.Insert([]byte(fmt.Sprintf("key_%03d", i)), []byte(fmt.Sprintf("value_%03d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A commit torn in the middle of a frame must be discarded
+	log.data = append(log.data, make([]byte, frameHeaderSize+10)...)
+
+	d, err = Load(&file{data: before}, WithLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.data) != 0 {
+		t.Fatalf("got %d bytes in log; want log to be checkpointed", len(log.data))
+	}
+	c, err = d.Collection("relationships")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := c.Find([]byte(fmt.Sprintf("key_%03d", i))); err != nil {
+			t.Fatalf("key_%03d: %v", i, err)
+		}
+	}
+	if d.TxID() != 501 {
+		t.Fatalf("got transaction %d; want %d", d.TxID(), 501)
+	}
+}
+
+func TestDAL_Apply(t *testing.T) {
+	primary, err := New(&file{}, WithLog(&file{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := primary.CreateCollection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Insert([]byte("alice"), []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	follower, err := New(&file{}, WithLog(&file{}), ReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := follower.CreateCollection("principals"); err != ErrReadOnly {
+		t.Fatalf("got %v; want %v", err, ErrReadOnly)
+	}
+	commits, err := primary.Commits(follower.TxID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, commit := range commits {
+		if err := follower.Apply(commit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replica, err := follower.Collection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replica.Find([]byte("alice")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A live commit is observed by an opened collection on the follower
+	subscription := primary.Subscribe(1)
+	if err := c.Insert([]byte("bob"), []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := follower.Apply(<-subscription.C); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replica.Find([]byte("bob")); err != nil {
+		t.Fatal(err)
+	}
+	if follower.TxID() != primary.TxID() {
+		t.Fatalf("got transaction %d; want %d", follower.TxID(), primary.TxID())
+	}
+
+	// Once the log has been checkpointed a new follower can only be brought up to date through a snapshot
+	if err := primary.wal.checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	commits, err = primary.Commits(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || !commits[0].Snapshot {
+		t.Fatalf("got %d commits; want a single snapshot", len(commits))
+	}
+	late, err := New(&file{}, ReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := late.Apply(commits[0]); err != nil {
+		t.Fatal(err)
+	}
+	replica, err = late.Collection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replica.Find([]byte("bob")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package gaslight
+
+import "os"
+
+// lock is a no-op on platforms without flock, where it is on the caller to keep a file from being opened by several
+// processes at once.
+func lock(*os.File, bool) error {
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package gaslight
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lock takes an advisory lock on the file without waiting for it, an exclusive lock if exclusive is true and a shared
+// lock otherwise. ErrLocked is returned if another process holds a conflicting lock. The lock is released once the
+// file is closed.
+func lock(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package gaslight
+
+import (
+	"errors"
+)
+
+// TypedCollection wraps a collection and converts keys and values with the provided codecs, which saves callers from
+// marshalling byte slices by hand.
+type TypedCollection[K, V any] struct {
+	collection *Collection
+	// operations is the collection itself, or the collection within a transaction for collections returned by In.
+	operations operations
+	keys       Codec[K]
+	values     Codec[V]
+}
+
+// operations are the operations on a collection used by TypedCollection.
+type operations interface {
+	Find(key []byte) (*Item, error)
+	Insert(key, value []byte) error
+	Delete(key []byte) error
+	PutBatch(items []*Item) error
+	GetMany(keys [][]byte) ([]*Item, error)
+	ForEachPrefix(prefix []byte, fn func(key, value []byte) error) error
+}
+
+// bound is a collection within a transaction.
+type bound struct {
+	tx         *Tx
+	collection *Collection
+}
+
+func (b bound) Find(key []byte) (*Item, error) {
+	return b.tx.Find(b.collection, key)
+}
+
+func (b bound) Insert(key, value []byte) error {
+	return b.tx.Insert(b.collection, key, value)
+}
+
+func (b bound) Delete(key []byte) error {
+	return b.tx.Delete(b.collection, key)
+}
+
+func (b bound) PutBatch(items []*Item) error {
+	return b.tx.PutBatch(b.collection, items)
+}
+
+func (b bound) GetMany(keys [][]byte) ([]*Item, error) {
+	return b.tx.GetMany(b.collection, keys)
+}
+
+func (b bound) ForEachPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	return b.tx.ForEachPrefix(b.collection, prefix, fn)
+}
+
+// NewTypedCollection wraps the provided collection. The key codec must be order preserving for the order of the stored
+// items to follow the order of the keys.
+func NewTypedCollection[K, V any](c *Collection, keys Codec[K], values Codec[V]) *TypedCollection[K, V] {
+	return &TypedCollection[K, V]{
+		collection: c,
+		operations: c,
+		keys:       keys,
+		values:     values,
+	}
+}
+
+// In returns the collection within the provided transaction, which must not be used once the transaction has ended.
+func (t *TypedCollection[K, V]) In(tx *Tx) *TypedCollection[K, V] {
+	return &TypedCollection[K, V]{
+		collection: t.collection,
+		operations: bound{tx: tx, collection: t.collection},
+		keys:       t.keys,
+		values:     t.values,
+	}
+}
+
+// Collection returns the underlying, untyped, collection.
+func (t *TypedCollection[K, V]) Collection() *Collection {
+	return t.collection
+}
+
+// Get returns the value stored under the provided key or ErrItemNotFound if there is none.
+func (t *TypedCollection[K, V]) Get(key K) (V, error) {
+	var v V
+	k, err := t.keys.Encode(key)
+	if err != nil {
+		return v, err
+	}
+	item, err := t.operations.Find(k)
+	if err != nil {
+		return v, err
+	}
+	return t.values.Decode(item.Value())
+}
+
+// Put stores the value under the provided key, replacing any value already stored under it.
+func (t *TypedCollection[K, V]) Put(key K, val V) error {
+	k, err := t.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	v, err := t.values.Encode(val)
+	if err != nil {
+		return err
+	}
+	return t.operations.Insert(k, v)
+}
+
+// Delete removes the value stored under the provided key or returns ErrItemNotFound if there is none.
+func (t *TypedCollection[K, V]) Delete(key K) error {
+	k, err := t.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	return t.operations.Delete(k)
+}
+
+// PutBatch stores every value under the key at the same index in a single transaction. If a key occurs more than once
+// the last value is stored.
+func (t *TypedCollection[K, V]) PutBatch(keys []K, values []V) error {
+	if len(keys) != len(values) {
+		return errors.New("number of keys and values differ")
+	}
+	items := make([]*Item, 0, len(keys))
+	for i := range keys {
+		k, err := t.keys.Encode(keys[i])
+		if err != nil {
+			return err
+		}
+		v, err := t.values.Encode(values[i])
+		if err != nil {
+			return err
+		}
+		items = append(items, NewItem(k, v))
+	}
+	return t.operations.PutBatch(items)
+}
+
+// GetMany looks up every key in a single traversal of the collection. The returned values are in the same order as the
+// keys, the returned booleans tell whether the key at the same index was found.
+func (t *TypedCollection[K, V]) GetMany(keys []K) ([]V, []bool, error) {
+	encoded := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		k, err := t.keys.Encode(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		encoded = append(encoded, k)
+	}
+	items, err := t.operations.GetMany(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, found := make([]V, len(keys)), make([]bool, len(keys))
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		if values[i], err = t.values.Decode(item.Value()); err != nil {
+			return nil, nil, err
+		}
+		found[i] = true
+	}
+	return values, found, nil
+}
+
+// ForEachPrefix calls fn for every item whose encoded key starts with the encoded prefix, in ascending key order. With
+// TupleCodec this visits every key starting with the elements of the prefix tuple.
+func (t *TypedCollection[K, V]) ForEachPrefix(prefix K, fn func(key K, value V) error) error {
+	p, err := t.keys.Encode(prefix)
+	if err != nil {
+		return err
+	}
+	return t.operations.ForEachPrefix(p, func(k, v []byte) error {
+		key, err := t.keys.Decode(k)
+		if err != nil {
+			return err
+		}
+		value, err := t.values.Decode(v)
+		if err != nil {
+			return err
+		}
+		return fn(key, value)
+	})
+}
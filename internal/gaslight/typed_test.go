@@ -0,0 +1,156 @@
+package gaslight
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type principal struct {
+	Subject string `json:"subject"`
+	Groups  []string
+}
+
+func TestTypedCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gaslight.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := db.CreateCollection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	principals := NewTypedCollection[Tuple, principal](c, TupleCodec{}, JSONCodec[principal]{})
+	if err := principals.Put(Tuple{"users", "alice"}, principal{Subject: "alice", Groups: []string{"eng"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := principals.Put(Tuple{"users", "alice"}, principal{Subject: "alice", Groups: []string{"ops"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c, err = db.Collection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	principals = NewTypedCollection[Tuple, principal](c, TupleCodec{}, JSONCodec[principal]{})
+	p, err := principals.Get(Tuple{"users", "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "alice" || len(p.Groups) != 1 || p.Groups[0] != "ops" {
+		t.Fatalf("got %v; want alice in ops", p)
+	}
+	if _, err := principals.Get(Tuple{"users", "bob"}); !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("got %v; want %v", err, ErrItemNotFound)
+	}
+}
+
+func TestTypedCollection_GetMany(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "gaslight.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c, err := db.CreateCollection("sequences")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sequences := NewTypedCollection[string, uint64](c, StringCodec{}, Uint64Codec{})
+	if err := sequences.PutBatch([]string{"b", "a", "c"}, []uint64{2, 1, 3}); err != nil {
+		t.Fatal(err)
+	}
+	values, found, err := sequences.GetMany([]string{"c", "x", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found[0] || found[1] || !found[2] || values[0] != 3 || values[2] != 1 {
+		t.Fatalf("got %v %v; want [3 0 1] [true false true]", values, found)
+	}
+}
+
+func TestTypedCollection_ForEachPrefix(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "gaslight.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c, err := db.CreateCollection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	principals := NewTypedCollection[Tuple, principal](c, TupleCodec{}, JSONCodec[principal]{})
+	for _, key := range []Tuple{{"users", "alice"}, {"users", "bob"}, {"usersets", "eng"}, {"groups", "ops"}} {
+		if err := principals.Put(key, principal{Subject: key[1].(string)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var subjects []string
+	err = principals.ForEachPrefix(Tuple{"users"}, func(key Tuple, value principal) error {
+		subjects = append(subjects, value.Subject)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subjects) != 2 || subjects[0] != "alice" || subjects[1] != "bob" {
+		t.Fatalf("got %v; want [alice bob]", subjects)
+	}
+}
+
+func TestTypedCollection_In(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "gaslight.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	p, err := db.CreateCollection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := db.CreateCollection("groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	principals := NewTypedCollection[string, principal](p, StringCodec{}, JSONCodec[principal]{})
+	groups := NewTypedCollection[string, string](g, StringCodec{}, StringCodec{})
+	failed := errors.New("failed")
+	err = db.Update(func(tx *Tx) error {
+		if err := principals.In(tx).Put("alice", principal{Subject: "alice", Groups: []string{"eng"}}); err != nil {
+			return err
+		}
+		if err := groups.In(tx).Put("eng", "alice"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("got %v; want %v", err, failed)
+	}
+	if _, err := principals.Get("alice"); !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("got %v; want %v", err, ErrItemNotFound)
+	}
+	if _, err := groups.Get("eng"); !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("got %v; want %v", err, ErrItemNotFound)
+	}
+	err = db.Update(func(tx *Tx) error {
+		if err := principals.In(tx).Put("alice", principal{Subject: "alice", Groups: []string{"eng"}}); err != nil {
+			return err
+		}
+		return groups.In(tx).Put("eng", "alice")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member, err := groups.Get("eng"); err != nil || member != "alice" {
+		t.Fatalf("got %q, %v; want alice", member, err)
+	}
+}
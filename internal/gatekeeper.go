@@ -2,6 +2,7 @@ package gatekeeper
 
 import (
 	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/condition"
 	"github.com/ernilsson/gatekeeper/internal/entity"
 	"time"
 )
@@ -28,6 +29,9 @@ type Relationship struct {
 	*entity.Entity
 	Namespace Namespace
 	Name      string
+	// Condition is an expression of the condition package which must hold, for the attributes of an authorization, for
+	// the relationship to be granted. Relationships without a condition are granted unconditionally.
+	Condition string
 	Created   time.Time
 	Updated   time.Time
 }
@@ -35,3 +39,15 @@ type Relationship struct {
 func (r Relationship) QualifiedName() string {
 	return fmt.Sprintf("%s:%s", r.Namespace.Name, r.Name)
 }
+
+// Validate returns an error wrapping condition.ErrInvalidCondition if the condition of the relationship does not
+// compile.
+func (r Relationship) Validate() error {
+	if r.Condition == "" {
+		return nil
+	}
+	if _, err := condition.Compile(r.Condition); err != nil {
+		return fmt.Errorf("relationship %s: %w", r.QualifiedName(), err)
+	}
+	return nil
+}
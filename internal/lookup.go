@@ -0,0 +1,169 @@
+package gatekeeper
+
+import (
+	"context"
+	"fmt"
+)
+
+// LookupResources calls fn with the ID of every object of the namespace the subject holds the relation on, as the
+// lookup finds them. It is the reverse of Check, following the tuples naming the subject, the usersets it is a member
+// of and the relations implied by the relations it holds breadth first, which means that objects are found in order
+// of how many tuples and inheritance edges away from the subject they are, and that an object is only found if Check
+// would return true for it. The lookup stops with the error of fn if it returns one. ErrMaxDepth is returned if there
+// are more tuples or inheritance edges to follow beyond the maximum depth. Once every object has been found,
+// ErrConditionalRelation is returned if the subject holds the relation on further objects only through a relation with
+// a condition.
+func (c *Checker) LookupResources(ctx context.Context, namespace, relation string, subject Subject,
+	fn func(object string) error) error {
+	if namespace == "" || relation == "" || subject.Namespace == "" || subject.Object == "" {
+		return fmt.Errorf("%w: looking up resources requires a namespace, a relation and a subject", ErrMalformedTuple)
+	}
+	r := c.rules.Load()
+	found := make(map[string]bool)
+	skipped, err := c.resources(ctx, r, namespace, relation, subject, false, func(object string) error {
+		found[object] = true
+		return fn(object)
+	})
+	if err != nil || !skipped {
+		return err
+	}
+	// Relations with a condition only matter to the lookup if objects are reached through them alone
+	_, err = c.resources(ctx, r, namespace, relation, subject, true, func(object string) error {
+		if found[object] {
+			return nil
+		}
+		return fmt.Errorf("%w: %s holds %s:%s through it", ErrConditionalRelation, subject, namespace, relation)
+	})
+	return err
+}
+
+// resources calls fn with every object the subject holds the relation on as they are found, following relations with
+// a condition only if conditional is true. It returns whether a relation with a condition was skipped.
+func (c *Checker) resources(ctx context.Context, r *rules, namespace, relation string, subject Subject,
+	conditional bool, fn func(object string) error) (bool, error) {
+	var skipped bool
+	visited := map[Subject]bool{subject: true}
+	frontier := []Subject{subject}
+	for depth := 0; len(frontier) > 0; depth++ {
+		if depth > c.maxDepth {
+			return false, fmt.Errorf("%w: looking up resources of %s", ErrMaxDepth, subject)
+		}
+		var next []Subject
+		visit := func(userset Subject) error {
+			if visited[userset] {
+				return nil
+			}
+			if !conditional && r.conditional(userset) != nil {
+				skipped = true
+				return nil
+			}
+			visited[userset] = true
+			next = append(next, userset)
+			if userset.Namespace == namespace && userset.Relation == relation {
+				return fn(userset.Object)
+			}
+			return nil
+		}
+		for _, s := range frontier {
+			// Holding a relation implies holding every relation it is inherited by, which is one edge further down the
+			// path of a check, just like the usersets naming it
+			if s.Userset() {
+				for _, implied := range r.implied[s.Namespace+":"+s.Relation] {
+					if err := visit(Subject{Namespace: s.Namespace, Object: s.Object, Relation: implied}); err != nil {
+						return false, err
+					}
+				}
+			}
+			tuples, err := c.tuples.SubjectTuples(ctx, s, "")
+			if err != nil {
+				return false, err
+			}
+			for _, t := range tuples {
+				if err := visit(Subject{Namespace: t.Namespace, Object: t.Object, Relation: t.Relation}); err != nil {
+					return false, err
+				}
+			}
+		}
+		frontier = next
+	}
+	return skipped, nil
+}
+
+// LookupSubjects calls fn with every direct subject holding the relation on the object of the namespace, as the lookup
+// finds them, limited to subjects of the subject namespace unless it is empty. Subjects are found breadth first, in
+// order of how many usersets and inherited relations away from the object they are, and a subject is only found if
+// Check would return true for it. The lookup stops with the error of fn if it returns one. ErrMaxDepth is returned if
+// there are more usersets or inherited relations to follow beyond the maximum depth. Once every subject has been found,
+// ErrConditionalRelation is returned if further subjects hold the relation only through a relation with a condition.
+func (c *Checker) LookupSubjects(ctx context.Context, namespace, object, relation, subjectNamespace string,
+	fn func(subject Subject) error) error {
+	if namespace == "" || object == "" || relation == "" {
+		return fmt.Errorf("%w: looking up subjects requires a namespace, an object and a relation", ErrMalformedTuple)
+	}
+	r := c.rules.Load()
+	root := Subject{Namespace: namespace, Object: object, Relation: relation}
+	found := make(map[Subject]bool)
+	skipped, err := c.subjects(ctx, r, root, subjectNamespace, false, func(s Subject) error {
+		found[s] = true
+		return fn(s)
+	})
+	if err != nil || !skipped {
+		return err
+	}
+	// Relations with a condition only matter to the lookup if subjects are reached through them alone
+	_, err = c.subjects(ctx, r, root, subjectNamespace, true, func(s Subject) error {
+		if found[s] {
+			return nil
+		}
+		return fmt.Errorf("%w: subjects hold %s through it", ErrConditionalRelation, root)
+	})
+	return err
+}
+
+// subjects calls fn with every direct subject holding the relation of the root userset as they are found, following
+// relations with a condition only if conditional is true. It returns whether a relation with a condition was skipped.
+func (c *Checker) subjects(ctx context.Context, r *rules, root Subject, subjectNamespace string, conditional bool,
+	fn func(subject Subject) error) (bool, error) {
+	var skipped bool
+	visited := map[Subject]bool{root: true}
+	frontier := []Subject{root}
+	for depth := 0; len(frontier) > 0; depth++ {
+		if depth > c.maxDepth {
+			return false, fmt.Errorf("%w: looking up subjects of %s", ErrMaxDepth, root)
+		}
+		var next []Subject
+		for _, userset := range frontier {
+			if !conditional && r.conditional(userset) != nil {
+				skipped = true
+				continue
+			}
+			tuples, err := c.tuples.ObjectTuples(ctx, userset.Namespace, userset.Object, userset.Relation)
+			if err != nil {
+				return false, err
+			}
+			reached := make([]Subject, 0, len(tuples))
+			for _, t := range tuples {
+				reached = append(reached, t.Subject)
+			}
+			for _, relation := range r.inherited[userset.Namespace+":"+userset.Relation] {
+				reached = append(reached, Subject{Namespace: userset.Namespace, Object: userset.Object, Relation: relation})
+			}
+			for _, s := range reached {
+				if visited[s] {
+					continue
+				}
+				visited[s] = true
+				switch {
+				case s.Userset():
+					next = append(next, s)
+				case subjectNamespace == "" || s.Namespace == subjectNamespace:
+					if err := fn(s); err != nil {
+						return false, err
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+	return skipped, nil
+}
@@ -0,0 +1,273 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChecker_LookupResources(t *testing.T) {
+	editor := Inheritance{
+		Parent: Relationship{Namespace: Namespace{Name: "documents"}, Name: "editor"},
+		Child:  Relationship{Namespace: Namespace{Name: "documents"}, Name: "viewer"},
+	}
+	// Membership of a group is only held from within the internal network
+	internal := WithConditions(&Relationship{
+		Namespace: Namespace{Name: "group"},
+		Name:      "member",
+		Condition: `request.ip in cidr("10.0.0.0/8")`,
+	})
+	matrix := []struct {
+		name         string
+		tuples       tuples
+		inheritances []Inheritance
+		opts         []CheckOption
+		relation     string
+		expected     []string
+		err          error
+	}{
+		{
+			name: "given direct tuples, usersets and inherited relation",
+			tuples: tuples{
+				"documents:doc_3#viewer@user:alice",
+				"documents:doc_1#viewer@group:eng#member",
+				"documents:doc_2#editor@user:alice",
+				"documents:doc_4#viewer@user:bob",
+				"group:eng#member@user:alice",
+			},
+			inheritances: []Inheritance{editor},
+			relation:     "viewer",
+			// Objects are found in order of how far away from the subject they are, doc_3 is a single tuple away
+			expected: []string{"doc_3", "doc_2", "doc_1"},
+		},
+		{
+			name: "given cycle of usersets",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:a#member",
+				"group:a#member@group:b#member",
+				"group:b#member@group:a#member",
+				"group:b#member@user:alice",
+			},
+			relation: "viewer",
+			expected: []string{"doc_1"},
+		},
+		{
+			name: "given chain deeper than maximum depth",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:a#member",
+				"group:a#member@group:b#member",
+				"group:b#member@user:alice",
+			},
+			opts:     []CheckOption{WithMaxDepth(1)},
+			relation: "viewer",
+			err:      ErrMaxDepth,
+		},
+		{
+			name: "given userset with condition",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			relation: "viewer",
+			err:      ErrConditionalRelation,
+		},
+		{
+			name: "given userset with condition along with unconditional tuple",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"documents:doc_2#viewer@user:alice",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			relation: "viewer",
+			// The objects held unconditionally are found before the lookup fails
+			expected: []string{"doc_2"},
+			err:      ErrConditionalRelation,
+		},
+		{
+			name: "given userset with condition not affecting the objects",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"documents:doc_1#viewer@user:alice",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			relation: "viewer",
+			expected: []string{"doc_1"},
+		},
+		{
+			name:     "given missing relation",
+			tuples:   tuples{},
+			relation: "",
+			err:      ErrMalformedTuple,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			c, err := NewChecker(m.tuples, m.inheritances, m.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			alice := Subject{Namespace: "user", Object: "alice"}
+			var objects []string
+			err = c.LookupResources(context.Background(), "documents", m.relation, alice, func(object string) error {
+				objects = append(objects, object)
+				return nil
+			})
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if !reflect.DeepEqual(objects, m.expected) {
+				t.Fatalf("got %v; want %v", objects, m.expected)
+			}
+		})
+	}
+}
+
+func TestChecker_LookupSubjects(t *testing.T) {
+	editor := Inheritance{
+		Parent: Relationship{Namespace: Namespace{Name: "documents"}, Name: "editor"},
+		Child:  Relationship{Namespace: Namespace{Name: "documents"}, Name: "viewer"},
+	}
+	// Membership of a group is only held from within the internal network
+	internal := WithConditions(&Relationship{
+		Namespace: Namespace{Name: "group"},
+		Name:      "member",
+		Condition: `request.ip in cidr("10.0.0.0/8")`,
+	})
+	matrix := []struct {
+		name             string
+		tuples           tuples
+		inheritances     []Inheritance
+		opts             []CheckOption
+		relation         string
+		subjectNamespace string
+		expected         []string
+		err              error
+	}{
+		{
+			name: "given direct tuples, usersets and inherited relation",
+			tuples: tuples{
+				"documents:doc_1#viewer@user:carol",
+				"documents:doc_1#viewer@group:eng#member",
+				"documents:doc_1#editor@user:alice",
+				"documents:doc_2#viewer@user:dave",
+				"group:eng#member@user:bob",
+				"group:eng#member@user:carol",
+			},
+			inheritances: []Inheritance{editor},
+			relation:     "viewer",
+			// Subjects are found in order of how far away from the object they are, carol is a single tuple away
+			expected: []string{"user:carol", "user:bob", "user:alice"},
+		},
+		{
+			name: "given subject namespace",
+			tuples: tuples{
+				"documents:doc_1#viewer@user:alice",
+				"documents:doc_1#viewer@service:indexer",
+			},
+			relation:         "viewer",
+			subjectNamespace: "service",
+			expected:         []string{"service:indexer"},
+		},
+		{
+			name: "given cycle of usersets",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:a#member",
+				"group:a#member@group:b#member",
+				"group:b#member@group:a#member",
+				"group:b#member@user:alice",
+			},
+			relation: "viewer",
+			expected: []string{"user:alice"},
+		},
+		{
+			name: "given chain deeper than maximum depth",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:a#member",
+				"group:a#member@group:b#member",
+				"group:b#member@user:alice",
+			},
+			opts:     []CheckOption{WithMaxDepth(1)},
+			relation: "viewer",
+			err:      ErrMaxDepth,
+		},
+		{
+			name: "given userset with condition",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			relation: "viewer",
+			err:      ErrConditionalRelation,
+		},
+		{
+			name: "given userset with condition not affecting the subjects",
+			tuples: tuples{
+				"documents:doc_1#viewer@group:eng#member",
+				"documents:doc_1#viewer@user:alice",
+				"group:eng#member@user:alice",
+			},
+			opts:     []CheckOption{internal},
+			relation: "viewer",
+			expected: []string{"user:alice"},
+		},
+		{
+			name:   "given missing relation",
+			tuples: tuples{},
+			err:    ErrMalformedTuple,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			c, err := NewChecker(m.tuples, m.inheritances, m.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rendered []string
+			err = c.LookupSubjects(context.Background(), "documents", "doc_1", m.relation, m.subjectNamespace,
+				func(s Subject) error {
+					rendered = append(rendered, s.String())
+					return nil
+				})
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if !reflect.DeepEqual(rendered, m.expected) {
+				t.Fatalf("got %v; want %v", rendered, m.expected)
+			}
+		})
+	}
+}
+
+func TestChecker_Lookup_Stop(t *testing.T) {
+	c, err := NewChecker(tuples{
+		"documents:doc_1#viewer@user:alice",
+		"documents:doc_2#viewer@user:alice",
+		"documents:doc_1#viewer@user:bob",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stop")
+	var objects []string
+	alice := Subject{Namespace: "user", Object: "alice"}
+	err = c.LookupResources(context.Background(), "documents", "viewer", alice, func(object string) error {
+		objects = append(objects, object)
+		return stop
+	})
+	if !errors.Is(err, stop) || len(objects) != 1 {
+		t.Fatalf("got %v after %v; want %v after a single object", err, objects, stop)
+	}
+	var subjects []Subject
+	err = c.LookupSubjects(context.Background(), "documents", "doc_1", "viewer", "", func(s Subject) error {
+		subjects = append(subjects, s)
+		return stop
+	})
+	if !errors.Is(err, stop) || len(subjects) != 1 {
+		t.Fatalf("got %v after %v; want %v after a single subject", err, subjects, stop)
+	}
+}
@@ -0,0 +1,372 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: api/gatekeeper/v1/gatekeeper.proto
+
+package gatekeeperv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AuthorizationClient is the client API for Authorization service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuthorizationClient interface {
+	// Decides whether the principal may perform the operation on the resource. The principal, as the subject
+	// principal:<principal_id>, is first decided on by the policies, like Decide does, and a policy applying to it
+	// settles the decision. Without one the principal may perform the operation if it holds the relation named by the
+	// operation on the resource through relation tuples, directly or through usersets and inherited relations.
+	// Relationships with a condition are only held if the condition holds for the attributes of the request, missing or
+	// malformed attributes read by the condition return the INVALID_ARGUMENT status. If the principal is granted the
+	// operation then granted is true and no challenge is returned. If a challenge is returned then the principal is not
+	// granted the operation as is, but would be if the condition of the challenged relation held, and it is on the
+	// client to perform a follow-up request with attributes satisfying it, such as after a step-up authentication.
+	// Depending on the sensitivity of the data a response with a challenge could be cached but if it is it should only
+	// be for a short duration of time. If the operation is not permitted then either granted is false without a
+	// challenge, when a policy denies the principal the operation, or the PERMISSION_DENIED status is returned, when
+	// nothing permits the operation. Unknown principals and operations return the NOT_FOUND status.
+	Authorize(ctx context.Context, in *AuthorizeRequest, opts ...grpc.CallOption) (*AuthorizeResponse, error)
+	// Returns the tree of every subject holding the relation on the object, made up of the direct subjects of the
+	// relation, the usersets they hold it through and the relations implying it. Intended to answer why a subject holds
+	// a relation rather than whether it does, use Authorize for the latter. Trees reaching a relationship with a
+	// condition return the FAILED_PRECONDITION status, as the condition is only evaluated for the attributes of a
+	// single request.
+	Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error)
+	// Streams the objects of the namespace the subject holds the relation on, such as every document a principal may
+	// view, as the lookup finds them, nearest to the subject first. At most page_size objects are streamed, each with a
+	// cursor. The lookup is paused after the last object of a page, and the cursor of that object resumes it where it
+	// left off. Resuming returns the NOT_FOUND status once the lookup has completed or has been paused for more than a
+	// minute, the FAILED_PRECONDITION status for the cursor of any other object and the INVALID_ARGUMENT status for a
+	// request differing from the one the cursor was sent for.
+	// Once every object has been streamed, the FAILED_PRECONDITION status is returned if the subject holds the relation
+	// on further objects only through a relationship with a condition.
+	LookupResources(ctx context.Context, in *LookupResourcesRequest, opts ...grpc.CallOption) (Authorization_LookupResourcesClient, error)
+	// Streams the direct subjects holding the relation on the object, such as every principal who may edit a document,
+	// as the lookup finds them, nearest to the object first. Pages and cursors work as for LookupResources. Once every
+	// subject has been streamed, the FAILED_PRECONDITION status is returned if further subjects hold the relation only
+	// through a relationship with a condition.
+	LookupSubjects(ctx context.Context, in *LookupSubjectsRequest, opts ...grpc.CallOption) (Authorization_LookupSubjectsClient, error)
+	// Decides whether the subject may perform the operation on the resource from the policies permitting and forbidding
+	// it, combined by the algorithm the server is configured with. Policies naming a userset apply to its members, as
+	// long as the conditions of the relationships the membership is held through hold for the attributes of the
+	// request. The PERMISSION_DENIED status is returned when no policy applies to the subject.
+	Decide(ctx context.Context, in *DecideRequest, opts ...grpc.CallOption) (*DecideResponse, error)
+}
+
+type authorizationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthorizationClient(cc grpc.ClientConnInterface) AuthorizationClient {
+	return &authorizationClient{cc}
+}
+
+func (c *authorizationClient) Authorize(ctx context.Context, in *AuthorizeRequest, opts ...grpc.CallOption) (*AuthorizeResponse, error) {
+	out := new(AuthorizeResponse)
+	err := c.cc.Invoke(ctx, "/gatekeeper.v1.Authorization/Authorize", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizationClient) Expand(ctx context.Context, in *ExpandRequest, opts ...grpc.CallOption) (*ExpandResponse, error) {
+	out := new(ExpandResponse)
+	err := c.cc.Invoke(ctx, "/gatekeeper.v1.Authorization/Expand", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authorizationClient) LookupResources(ctx context.Context, in *LookupResourcesRequest, opts ...grpc.CallOption) (Authorization_LookupResourcesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Authorization_ServiceDesc.Streams[0], "/gatekeeper.v1.Authorization/LookupResources", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &authorizationLookupResourcesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Authorization_LookupResourcesClient interface {
+	Recv() (*LookupResourcesResponse, error)
+	grpc.ClientStream
+}
+
+type authorizationLookupResourcesClient struct {
+	grpc.ClientStream
+}
+
+func (x *authorizationLookupResourcesClient) Recv() (*LookupResourcesResponse, error) {
+	m := new(LookupResourcesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *authorizationClient) LookupSubjects(ctx context.Context, in *LookupSubjectsRequest, opts ...grpc.CallOption) (Authorization_LookupSubjectsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Authorization_ServiceDesc.Streams[1], "/gatekeeper.v1.Authorization/LookupSubjects", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &authorizationLookupSubjectsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Authorization_LookupSubjectsClient interface {
+	Recv() (*LookupSubjectsResponse, error)
+	grpc.ClientStream
+}
+
+type authorizationLookupSubjectsClient struct {
+	grpc.ClientStream
+}
+
+func (x *authorizationLookupSubjectsClient) Recv() (*LookupSubjectsResponse, error) {
+	m := new(LookupSubjectsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *authorizationClient) Decide(ctx context.Context, in *DecideRequest, opts ...grpc.CallOption) (*DecideResponse, error) {
+	out := new(DecideResponse)
+	err := c.cc.Invoke(ctx, "/gatekeeper.v1.Authorization/Decide", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthorizationServer is the server API for Authorization service.
+// All implementations must embed UnimplementedAuthorizationServer
+// for forward compatibility
+type AuthorizationServer interface {
+	// Decides whether the principal may perform the operation on the resource. The principal, as the subject
+	// principal:<principal_id>, is first decided on by the policies, like Decide does, and a policy applying to it
+	// settles the decision. Without one the principal may perform the operation if it holds the relation named by the
+	// operation on the resource through relation tuples, directly or through usersets and inherited relations.
+	// Relationships with a condition are only held if the condition holds for the attributes of the request, missing or
+	// malformed attributes read by the condition return the INVALID_ARGUMENT status. If the principal is granted the
+	// operation then granted is true and no challenge is returned. If a challenge is returned then the principal is not
+	// granted the operation as is, but would be if the condition of the challenged relation held, and it is on the
+	// client to perform a follow-up request with attributes satisfying it, such as after a step-up authentication.
+	// Depending on the sensitivity of the data a response with a challenge could be cached but if it is it should only
+	// be for a short duration of time. If the operation is not permitted then either granted is false without a
+	// challenge, when a policy denies the principal the operation, or the PERMISSION_DENIED status is returned, when
+	// nothing permits the operation. Unknown principals and operations return the NOT_FOUND status.
+	Authorize(context.Context, *AuthorizeRequest) (*AuthorizeResponse, error)
+	// Returns the tree of every subject holding the relation on the object, made up of the direct subjects of the
+	// relation, the usersets they hold it through and the relations implying it. Intended to answer why a subject holds
+	// a relation rather than whether it does, use Authorize for the latter. Trees reaching a relationship with a
+	// condition return the FAILED_PRECONDITION status, as the condition is only evaluated for the attributes of a
+	// single request.
+	Expand(context.Context, *ExpandRequest) (*ExpandResponse, error)
+	// Streams the objects of the namespace the subject holds the relation on, such as every document a principal may
+	// view, as the lookup finds them, nearest to the subject first. At most page_size objects are streamed, each with a
+	// cursor. The lookup is paused after the last object of a page, and the cursor of that object resumes it where it
+	// left off. Resuming returns the NOT_FOUND status once the lookup has completed or has been paused for more than a
+	// minute, the FAILED_PRECONDITION status for the cursor of any other object and the INVALID_ARGUMENT status for a
+	// request differing from the one the cursor was sent for.
+	// Once every object has been streamed, the FAILED_PRECONDITION status is returned if the subject holds the relation
+	// on further objects only through a relationship with a condition.
+	LookupResources(*LookupResourcesRequest, Authorization_LookupResourcesServer) error
+	// Streams the direct subjects holding the relation on the object, such as every principal who may edit a document,
+	// as the lookup finds them, nearest to the object first. Pages and cursors work as for LookupResources. Once every
+	// subject has been streamed, the FAILED_PRECONDITION status is returned if further subjects hold the relation only
+	// through a relationship with a condition.
+	LookupSubjects(*LookupSubjectsRequest, Authorization_LookupSubjectsServer) error
+	// Decides whether the subject may perform the operation on the resource from the policies permitting and forbidding
+	// it, combined by the algorithm the server is configured with. Policies naming a userset apply to its members, as
+	// long as the conditions of the relationships the membership is held through hold for the attributes of the
+	// request. The PERMISSION_DENIED status is returned when no policy applies to the subject.
+	Decide(context.Context, *DecideRequest) (*DecideResponse, error)
+	mustEmbedUnimplementedAuthorizationServer()
+}
+
+// UnimplementedAuthorizationServer must be embedded to have forward compatible implementations.
+type UnimplementedAuthorizationServer struct {
+}
+
+func (UnimplementedAuthorizationServer) Authorize(context.Context, *AuthorizeRequest) (*AuthorizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Authorize not implemented")
+}
+func (UnimplementedAuthorizationServer) Expand(context.Context, *ExpandRequest) (*ExpandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Expand not implemented")
+}
+func (UnimplementedAuthorizationServer) LookupResources(*LookupResourcesRequest, Authorization_LookupResourcesServer) error {
+	return status.Errorf(codes.Unimplemented, "method LookupResources not implemented")
+}
+func (UnimplementedAuthorizationServer) LookupSubjects(*LookupSubjectsRequest, Authorization_LookupSubjectsServer) error {
+	return status.Errorf(codes.Unimplemented, "method LookupSubjects not implemented")
+}
+func (UnimplementedAuthorizationServer) Decide(context.Context, *DecideRequest) (*DecideResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Decide not implemented")
+}
+func (UnimplementedAuthorizationServer) mustEmbedUnimplementedAuthorizationServer() {}
+
+// UnsafeAuthorizationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthorizationServer will
+// result in compilation errors.
+type UnsafeAuthorizationServer interface {
+	mustEmbedUnimplementedAuthorizationServer()
+}
+
+func RegisterAuthorizationServer(s grpc.ServiceRegistrar, srv AuthorizationServer) {
+	s.RegisterService(&Authorization_ServiceDesc, srv)
+}
+
+func _Authorization_Authorize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthorizeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizationServer).Authorize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gatekeeper.v1.Authorization/Authorize",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizationServer).Authorize(ctx, req.(*AuthorizeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Authorization_Expand_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpandRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizationServer).Expand(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gatekeeper.v1.Authorization/Expand",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizationServer).Expand(ctx, req.(*ExpandRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Authorization_LookupResources_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LookupResourcesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AuthorizationServer).LookupResources(m, &authorizationLookupResourcesServer{stream})
+}
+
+type Authorization_LookupResourcesServer interface {
+	Send(*LookupResourcesResponse) error
+	grpc.ServerStream
+}
+
+type authorizationLookupResourcesServer struct {
+	grpc.ServerStream
+}
+
+func (x *authorizationLookupResourcesServer) Send(m *LookupResourcesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Authorization_LookupSubjects_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LookupSubjectsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AuthorizationServer).LookupSubjects(m, &authorizationLookupSubjectsServer{stream})
+}
+
+type Authorization_LookupSubjectsServer interface {
+	Send(*LookupSubjectsResponse) error
+	grpc.ServerStream
+}
+
+type authorizationLookupSubjectsServer struct {
+	grpc.ServerStream
+}
+
+func (x *authorizationLookupSubjectsServer) Send(m *LookupSubjectsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Authorization_Decide_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecideRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthorizationServer).Decide(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gatekeeper.v1.Authorization/Decide",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthorizationServer).Decide(ctx, req.(*DecideRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Authorization_ServiceDesc is the grpc.ServiceDesc for Authorization service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Authorization_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gatekeeper.v1.Authorization",
+	HandlerType: (*AuthorizationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Authorize",
+			Handler:    _Authorization_Authorize_Handler,
+		},
+		{
+			MethodName: "Expand",
+			Handler:    _Authorization_Expand_Handler,
+		},
+		{
+			MethodName: "Decide",
+			Handler:    _Authorization_Decide_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "LookupResources",
+			Handler:       _Authorization_LookupResources_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "LookupSubjects",
+			Handler:       _Authorization_LookupSubjects_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/gatekeeper/v1/gatekeeper.proto",
+}
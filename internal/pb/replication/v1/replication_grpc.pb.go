@@ -0,0 +1,207 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: api/replication/v1/replication.proto
+
+package replicationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ReplicationClient is the client API for Replication service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReplicationClient interface {
+	// Streams every commit made to the primary after the provided transaction, followed by new commits as they are made.
+	// If the primary no longer holds every commit requested then a snapshot of the entire store is sent first. The stream
+	// is ended by the primary if the follower is not keeping up, in which case the follower is expected to reconnect.
+	Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Replication_StreamClient, error)
+	// Streams a backup of the gaslight file of the primary, as written by gatekeeper db backup, in chunks. The backup is
+	// incremental if since is set and full otherwise. It is the only way of backing up a running primary, which holds
+	// an exclusive lock on its file.
+	Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (Replication_BackupClient, error)
+}
+
+type replicationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReplicationClient(cc grpc.ClientConnInterface) ReplicationClient {
+	return &replicationClient{cc}
+}
+
+func (c *replicationClient) Stream(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (Replication_StreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Replication_ServiceDesc.Streams[0], "/gatekeeper.replication.v1.Replication/Stream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &replicationStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Replication_StreamClient interface {
+	Recv() (*Commit, error)
+	grpc.ClientStream
+}
+
+type replicationStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *replicationStreamClient) Recv() (*Commit, error) {
+	m := new(Commit)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *replicationClient) Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (Replication_BackupClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Replication_ServiceDesc.Streams[1], "/gatekeeper.replication.v1.Replication/Backup", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &replicationBackupClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Replication_BackupClient interface {
+	Recv() (*BackupChunk, error)
+	grpc.ClientStream
+}
+
+type replicationBackupClient struct {
+	grpc.ClientStream
+}
+
+func (x *replicationBackupClient) Recv() (*BackupChunk, error) {
+	m := new(BackupChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReplicationServer is the server API for Replication service.
+// All implementations must embed UnimplementedReplicationServer
+// for forward compatibility
+type ReplicationServer interface {
+	// Streams every commit made to the primary after the provided transaction, followed by new commits as they are made.
+	// If the primary no longer holds every commit requested then a snapshot of the entire store is sent first. The stream
+	// is ended by the primary if the follower is not keeping up, in which case the follower is expected to reconnect.
+	Stream(*StreamRequest, Replication_StreamServer) error
+	// Streams a backup of the gaslight file of the primary, as written by gatekeeper db backup, in chunks. The backup is
+	// incremental if since is set and full otherwise. It is the only way of backing up a running primary, which holds
+	// an exclusive lock on its file.
+	Backup(*BackupRequest, Replication_BackupServer) error
+	mustEmbedUnimplementedReplicationServer()
+}
+
+// UnimplementedReplicationServer must be embedded to have forward compatible implementations.
+type UnimplementedReplicationServer struct {
+}
+
+func (UnimplementedReplicationServer) Stream(*StreamRequest, Replication_StreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stream not implemented")
+}
+func (UnimplementedReplicationServer) Backup(*BackupRequest, Replication_BackupServer) error {
+	return status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (UnimplementedReplicationServer) mustEmbedUnimplementedReplicationServer() {}
+
+// UnsafeReplicationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReplicationServer will
+// result in compilation errors.
+type UnsafeReplicationServer interface {
+	mustEmbedUnimplementedReplicationServer()
+}
+
+func RegisterReplicationServer(s grpc.ServiceRegistrar, srv ReplicationServer) {
+	s.RegisterService(&Replication_ServiceDesc, srv)
+}
+
+func _Replication_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReplicationServer).Stream(m, &replicationStreamServer{stream})
+}
+
+type Replication_StreamServer interface {
+	Send(*Commit) error
+	grpc.ServerStream
+}
+
+type replicationStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *replicationStreamServer) Send(m *Commit) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Replication_Backup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BackupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReplicationServer).Backup(m, &replicationBackupServer{stream})
+}
+
+type Replication_BackupServer interface {
+	Send(*BackupChunk) error
+	grpc.ServerStream
+}
+
+type replicationBackupServer struct {
+	grpc.ServerStream
+}
+
+func (x *replicationBackupServer) Send(m *BackupChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Replication_ServiceDesc is the grpc.ServiceDesc for Replication service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Replication_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gatekeeper.replication.v1.Replication",
+	HandlerType: (*ReplicationServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       _Replication_Stream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Backup",
+			Handler:       _Replication_Backup_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/replication/v1/replication.proto",
+}
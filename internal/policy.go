@@ -0,0 +1,184 @@
+package gatekeeper
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPolicy    = errors.New("invalid policy")
+	ErrUnknownAlgorithm = errors.New("unknown combining algorithm")
+)
+
+// Effect is whether a policy permits or forbids the operations it covers.
+type Effect string
+
+const (
+	Permit Effect = "permit"
+	Deny   Effect = "deny"
+)
+
+// Policy permits or forbids subjects to perform operations on resources. A policy applies to a subject it names or to
+// every member of a userset it names, such as group:eng#member.
+type Policy struct {
+	*entity.Entity
+	Effect   Effect
+	Subjects []Subject
+	// Operations are the qualified names of the relationships covered by the policy, such as documents:viewer.
+	Operations []string
+	// Resources are the IDs of the objects covered by the policy within the namespaces of its operations, or every
+	// object if empty.
+	Resources []string
+	// Priority orders the policies combined by FirstApplicable, lower priorities first and ties broken by ID.
+	Priority int
+	Created  time.Time
+	Updated  time.Time
+}
+
+// Validate returns an error wrapping ErrInvalidPolicy unless the policy has a known effect, at least one subject and
+// at least one operation of the form namespace:relation.
+func (p Policy) Validate() error {
+	if p.Effect != Permit && p.Effect != Deny {
+		return fmt.Errorf("%w: unknown effect %q", ErrInvalidPolicy, p.Effect)
+	}
+	if len(p.Subjects) == 0 || len(p.Operations) == 0 {
+		return fmt.Errorf("%w: a policy requires subjects and operations", ErrInvalidPolicy)
+	}
+	for _, s := range p.Subjects {
+		if s.Namespace == "" || s.Object == "" {
+			return fmt.Errorf("%w: subject %q requires a namespace and an object", ErrInvalidPolicy, s)
+		}
+	}
+	for _, op := range p.Operations {
+		if namespace, name, ok := strings.Cut(op, ":"); !ok || namespace == "" || name == "" {
+			return fmt.Errorf("%w: operation %q is not of the form namespace:relation", ErrInvalidPolicy, op)
+		}
+	}
+	return nil
+}
+
+// covers returns true if the policy covers the operation on the resource.
+func (p *Policy) covers(operation, resource string) bool {
+	return slices.Contains(p.Operations, operation) && (len(p.Resources) == 0 || slices.Contains(p.Resources, resource))
+}
+
+// PolicyStore persists policies indexed by the operations they cover.
+type PolicyStore interface {
+	Policy(ctx context.Context, id string) (*Policy, error)
+	// SavePolicy returns the error of Validate, without saving, if the policy is invalid. Every save raises a
+	// PolicyAllowed or PolicyDenied event on the policy, depending on its effect, which is stored along with it.
+	SavePolicy(ctx context.Context, p *Policy) error
+	// Policies returns every policy covering the operation, ordered by ID.
+	Policies(ctx context.Context, operation string) ([]*Policy, error)
+}
+
+// CombiningAlgorithm decides the outcome when several policies apply to a subject.
+type CombiningAlgorithm string
+
+const (
+	// DenyOverrides forbids the operation if any applicable policy denies it.
+	DenyOverrides CombiningAlgorithm = "deny-overrides"
+	// PermitOverrides permits the operation if any applicable policy permits it.
+	PermitOverrides CombiningAlgorithm = "permit-overrides"
+	// FirstApplicable lets the applicable policy of the lowest priority decide.
+	FirstApplicable CombiningAlgorithm = "first-applicable"
+)
+
+// Policies decides whether subjects may perform operations on resources by combining the policies which apply to them.
+type Policies struct {
+	store     PolicyStore
+	checker   *Checker
+	algorithm CombiningAlgorithm
+}
+
+// NewPolicies combines the stored policies with the provided algorithm, using the checker to decide whether a subject
+// is a member of the usersets named by policies.
+func NewPolicies(store PolicyStore, checker *Checker, algorithm CombiningAlgorithm) (*Policies, error) {
+	switch algorithm {
+	case DenyOverrides, PermitOverrides, FirstApplicable:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+	return &Policies{store: store, checker: checker, algorithm: algorithm}, nil
+}
+
+// Decide returns true if the policies applying to the subject permit the operation on the resource, which is the ID of
+// an object within the namespace of the operation. ErrNoExplicitPolicy is returned if no policy applies. Conditions of
+// the usersets named by policies are evaluated against the attributes.
+func (p *Policies) Decide(ctx context.Context, subject Subject, operation, resource string,
+	attrs condition.Attributes) (bool, error) {
+	policies, err := p.store.Policies(ctx, operation)
+	if err != nil {
+		return false, err
+	}
+	policies = slices.DeleteFunc(policies, func(policy *Policy) bool {
+		return !policy.covers(operation, resource)
+	})
+	// Policies of the effect which overrides the other are tried first, which settles the decision at the first one
+	// applying to the subject
+	switch p.algorithm {
+	case DenyOverrides:
+		slices.SortStableFunc(policies, func(a, b *Policy) int {
+			return cmp.Compare(effectOrder(a.Effect, Deny), effectOrder(b.Effect, Deny))
+		})
+	case PermitOverrides:
+		slices.SortStableFunc(policies, func(a, b *Policy) int {
+			return cmp.Compare(effectOrder(a.Effect, Permit), effectOrder(b.Effect, Permit))
+		})
+	case FirstApplicable:
+		slices.SortStableFunc(policies, func(a, b *Policy) int {
+			return cmp.Compare(a.Priority, b.Priority)
+		})
+	}
+	for _, policy := range policies {
+		applies, err := p.applies(ctx, policy, subject, attrs)
+		if err != nil {
+			return false, fmt.Errorf("policy %s: %w", policy.ID, err)
+		}
+		if applies {
+			return policy.Effect == Permit, nil
+		}
+	}
+	return false, fmt.Errorf("%w: %s for %s on %s", ErrNoExplicitPolicy, subject, operation, resource)
+}
+
+// effectOrder orders policies of the first effect before any other policies.
+func effectOrder(effect, first Effect) int {
+	if effect == first {
+		return 0
+	}
+	return 1
+}
+
+// applies returns true if the policy names the subject or a userset the subject is a member of. An error is only
+// returned if the membership of a userset could not be checked and the subject is not a member of any other userset.
+func (p *Policies) applies(ctx context.Context, policy *Policy, subject Subject,
+	attrs condition.Attributes) (bool, error) {
+	for _, s := range policy.Subjects {
+		if s == subject {
+			return true, nil
+		}
+	}
+	var errs []error
+	for _, s := range policy.Subjects {
+		if !s.Userset() {
+			continue
+		}
+		member, err := p.checker.Check(ctx, s.Namespace, s.Object, s.Relation, subject, attrs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if member {
+			return true, nil
+		}
+	}
+	return false, errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"slices"
+	"testing"
+)
+
+// policies is a read-only PolicyStore of the listed policies, which must be listed in order of ID.
+type policies []*Policy
+
+func (ps policies) Policy(_ context.Context, id string) (*Policy, error) {
+	for _, p := range ps {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (ps policies) SavePolicy(context.Context, *Policy) error {
+	return errors.New("not supported")
+}
+
+func (ps policies) Policies(_ context.Context, operation string) ([]*Policy, error) {
+	var found []*Policy
+	for _, p := range ps {
+		if slices.Contains(p.Operations, operation) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+func TestPolicies_Decide(t *testing.T) {
+	alice := Subject{Namespace: "user", Object: "alice"}
+	eng := Subject{Namespace: "group", Object: "eng", Relation: "member"}
+	// Checking the members of deep exceeds the maximum depth of the checker
+	deep := Subject{Namespace: "group", Object: "deep", Relation: "member"}
+	policy := func(id string, effect Effect, subject Subject, priority int, resources ...string) *Policy {
+		return &Policy{
+			Entity:     &entity.Entity{ID: id},
+			Effect:     effect,
+			Subjects:   []Subject{subject},
+			Operations: []string{"documents:viewer"},
+			Resources:  resources,
+			Priority:   priority,
+		}
+	}
+	matrix := []struct {
+		name      string
+		policies  policies
+		algorithm CombiningAlgorithm
+		expected  bool
+		err       error
+	}{
+		{
+			name:      "given deny overriding permit",
+			policies:  policies{policy("p-1", Permit, alice, 0), policy("p-2", Deny, eng, 0)},
+			algorithm: DenyOverrides,
+			expected:  false,
+		},
+		{
+			name:      "given permit overriding deny",
+			policies:  policies{policy("p-1", Deny, eng, 0), policy("p-2", Permit, alice, 0)},
+			algorithm: PermitOverrides,
+			expected:  true,
+		},
+		{
+			name:      "given first applicable by priority",
+			policies:  policies{policy("p-1", Deny, eng, 2), policy("p-2", Permit, alice, 1)},
+			algorithm: FirstApplicable,
+			expected:  true,
+		},
+		{
+			name:      "given first applicable tied by priority",
+			policies:  policies{policy("p-1", Deny, eng, 1), policy("p-2", Permit, alice, 1)},
+			algorithm: FirstApplicable,
+			expected:  false,
+		},
+		{
+			name:      "given policy of another resource",
+			policies:  policies{policy("p-1", Deny, alice, 0, "doc_2"), policy("p-2", Permit, alice, 0, "doc_1")},
+			algorithm: DenyOverrides,
+			expected:  true,
+		},
+		{
+			name:      "given policy of userset without alice",
+			policies:  policies{policy("p-1", Permit, Subject{Namespace: "group", Object: "ops", Relation: "member"}, 0)},
+			algorithm: PermitOverrides,
+			err:       ErrNoExplicitPolicy,
+		},
+		{
+			name: "given policy of failing userset followed by userset with alice",
+			policies: policies{{
+				Entity:     &entity.Entity{ID: "p-1"},
+				Effect:     Permit,
+				Subjects:   []Subject{deep, eng},
+				Operations: []string{"documents:viewer"},
+			}},
+			algorithm: PermitOverrides,
+			expected:  true,
+		},
+		{
+			name:      "given policy of failing userset only",
+			policies:  policies{policy("p-1", Permit, deep, 0)},
+			algorithm: PermitOverrides,
+			err:       ErrMaxDepth,
+		},
+		{
+			name:      "given no policies",
+			algorithm: DenyOverrides,
+			err:       ErrNoExplicitPolicy,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			checker, err := NewChecker(tuples{
+				"group:deep#member@group:deeper#member",
+				"group:deeper#member@group:deepest#member",
+				"group:eng#member@user:alice",
+			}, nil, WithMaxDepth(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := NewPolicies(m.policies, checker, m.algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			permitted, err := p.Decide(context.Background(), alice, "documents:viewer", "doc_1", condition.Attributes{})
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if permitted != m.expected {
+				t.Fatalf("got %t; want %t", permitted, m.expected)
+			}
+		})
+	}
+}
+
+func TestNewPolicies(t *testing.T) {
+	if _, err := NewPolicies(policies{}, nil, "only-one-applicable"); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Fatalf("got %v; want %v", err, ErrUnknownAlgorithm)
+	}
+}
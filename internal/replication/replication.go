@@ -0,0 +1,237 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	replicationv1 "github.com/ernilsson/gatekeeper/internal/pb/replication/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// buffer is the number of commits a follower may fall behind the primary before its stream is ended.
+	buffer = 1024
+	// maxBackoff is the longest a follower waits before reconnecting to the primary.
+	maxBackoff = 5 * time.Second
+	// chunkSize is the largest number of bytes of a backup sent in a single message.
+	chunkSize = 256 << 10
+)
+
+// NewPrimary creates a replication server which streams the commits of the provided DB to followers.
+func NewPrimary(db *gaslight.DB) *Primary {
+	return &Primary{
+		db: db,
+	}
+}
+
+type Primary struct {
+	replicationv1.UnimplementedReplicationServer
+	db *gaslight.DB
+}
+
+func (p *Primary) Stream(req *replicationv1.StreamRequest, stream replicationv1.Replication_StreamServer) error {
+	// The subscription is made before reading the backlog to make sure that no commit is missed in between, commits
+	// found in both are only sent once
+	subscription := p.db.Subscribe(buffer)
+	defer subscription.Close()
+	backlog, err := p.db.Commits(req.After)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	last := req.After
+	for _, c := range backlog {
+		if err := stream.Send(p.encode(c)); err != nil {
+			return err
+		}
+		last = c.TxID
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case c, ok := <-subscription.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "follower fell too far behind")
+			}
+			if !c.Snapshot && c.TxID <= last {
+				continue
+			}
+			if err := stream.Send(p.encode(c)); err != nil {
+				return err
+			}
+			last = c.TxID
+		}
+	}
+}
+
+// Backup streams a backup of the DB in chunks. The backup is written to a temporary file before it is sent, which
+// blocks writes to the DB only for as long as it takes to write the backup locally rather than for as long as the
+// client takes to receive it.
+func (p *Primary) Backup(req *replicationv1.BackupRequest, stream replicationv1.Replication_BackupServer) error {
+	f, err := os.CreateTemp("", "gatekeeper-backup-*")
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := p.db.Backup(f, req.Since); err != nil {
+		if errors.Is(err, gaslight.ErrBackupChain) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&replicationv1.BackupChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+// Backup writes a backup of the DB of the primary reached through conn to w, incremental if since is the TxID of the
+// header of a previous backup and full if it is zero.
+func Backup(ctx context.Context, conn grpc.ClientConnInterface, w io.Writer, since uint64) error {
+	stream, err := replicationv1.NewReplicationClient(conn).Backup(ctx, &replicationv1.BackupRequest{Since: since})
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Primary) encode(c gaslight.Commit) *replicationv1.Commit {
+	msg := &replicationv1.Commit{
+		TxId:     c.TxID,
+		Time:     c.Time,
+		Snapshot: c.Snapshot,
+		Frames:   make([]*replicationv1.Frame, 0, len(c.Frames)),
+		Head:     p.db.TxID(),
+	}
+	for _, frame := range c.Frames {
+		msg.Frames = append(msg.Frames, &replicationv1.Frame{
+			PageId: frame.PageID,
+			Data:   frame.Data,
+		})
+	}
+	return msg
+}
+
+// NewFollower creates a follower which keeps the provided DB up to date with the primary reached through conn. The DB
+// should be opened with gaslight.ReadOnly to make sure that all writes go through the primary.
+func NewFollower(db *gaslight.DB, conn grpc.ClientConnInterface) *Follower {
+	return &Follower{
+		db:     db,
+		client: replicationv1.NewReplicationClient(conn),
+	}
+}
+
+type Follower struct {
+	db     *gaslight.DB
+	client replicationv1.ReplicationClient
+	// head is the last transaction known to be committed on the primary and applied is the commit time of the last
+	// transaction applied to the follower.
+	head    atomic.Uint64
+	applied atomic.Int64
+}
+
+// Lag describes how far a follower is behind its primary.
+type Lag struct {
+	// Transactions is the number of transactions committed on the primary that are yet to be applied.
+	Transactions uint64
+	// Delay is the time passed since the commit of the last applied transaction, or zero if the follower has caught
+	// up with the primary.
+	Delay time.Duration
+}
+
+// Lag returns how far the follower is behind the primary, as of the last commit received.
+func (f *Follower) Lag() Lag {
+	head, txid := f.head.Load(), f.db.TxID()
+	if head <= txid {
+		return Lag{}
+	}
+	return Lag{
+		Transactions: head - txid,
+		Delay:        time.Since(time.Unix(0, f.applied.Load())),
+	}
+}
+
+// Run streams commits from the primary and applies them until the context is cancelled. Whenever the stream ends it
+// is resumed from the last applied transaction.
+func (f *Follower) Run(ctx context.Context) error {
+	backoff := 100 * time.Millisecond
+	for {
+		err := f.stream(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			backoff = 100 * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+func (f *Follower) stream(ctx context.Context) error {
+	stream, err := f.client.Stream(ctx, &replicationv1.StreamRequest{
+		After: f.db.TxID(),
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		f.head.Store(msg.Head)
+		c := gaslight.Commit{
+			TxID:     msg.TxId,
+			Time:     msg.Time,
+			Snapshot: msg.Snapshot,
+			Frames:   make([]gaslight.Frame, 0, len(msg.Frames)),
+		}
+		for _, frame := range msg.Frames {
+			c.Frames = append(c.Frames, gaslight.Frame{
+				PageID: frame.PageId,
+				Data:   frame.Data,
+			})
+		}
+		if err := f.db.Apply(c); err != nil {
+			return err
+		}
+		f.applied.Store(c.Time)
+	}
+}
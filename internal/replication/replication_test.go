@@ -0,0 +1,201 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	replicationv1 "github.com/ernilsson/gatekeeper/internal/pb/replication/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestPrimaryProcess is not a test of its own, it runs the primary in a separate process when started by
+// TestReplication. The primary listens on a loopback socket, prints its address and then inserts every line read from
+// stdin as a principal until stdin is closed.
+func TestPrimaryProcess(t *testing.T) {
+	path := os.Getenv("GATEKEEPER_PRIMARY_DB")
+	if path == "" {
+		t.Skip("only run as the primary process of TestReplication")
+	}
+	db, err := gaslight.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	principals, err := db.CreateCollection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := principals.Insert([]byte("alice"), []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	replicationv1.RegisterReplicationServer(srv, NewPrimary(db))
+	go srv.Serve(lis)
+	defer srv.Stop()
+	fmt.Println(lis.Addr().String())
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if err := principals.Insert(scanner.Bytes(), []byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplication(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPrimaryProcess$")
+	cmd.Env = append(os.Environ(), "GATEKEEPER_PRIMARY_DB="+filepath.Join(t.TempDir(), "primary.db"))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+	lines := bufio.NewScanner(stdout)
+	if !lines.Scan() {
+		t.Fatalf("primary exited without printing its address: %v", lines.Err())
+	}
+	addr := lines.Text()
+
+	replica, err := gaslight.Open(filepath.Join(t.TempDir(), "replica.db"), gaslight.ReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	follower := NewFollower(replica, conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- follower.Run(ctx)
+	}()
+
+	// Commits made while the follower is connected are streamed as they happen
+	if _, err := fmt.Fprintln(stdin, "bob\ncarol"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		c, err := replica.Collection("principals")
+		if err == nil {
+			if _, err = c.Find([]byte("carol")); err == nil {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("follower did not catch up: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if lag := follower.Lag(); lag.Transactions != 0 || lag.Delay != 0 {
+		t.Fatalf("got %+v; want no lag", lag)
+	}
+	c, err := replica.Collection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := c.Find([]byte(name)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if err := c.Insert([]byte("mallory"), []byte("{}")); !errors.Is(err, gaslight.ErrReadOnly) {
+		t.Fatalf("got %v; want %v", err, gaslight.ErrReadOnly)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestPrimary_Backup(t *testing.T) {
+	dir := t.TempDir()
+	db, err := gaslight.Open(filepath.Join(dir, "primary.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	principals, err := db.CreateCollection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enough principals for the backup to be sent in several chunks
+	items := make([]*gaslight.Item, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		key := []byte(fmt.Sprintf("principal_%04d", i))
+		items = append(items, gaslight.NewItem(key, bytes.Repeat([]byte{'a'}, 256)))
+	}
+	if err := principals.PutBatch(items); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	replicationv1.RegisterReplicationServer(srv, NewPrimary(db))
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	backup := &bytes.Buffer{}
+	if err := Backup(context.Background(), conn, backup, 0); err != nil {
+		t.Fatal(err)
+	}
+	if backup.Len() <= chunkSize {
+		t.Fatalf("got a backup of %d bytes; want more than a single chunk", backup.Len())
+	}
+	if _, err := gaslight.Restore(filepath.Join(dir, "restored.db"), time.Now(), backup); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := gaslight.Open(filepath.Join(dir, "restored.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	c, err := restored.Collection("principals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Find([]byte("principal_1999")); err != nil {
+		t.Fatal(err)
+	}
+	err = Backup(context.Background(), conn, io.Discard, db.TxID()+1)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("got %v; want %v for a backup since a transaction ahead of the primary", err, codes.FailedPrecondition)
+	}
+}
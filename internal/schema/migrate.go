@@ -0,0 +1,392 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsafeChange = errors.New("unsafe schema change")
+)
+
+// Change is a difference between two versions of a schema.
+type Change struct {
+	// Pos is where the change is made in the new version, it is zero for removals.
+	Pos Position
+	Msg string
+	// Unsafe is true if the change breaks stored tuples or makes permissions inherit from themselves.
+	Unsafe bool
+}
+
+func (c Change) String() string {
+	var b strings.Builder
+	if c.Pos != (Position{}) {
+		fmt.Fprintf(&b, "%s: ", c.Pos)
+	}
+	b.WriteString(c.Msg)
+	if c.Unsafe {
+		b.WriteString(" (unsafe)")
+	}
+	return b.String()
+}
+
+// Diff returns the changes from one version of a schema to the next, where from is nil for the first version. Removing
+// a definition, relation or permission is only unsafe if stored tuples still reference it, and so is removing a subject
+// type from a relation or turning a relation into a permission. A permission which becomes part of an inheritance cycle
+// is always unsafe, as the cycle makes every permission on it hold every other.
+func Diff(ctx context.Context, tuples gatekeeper.TupleStore, from, to *Schema) ([]Change, error) {
+	if from == nil {
+		from = &Schema{}
+	}
+	d := differ{ctx: ctx, tuples: tuples}
+	for _, old := range from.Definitions {
+		definition := to.Definition(old.Name)
+		if definition == nil {
+			referenced, err := d.referenced(old.Name, "", nil)
+			if err != nil {
+				return nil, err
+			}
+			d.report(Position{}, referenced, "definition %s removed%s", old.Name, d.references(referenced))
+			continue
+		}
+		if err := d.definition(old, definition); err != nil {
+			return nil, err
+		}
+	}
+	for _, definition := range to.Definitions {
+		if from.Definition(definition.Name) == nil {
+			d.report(definition.Pos, 0, "definition %s added", definition.Name)
+		}
+	}
+	before, after := cycles(from), cycles(to)
+	for _, definition := range to.Definitions {
+		for _, p := range definition.Permissions {
+			name := definition.Name + "#" + p.Name
+			if after[name] && !before[name] {
+				d.changes = append(d.changes, Change{
+					Pos:    p.Pos,
+					Msg:    fmt.Sprintf("permission %s is part of a new inheritance cycle", name),
+					Unsafe: true,
+				})
+			}
+		}
+	}
+	return d.changes, nil
+}
+
+// differ collects the changes of a diff, looking up the stored tuples referencing removed parts of the schema.
+type differ struct {
+	ctx     context.Context
+	tuples  gatekeeper.TupleStore
+	changes []Change
+}
+
+// report adds a change which is unsafe if it affects any stored tuples.
+func (d *differ) report(pos Position, referenced int, format string, args ...any) {
+	d.changes = append(d.changes, Change{Pos: pos, Msg: fmt.Sprintf(format, args...), Unsafe: referenced > 0})
+}
+
+func (d *differ) references(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" but still referenced by %d stored tuples", n)
+}
+
+// referenced returns the number of stored tuples referencing the relation of the namespace, or any relation if it is
+// empty, which match the filter unless it is nil.
+func (d *differ) referenced(namespace, relation string, filter func(t gatekeeper.RelationTuple) bool) (int, error) {
+	tuples, err := d.tuples.RelationTuples(d.ctx, namespace, relation)
+	if err != nil {
+		return 0, err
+	}
+	if filter == nil {
+		return len(tuples), nil
+	}
+	n := 0
+	for _, t := range tuples {
+		if filter(t) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (d *differ) definition(old, definition *Definition) error {
+	for _, r := range old.Relations {
+		name := definition.Name + "#" + r.Name
+		relation, permission := definition.Relation(r.Name), definition.Permission(r.Name)
+		switch {
+		case relation == nil && permission == nil:
+			referenced, err := d.referenced(definition.Name, r.Name, nil)
+			if err != nil {
+				return err
+			}
+			d.report(Position{}, referenced, "relation %s removed%s", name, d.references(referenced))
+		case permission != nil:
+			// Usersets of the relation remain valid, but tuples can no longer be held on a permission
+			held, err := d.referenced(definition.Name, r.Name, func(t gatekeeper.RelationTuple) bool {
+				return t.Namespace == definition.Name && t.Relation == r.Name
+			})
+			if err != nil {
+				return err
+			}
+			d.report(permission.Pos, held, "relation %s became a permission%s", name, d.references(held))
+		default:
+			if err := d.types(name, r, relation); err != nil {
+				return err
+			}
+			d.condition(name, r, relation)
+		}
+	}
+	for _, p := range old.Permissions {
+		name := definition.Name + "#" + p.Name
+		relation, permission := definition.Relation(p.Name), definition.Permission(p.Name)
+		switch {
+		case relation == nil && permission == nil:
+			referenced, err := d.referenced(definition.Name, p.Name, nil)
+			if err != nil {
+				return err
+			}
+			d.report(Position{}, referenced, "permission %s removed%s", name, d.references(referenced))
+		case relation != nil:
+			d.report(relation.Pos, 0, "permission %s became a relation", name)
+		case !slices.Equal(names(p.Members), names(permission.Members)):
+			d.report(permission.Pos, 0, "permission %s changed from %s to %s", name,
+				strings.Join(names(p.Members), " + "), strings.Join(names(permission.Members), " + "))
+		}
+	}
+	for _, r := range definition.Relations {
+		if old.Relation(r.Name) == nil && old.Permission(r.Name) == nil {
+			d.report(r.Pos, 0, "relation %s#%s added", definition.Name, r.Name)
+		}
+	}
+	for _, p := range definition.Permissions {
+		if old.Relation(p.Name) == nil && old.Permission(p.Name) == nil {
+			d.report(p.Pos, 0, "permission %s#%s added", definition.Name, p.Name)
+		}
+	}
+	return nil
+}
+
+// types reports the subject types removed from and added to a relation, a removed type is unsafe if the relation is
+// held by stored tuples of subjects of the type.
+func (d *differ) types(name string, old, relation *Relation) error {
+	namespace, _, _ := strings.Cut(name, "#")
+	for _, t := range old.Types {
+		if slices.ContainsFunc(relation.Types, t.equal) {
+			continue
+		}
+		held, err := d.referenced(namespace, relation.Name, func(tuple gatekeeper.RelationTuple) bool {
+			return tuple.Namespace == namespace && tuple.Relation == relation.Name &&
+				tuple.Subject.Namespace == t.Namespace && tuple.Subject.Relation == t.Relation
+		})
+		if err != nil {
+			return err
+		}
+		d.report(relation.Pos, held, "subject type %s removed from relation %s%s", t, name, d.references(held))
+	}
+	for _, t := range relation.Types {
+		if !slices.ContainsFunc(old.Types, t.equal) {
+			d.report(t.Pos, 0, "subject type %s added to relation %s", t, name)
+		}
+	}
+	return nil
+}
+
+// condition reports a condition added to, removed from or changed on a relation. These are always safe, as stored
+// tuples remain valid whether or not the relation they hold is conditioned.
+func (d *differ) condition(name string, old, relation *Relation) {
+	from, to := old.Condition.source(), relation.Condition.source()
+	switch {
+	case from == to:
+	case from == "":
+		d.report(relation.Condition.Pos, 0, "condition %s added to relation %s", to, name)
+	case to == "":
+		d.report(relation.Pos, 0, "condition %s removed from relation %s", from, name)
+	default:
+		d.report(relation.Condition.Pos, 0, "condition of relation %s changed from %s to %s", name, from, to)
+	}
+}
+
+func (t SubjectType) equal(other SubjectType) bool {
+	return t.Namespace == other.Namespace && t.Relation == other.Relation
+}
+
+func names(references []Reference) []string {
+	names := make([]string, 0, len(references))
+	for _, r := range references {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// cycles returns the qualified names, such as document#view, of the permissions of the schema which inherit from
+// themselves through other permissions.
+func cycles(s *Schema) map[string]bool {
+	cyclic := make(map[string]bool)
+	for _, d := range s.Definitions {
+		for _, p := range d.Permissions {
+			visited := make(map[string]bool)
+			var reaches func(current *Permission) bool
+			reaches = func(current *Permission) bool {
+				for _, m := range current.Members {
+					if m.Name == p.Name {
+						return true
+					}
+					next := d.Permission(m.Name)
+					if next == nil || visited[m.Name] {
+						continue
+					}
+					visited[m.Name] = true
+					if reaches(next) {
+						return true
+					}
+				}
+				return false
+			}
+			if reaches(p) {
+				cyclic[d.Name+"#"+p.Name] = true
+			}
+		}
+	}
+	return cyclic
+}
+
+// Apply compiles the source and stores it as the latest version of the schema, along with its changes from the
+// previous version. Unsafe changes make Apply return an error wrapping ErrUnsafeChange, without storing the version,
+// unless forced. The changes are returned whether or not the version is stored. Once stored, the namespaces and
+// relationships of the version are persisted as described by persist, which Apply can be retried to complete.
+func Apply(ctx context.Context, store gatekeeper.Store, src string, force bool) (*gatekeeper.SchemaVersion, []Change, error) {
+	to, err := Compile(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	var from *Schema
+	previous, err := store.Schema(ctx, 0)
+	switch {
+	case errors.Is(err, gatekeeper.ErrNotFound):
+	case err != nil:
+		return nil, nil, err
+	default:
+		if from, err = Compile(previous.Source); err != nil {
+			return nil, nil, fmt.Errorf("schema version %d: %w", previous.Version, err)
+		}
+	}
+	changes, err := Diff(ctx, store, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := &gatekeeper.SchemaVersion{Source: src, Changes: make([]string, 0, len(changes))}
+	var unsafe int
+	for _, c := range changes {
+		v.Changes = append(v.Changes, c.String())
+		if c.Unsafe {
+			unsafe++
+		}
+	}
+	if unsafe > 0 && !force {
+		return nil, changes, fmt.Errorf("%w: %d of %d changes are unsafe", ErrUnsafeChange, unsafe, len(changes))
+	}
+	v.Forced = unsafe > 0
+	if err := store.WriteSchema(ctx, v); err != nil {
+		return nil, changes, err
+	}
+	if err := persist(ctx, store, from, to); err != nil {
+		return v, changes, fmt.Errorf("schema version %d: %w", v.Version, err)
+	}
+	return v, changes, nil
+}
+
+// persist saves the namespaces and relationships of the schema which are not stored yet, using the name of a namespace
+// and the qualified name of a relationship as their IDs, and deletes the stored relationships of the relations and
+// permissions removed since the previous version, which is nil for the first version. Relationships which remain part
+// of the schema are saved again if their stored condition differs from the one of the schema, where permissions never
+// have a condition.
+func persist(ctx context.Context, store gatekeeper.Store, from, to *Schema) error {
+	if from == nil {
+		from = &Schema{}
+	}
+	var namespaces []string
+	for _, d := range slices.Concat(from.Definitions, to.Definitions) {
+		if !slices.Contains(namespaces, d.Name) {
+			namespaces = append(namespaces, d.Name)
+		}
+	}
+	now := time.Now()
+	for _, name := range namespaces {
+		old, definition := from.Definition(name), to.Definition(name)
+		stored, err := store.Relationships(ctx, name)
+		if err != nil {
+			return err
+		}
+		held := make(map[string]bool, len(stored))
+		for _, r := range stored {
+			held[r.Name] = true
+			switch {
+			case definition != nil && definition.defines(r.Name):
+				if cond := definition.condition(r.Name); r.Condition != cond {
+					r.Condition, r.Updated = cond, now
+					if err := store.SaveRelationship(ctx, r); err != nil {
+						return err
+					}
+				}
+			case old != nil && old.defines(r.Name):
+				if err := store.DeleteRelationship(ctx, r.ID); err != nil {
+					return err
+				}
+			}
+		}
+		if definition == nil {
+			continue
+		}
+		namespace, err := persistNamespace(ctx, store, name, stored)
+		if err != nil {
+			return err
+		}
+		for _, member := range definition.members() {
+			if held[member] {
+				continue
+			}
+			r := &gatekeeper.Relationship{
+				Entity:    &entity.Entity{ID: name + ":" + member},
+				Namespace: namespace,
+				Name:      member,
+				Condition: definition.condition(member),
+				Created:   now,
+				Updated:   now,
+			}
+			if err := store.SaveRelationship(ctx, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// persistNamespace returns the namespace of the stored relationships, or the namespace stored under the name if there
+// are none, which is saved first if it is not stored either.
+func persistNamespace(ctx context.Context, store gatekeeper.Store, name string,
+	stored []*gatekeeper.Relationship) (gatekeeper.Namespace, error) {
+	if len(stored) > 0 {
+		return stored[0].Namespace, nil
+	}
+	n, err := store.Namespace(ctx, name)
+	switch {
+	case err == nil:
+		return *n, nil
+	case !errors.Is(err, gatekeeper.ErrNotFound):
+		return gatekeeper.Namespace{}, err
+	}
+	namespace := gatekeeper.Namespace{Entity: &entity.Entity{ID: name}, Name: name}
+	if err := store.SaveNamespace(ctx, &namespace); err != nil {
+		return gatekeeper.Namespace{}, err
+	}
+	return namespace, nil
+}
@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/store"
+	"reflect"
+	"testing"
+)
+
+// memory returns a memory store holding the provided tuples.
+func memory(t *testing.T, tuples ...string) *store.Memory {
+	t.Helper()
+	s := store.NewMemory()
+	for _, tuple := range tuples {
+		parsed, err := gatekeeper.ParseTuple(tuple)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.WriteTuples(context.Background(), parsed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestDiff(t *testing.T) {
+	base := "definition user {}\n" +
+		"definition group { relation member: user }\n" +
+		"definition document { relation owner: user; relation viewer: user | group#member; permission view = viewer }"
+	matrix := []struct {
+		name     string
+		from     string
+		to       string
+		tuples   []string
+		expected []string
+	}{
+		{
+			name: "given first version",
+			to:   "definition user {}\ndefinition group { relation member: user }",
+			expected: []string{
+				"1:1: definition user added",
+				"2:1: definition group added",
+			},
+		},
+		{
+			name: "given unchanged schema",
+			from: base,
+			to:   base,
+		},
+		{
+			name: "given removed relations with and without tuples",
+			from: base,
+			to: "definition user {}\n" +
+				"definition group { relation member: user }\n" +
+				"definition document { relation viewer: user | group#member; permission view = viewer }",
+			tuples: []string{"document:doc_1#viewer@user:alice"},
+			expected: []string{
+				"relation document#owner removed",
+			},
+		},
+		{
+			name: "given removed relation referenced by tuples",
+			from: base,
+			to: "definition user {}\n" +
+				"definition group { relation admin: user }\n" +
+				"definition document { relation owner: user; relation viewer: user; permission view = viewer }",
+			tuples: []string{"document:doc_1#viewer@group:eng#member"},
+			expected: []string{
+				"relation group#member removed but still referenced by 1 stored tuples (unsafe)",
+				"2:20: relation group#admin added",
+				"3:45: subject type group#member removed from relation document#viewer but still referenced by 1 stored " +
+					"tuples (unsafe)",
+			},
+		},
+		{
+			name: "given added subject type and removed unused subject type",
+			from: base,
+			to: "definition user {}\n" +
+				"definition group { relation member: user }\n" +
+				"definition document { relation owner: user | group#member; relation viewer: user; permission view = viewer }",
+			tuples: []string{"document:doc_1#viewer@user:alice"},
+			expected: []string{
+				"3:46: subject type group#member added to relation document#owner",
+				"3:60: subject type group#member removed from relation document#viewer",
+			},
+		},
+		{
+			name: "given relation turned into permission",
+			from: base,
+			to: "definition user {}\n" +
+				"definition group { relation member: user }\n" +
+				"definition document { relation viewer: user | group#member; permission owner = viewer; " +
+				"permission view = viewer }",
+			tuples: []string{"document:doc_1#owner@user:alice"},
+			expected: []string{
+				"3:61: relation document#owner became a permission but still referenced by 1 stored tuples (unsafe)",
+			},
+		},
+		{
+			name: "given added, changed and removed conditions",
+			from: "definition user {}\n" +
+				"definition group { relation member: user }\n" +
+				"definition document { relation owner: user if true; relation viewer: user if request.ip == \"::1\" }",
+			to: "definition user {}\n" +
+				"definition group { relation member: user if request.ip != \"::1\" }\n" +
+				"definition document { relation owner: user; relation viewer: user if request.ip != \"::1\" }",
+			tuples: []string{"document:doc_1#viewer@user:alice"},
+			expected: []string{
+				"2:45: condition request.ip != \"::1\" added to relation group#member",
+				"3:23: condition true removed from relation document#owner",
+				"3:70: condition of relation document#viewer changed from request.ip == \"::1\" to " +
+					"request.ip != \"::1\"",
+			},
+		},
+		{
+			name: "given new inheritance cycle",
+			from: base,
+			to: "definition user {}\n" +
+				"definition group { relation member: user }\n" +
+				"definition document { relation owner: user; relation viewer: user | group#member; " +
+				"permission view = viewer + edit; permission edit = owner + view }",
+			expected: []string{
+				"3:83: permission document#view changed from viewer to viewer + edit",
+				"3:116: permission document#edit added",
+				"3:83: permission document#view is part of a new inheritance cycle (unsafe)",
+				"3:116: permission document#edit is part of a new inheritance cycle (unsafe)",
+			},
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			var from *Schema
+			if m.from != "" {
+				var err error
+				if from, err = Compile(m.from); err != nil {
+					t.Fatal(err)
+				}
+			}
+			to, err := Compile(m.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := Diff(context.Background(), memory(t, m.tuples...), from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, m.expected) {
+				t.Fatalf("got %q; want %q", got, m.expected)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	s := memory(t, "document:doc_1#owner@user:alice")
+	v1 := "definition user {}\ndefinition document { relation owner: user }"
+	v2 := "definition user {}\ndefinition document { relation viewer: user }"
+	if _, _, err := Apply(ctx, s, v1, false); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Namespace(ctx, "user"); err != nil || n.Name != "user" {
+		t.Fatalf("got %v, %v; want namespace user to be persisted", n, err)
+	}
+	relationships(t, s, "document", "owner")
+	if _, changes, err := Apply(ctx, s, v2, false); !errors.Is(err, ErrUnsafeChange) || len(changes) != 2 {
+		t.Fatalf("got %v with changes %v; want %v", err, changes, ErrUnsafeChange)
+	}
+	if latest, err := s.Schema(ctx, 0); err != nil || latest.Version != 1 {
+		t.Fatalf("got %v (%v); want version 1 to remain the latest", latest, err)
+	}
+	v, _, err := Apply(ctx, s, v2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 2 || !v.Forced {
+		t.Fatalf("got version %d forced %t; want forced version 2", v.Version, v.Forced)
+	}
+	relationships(t, s, "document", "viewer")
+	events, err := s.Events(ctx, gatekeeper.SchemaKind, gatekeeper.SchemaEntityID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events; want one for each applied version", len(events))
+	}
+	// Conditions follow the schema, whether the relationship is new or already stored
+	v3 := "definition user {}\ndefinition document { relation viewer: user if true; relation owner: user if false }"
+	if _, _, err := Apply(ctx, s, v3, false); err != nil {
+		t.Fatal(err)
+	}
+	conditions(t, s, "document", map[string]string{"viewer": "true", "owner": "false"})
+	v4 := "definition user {}\ndefinition document { relation viewer: user; relation owner: user if true }"
+	if _, _, err := Apply(ctx, s, v4, false); err != nil {
+		t.Fatal(err)
+	}
+	conditions(t, s, "document", map[string]string{"viewer": "", "owner": "true"})
+	if _, _, err := Apply(ctx, s, "definition", true); !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("got %v; want %v", err, ErrInvalidSchema)
+	}
+}
+
+// relationships fails the test unless the store holds exactly the relationships of the namespace with the provided
+// names, in order of ID.
+func relationships(t *testing.T, s gatekeeper.Store, namespace string, names ...string) {
+	t.Helper()
+	stored, err := s.Relationships(context.Background(), namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(stored))
+	for _, r := range stored {
+		got = append(got, r.Name)
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Fatalf("got relationships %v of %s; want %v", got, namespace, names)
+	}
+}
+
+// conditions fails the test unless the stored relationships of the namespace have the provided conditions, by name.
+func conditions(t *testing.T, s gatekeeper.Store, namespace string, expected map[string]string) {
+	t.Helper()
+	stored, err := s.Relationships(context.Background(), namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string, len(stored))
+	for _, r := range stored {
+		got[r.Name] = r.Condition
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got conditions %v of %s; want %v", got, namespace, expected)
+	}
+}
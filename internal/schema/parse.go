@@ -0,0 +1,317 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type kind int
+
+const (
+	eof kind = iota
+	ident
+	punct
+)
+
+type token struct {
+	kind kind
+	text string
+	pos  Position
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case eof:
+		return "end of schema"
+	case ident:
+		return fmt.Sprintf("%q", t.text)
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+// lexer splits a schema into identifiers and punctuation, skipping whitespace and comments, which run from // to the
+// end of the line.
+type lexer struct {
+	src    string
+	offset int
+	pos    Position
+}
+
+func (l *lexer) next() (token, error) {
+	l.skip()
+	if l.offset == len(l.src) {
+		return token{kind: eof, pos: l.pos}, nil
+	}
+	start, pos := l.offset, l.pos
+	r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		for l.offset < len(l.src) {
+			r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+			if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			l.advance()
+		}
+		return token{kind: ident, text: l.src[start:l.offset], pos: pos}, nil
+	case strings.ContainsRune("{}:;|#=+", r):
+		l.advance()
+		return token{kind: punct, text: l.src[start:l.offset], pos: pos}, nil
+	default:
+		return token{}, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+	}
+}
+
+func (l *lexer) skip() {
+	for l.offset < len(l.src) {
+		r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+		switch {
+		case unicode.IsSpace(r):
+			l.advance()
+		case r == '/' && l.offset+1 < len(l.src) && l.src[l.offset+1] == '/':
+			for l.offset < len(l.src) && l.src[l.offset] != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// condition scans the source of a condition, which runs from the current offset to the next ';' or '}' outside of a
+// string. Comments within the condition are replaced by spaces, which keeps the positions of the condition aligned
+// with the schema.
+func (l *lexer) condition() (string, Position) {
+	l.skip()
+	pos := l.pos
+	var b strings.Builder
+	quoted, escaped, comment := false, false, false
+	for l.offset < len(l.src) {
+		r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+		switch {
+		case comment:
+			comment = r != '\n'
+		case quoted:
+			quoted = r != '\n' && (r != '"' || escaped)
+			escaped = r == '\\' && !escaped
+		case r == '"':
+			quoted, escaped = true, false
+		case r == ';' || r == '}':
+			return strings.TrimRightFunc(b.String(), unicode.IsSpace), pos
+		case strings.HasPrefix(l.src[l.offset:], "//"):
+			comment = true
+		}
+		if comment {
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(r)
+		}
+		l.advance()
+	}
+	return strings.TrimRightFunc(b.String(), unicode.IsSpace), pos
+}
+
+// advance moves past the rune at the current offset, keeping track of its position.
+func (l *lexer) advance() {
+	r, size := utf8.DecodeRuneInString(l.src[l.offset:])
+	l.offset += size
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+}
+
+// parser is a recursive descent parser of the grammar below, where semicolons separate the relations and permissions of
+// a definition and may be left out after the last of them. The condition of a relation is an expression of the
+// condition package, which runs to the next ';' or '}' outside of a string.
+//
+//	schema     = { definition } .
+//	definition = "definition" ident "{" [ member { ";" member } [ ";" ] ] "}" .
+//	member     = relation | permission .
+//	relation   = "relation" ident ":" type { "|" type } [ "if" condition ] .
+//	type       = ident [ "#" ident ] .
+//	permission = "permission" ident "=" ident { "+" ident } .
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// Parse parses a schema without checking that the names it references are defined, which is left to Compile. The
+// returned error is an *Error pointing out the first syntax error of the schema.
+func Parse(src string) ([]*Definition, error) {
+	p := &parser{lex: &lexer{src: src, pos: Position{Line: 1, Column: 1}}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var definitions []*Definition
+	for p.tok.kind != eof {
+		d, err := p.definition()
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	return definitions, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// at returns true if the current token is the punctuation or keyword text.
+func (p *parser) at(text string) bool {
+	return p.tok.kind != eof && p.tok.text == text
+}
+
+// expect consumes the current token if it is the punctuation or keyword text.
+func (p *parser) expect(text string) error {
+	if !p.at(text) {
+		return p.unexpected(fmt.Sprintf("'%s'", text))
+	}
+	return p.advance()
+}
+
+// name consumes the current token if it is an identifier and returns it.
+func (p *parser) name(what string) (token, error) {
+	tok := p.tok
+	if tok.kind != ident {
+		return token{}, p.unexpected(what)
+	}
+	return tok, p.advance()
+}
+
+func (p *parser) unexpected(expected string) error {
+	return &Error{Pos: p.tok.pos, Msg: fmt.Sprintf("expected %s, found %s", expected, p.tok)}
+}
+
+func (p *parser) definition() (*Definition, error) {
+	pos := p.tok.pos
+	if err := p.expect("definition"); err != nil {
+		return nil, err
+	}
+	name, err := p.name("definition name")
+	if err != nil {
+		return nil, err
+	}
+	d := &Definition{Pos: pos, Name: name.text}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.at("}") {
+		if err := p.member(d); err != nil {
+			return nil, err
+		}
+		if p.at(";") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !p.at("}") {
+			return nil, p.unexpected("';' or '}'")
+		}
+	}
+	return d, p.advance()
+}
+
+func (p *parser) member(d *Definition) error {
+	pos := p.tok.pos
+	switch {
+	case p.at("relation"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		name, err := p.name("relation name")
+		if err != nil {
+			return err
+		}
+		r := &Relation{Pos: pos, Name: name.text}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		for {
+			t, err := p.subjectType()
+			if err != nil {
+				return err
+			}
+			r.Types = append(r.Types, t)
+			if !p.at("|") {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+		if p.at("if") {
+			src, pos := p.lex.condition()
+			if src == "" {
+				return &Error{Pos: pos, Msg: "expected condition"}
+			}
+			r.Condition = &Condition{Pos: pos, Source: src}
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+		d.Relations = append(d.Relations, r)
+		return nil
+	case p.at("permission"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		name, err := p.name("permission name")
+		if err != nil {
+			return err
+		}
+		perm := &Permission{Pos: pos, Name: name.text}
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		for {
+			member, err := p.name("relation or permission name")
+			if err != nil {
+				return err
+			}
+			perm.Members = append(perm.Members, Reference{Pos: member.pos, Name: member.text})
+			if !p.at("+") {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+		d.Permissions = append(d.Permissions, perm)
+		return nil
+	default:
+		return p.unexpected("'relation', 'permission' or '}'")
+	}
+}
+
+func (p *parser) subjectType() (SubjectType, error) {
+	namespace, err := p.name("subject type")
+	if err != nil {
+		return SubjectType{}, err
+	}
+	t := SubjectType{Pos: namespace.pos, Namespace: namespace.text}
+	if !p.at("#") {
+		return t, nil
+	}
+	if err := p.advance(); err != nil {
+		return SubjectType{}, err
+	}
+	relation, err := p.name("relation name")
+	if err != nil {
+		return SubjectType{}, err
+	}
+	t.Relation = relation.text
+	return t, nil
+}
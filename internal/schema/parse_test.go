@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	matrix := []struct {
+		name     string
+		src      string
+		expected []*Definition
+		err      string
+	}{
+		{
+			name: "given relations and permission",
+			src: "// Documents are owned by a single user\n" +
+				"definition document {\n" +
+				"  relation owner: user;\n" +
+				"  relation viewer: user | group#member;\n" +
+				"  permission view = viewer + owner\n" +
+				"}",
+			expected: []*Definition{
+				{
+					Pos:  Position{Line: 2, Column: 1},
+					Name: "document",
+					Relations: []*Relation{
+						{
+							Pos:   Position{Line: 3, Column: 3},
+							Name:  "owner",
+							Types: []SubjectType{{Pos: Position{Line: 3, Column: 19}, Namespace: "user"}},
+						},
+						{
+							Pos:  Position{Line: 4, Column: 3},
+							Name: "viewer",
+							Types: []SubjectType{
+								{Pos: Position{Line: 4, Column: 20}, Namespace: "user"},
+								{Pos: Position{Line: 4, Column: 27}, Namespace: "group", Relation: "member"},
+							},
+						},
+					},
+					Permissions: []*Permission{
+						{
+							Pos:  Position{Line: 5, Column: 3},
+							Name: "view",
+							Members: []Reference{
+								{Pos: Position{Line: 5, Column: 21}, Name: "viewer"},
+								{Pos: Position{Line: 5, Column: 30}, Name: "owner"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "given empty definition and trailing semicolon",
+			src:  "definition user {}\ndefinition group { relation member: user; }",
+			expected: []*Definition{
+				{Pos: Position{Line: 1, Column: 1}, Name: "user"},
+				{
+					Pos:  Position{Line: 2, Column: 1},
+					Name: "group",
+					Relations: []*Relation{
+						{
+							Pos:   Position{Line: 2, Column: 20},
+							Name:  "member",
+							Types: []SubjectType{{Pos: Position{Line: 2, Column: 37}, Namespace: "user"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "given conditional relation",
+			src: "definition document {\n" +
+				"  relation viewer: user if resource.label != \"a;b}\" // not \"secret\";\n" +
+				"    && request.ip in cidr(\"10.0.0.0/8\");\n" +
+				"  relation owner: user if true }",
+			expected: []*Definition{
+				{
+					Pos:  Position{Line: 1, Column: 1},
+					Name: "document",
+					Relations: []*Relation{
+						{
+							Pos:   Position{Line: 2, Column: 3},
+							Name:  "viewer",
+							Types: []SubjectType{{Pos: Position{Line: 2, Column: 20}, Namespace: "user"}},
+							Condition: &Condition{
+								Pos: Position{Line: 2, Column: 28},
+								// The comment is blanked out, leaving the rest of the condition where it is
+								Source: "resource.label != \"a;b}\" " +
+									strings.Repeat(" ", len(`// not "secret";`)) + "\n" +
+									"    && request.ip in cidr(\"10.0.0.0/8\")",
+							},
+						},
+						{
+							Pos:       Position{Line: 4, Column: 3},
+							Name:      "owner",
+							Types:     []SubjectType{{Pos: Position{Line: 4, Column: 19}, Namespace: "user"}},
+							Condition: &Condition{Pos: Position{Line: 4, Column: 27}, Source: "true"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "given empty schema",
+			src:  "  // nothing yet\n",
+		},
+		{
+			name: "given missing separator",
+			src:  "definition document {\n  relation owner: user\n  relation viewer: user\n}",
+			err:  "3:3: expected ';' or '}', found \"relation\"",
+		},
+		{
+			name: "given missing subject type",
+			src:  "definition document { relation owner: }",
+			err:  "1:39: expected subject type, found '}'",
+		},
+		{
+			name: "given unknown member",
+			src:  "definition document { caveat owner }",
+			err:  "1:23: expected 'relation', 'permission' or '}', found \"caveat\"",
+		},
+		{
+			name: "given unterminated definition",
+			src:  "definition document {\n  relation owner: user;",
+			err:  "2:24: expected 'relation', 'permission' or '}', found end of schema",
+		},
+		{
+			name: "given missing condition",
+			src:  "definition document { relation owner: user if }",
+			err:  "1:47: expected condition",
+		},
+		{
+			name: "given unexpected character",
+			src:  "definition document { permission view = viewer - owner }",
+			err:  "1:48: unexpected character '-'",
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			definitions, err := Parse(m.src)
+			if m.err != "" {
+				if err == nil || err.Error() != m.err {
+					t.Fatalf("got %v; want %s", err, m.err)
+				}
+				if !errors.Is(err, ErrInvalidSchema) {
+					t.Fatalf("got %v; want error wrapping %v", err, ErrInvalidSchema)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(definitions, m.expected) {
+				t.Fatalf("got %+v; want %+v", definitions, m.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+// Package schema implements the language namespaces are defined in, which compiles to the namespaces, relationships and
+// inheritances of gatekeeper. A schema is made up of definitions, one for each namespace, holding relations, which are
+// written as tuples, and permissions, which are held by every holder of one of the relations or permissions they are
+// the union of. A relation may be conditioned on the attributes of the resource and request being authorized, written
+// as an expression of the condition package following if:
+//
+//	definition user {}
+//
+//	definition group {
+//		relation member: user | group#member
+//	}
+//
+//	definition document {
+//		relation owner: user;
+//		relation viewer: user | group#member if resource.classification != "secret";
+//		permission view = viewer + owner
+//	}
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+)
+
+var (
+	ErrInvalidSchema = errors.New("invalid schema")
+)
+
+// Position is the line and column of a character within a schema, both counted from one.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Error is a syntax or type error at a position of a schema. It wraps ErrInvalidSchema.
+type Error struct {
+	Pos Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return ErrInvalidSchema
+}
+
+// Definition defines a namespace along with its relations and permissions.
+type Definition struct {
+	Pos         Position
+	Name        string
+	Relations   []*Relation
+	Permissions []*Permission
+}
+
+// Relation is a relation of a definition along with the types of subjects it may be held by.
+type Relation struct {
+	Pos   Position
+	Name  string
+	Types []SubjectType
+	// Condition is nil for relations held unconditionally.
+	Condition *Condition
+}
+
+// Condition is the source of a condition a relation is only held for if it holds, along with where it starts.
+type Condition struct {
+	Pos    Position
+	Source string
+}
+
+// source returns the source of the condition, or an empty string if c is nil.
+func (c *Condition) source() string {
+	if c == nil {
+		return ""
+	}
+	return c.Source
+}
+
+// position returns the position within the schema of a position within the condition.
+func (c *Condition) position(pos condition.Position) Position {
+	if pos.Line == 1 {
+		return Position{Line: c.Pos.Line, Column: c.Pos.Column + pos.Column - 1}
+	}
+	return Position{Line: c.Pos.Line + pos.Line - 1, Column: pos.Column}
+}
+
+// SubjectType is either a definition, such as user, whose objects may hold a relation directly or a relation of a
+// definition, such as group#member, whose usersets may hold it.
+type SubjectType struct {
+	Pos       Position
+	Namespace string
+	// Relation is empty for direct subjects.
+	Relation string
+}
+
+func (t SubjectType) String() string {
+	if t.Relation == "" {
+		return t.Namespace
+	}
+	return fmt.Sprintf("%s#%s", t.Namespace, t.Relation)
+}
+
+// Permission is held by every holder of any of its members, which are relations or permissions of the same definition.
+type Permission struct {
+	Pos     Position
+	Name    string
+	Members []Reference
+}
+
+// Reference is the name of a relation or permission along with where it is referenced.
+type Reference struct {
+	Pos  Position
+	Name string
+}
+
+// Relation returns the relation with the provided name, or nil if the definition has no such relation.
+func (d *Definition) Relation(name string) *Relation {
+	for _, r := range d.Relations {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Permission returns the permission with the provided name, or nil if the definition has no such permission.
+func (d *Definition) Permission(name string) *Permission {
+	for _, p := range d.Permissions {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// defines returns true if the definition has a relation or permission with the provided name.
+func (d *Definition) defines(name string) bool {
+	return d.Relation(name) != nil || d.Permission(name) != nil
+}
+
+// condition returns the source of the condition of the relation with the provided name, or an empty string if it has
+// none or is not a relation.
+func (d *Definition) condition(name string) string {
+	if r := d.Relation(name); r != nil {
+		return r.Condition.source()
+	}
+	return ""
+}
+
+// members returns the names of the relations of the definition followed by the names of its permissions.
+func (d *Definition) members() []string {
+	members := make([]string, 0, len(d.Relations)+len(d.Permissions))
+	for _, r := range d.Relations {
+		members = append(members, r.Name)
+	}
+	for _, p := range d.Permissions {
+		members = append(members, p.Name)
+	}
+	return members
+}
+
+// Schema is a compiled schema, which only references definitions, relations and permissions it defines.
+type Schema struct {
+	Definitions []*Definition
+}
+
+// Compile parses and type-checks a schema. The returned error joins an *Error for every problem found, or holds the
+// first syntax error if the schema could not be parsed.
+func Compile(src string) (*Schema, error) {
+	definitions, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{Definitions: definitions}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Definition returns the definition with the provided name, or nil if the schema has no such definition.
+func (s *Schema) Definition(name string) *Definition {
+	for _, d := range s.Definitions {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// check returns an error for every name which is defined more than once or referenced without being defined, and for
+// every condition which does not compile.
+func (s *Schema) check() error {
+	var errs []error
+	report := func(pos Position, format string, args ...any) {
+		errs = append(errs, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+	}
+	definitions := make(map[string]*Definition)
+	for _, d := range s.Definitions {
+		if previous, ok := definitions[d.Name]; ok {
+			report(d.Pos, "definition %s is already defined at %s", d.Name, previous.Pos)
+			continue
+		}
+		definitions[d.Name] = d
+	}
+	for _, d := range s.Definitions {
+		members := make(map[string]Position)
+		define := func(pos Position, name string) {
+			if previous, ok := members[name]; ok {
+				report(pos, "%s#%s is already defined at %s", d.Name, name, previous)
+				return
+			}
+			members[name] = pos
+		}
+		for _, r := range d.Relations {
+			define(r.Pos, r.Name)
+		}
+		for _, p := range d.Permissions {
+			define(p.Pos, p.Name)
+		}
+		for _, r := range d.Relations {
+			for _, t := range r.Types {
+				target, ok := definitions[t.Namespace]
+				switch {
+				case !ok:
+					report(t.Pos, "undefined subject type %s", t.Namespace)
+				case t.Relation != "" && target.Relation(t.Relation) == nil && target.Permission(t.Relation) == nil:
+					report(t.Pos, "undefined subject type %s, %s has no relation or permission %s", t, t.Namespace,
+						t.Relation)
+				}
+			}
+			if r.Condition == nil {
+				continue
+			}
+			_, err := condition.Compile(r.Condition.Source)
+			var cerr *condition.Error
+			switch {
+			case errors.As(err, &cerr):
+				report(r.Condition.position(cerr.Pos), "condition of %s#%s: %s", d.Name, r.Name, cerr.Msg)
+			case err != nil:
+				report(r.Condition.Pos, "condition of %s#%s: %s", d.Name, r.Name, err)
+			}
+		}
+		for _, p := range d.Permissions {
+			for _, m := range p.Members {
+				if _, ok := members[m.Name]; !ok {
+					report(m.Pos, "%s has no relation or permission %s", d.Name, m.Name)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Namespaces returns a namespace for every definition of the schema, none of which are entities yet.
+func (s *Schema) Namespaces() []gatekeeper.Namespace {
+	namespaces := make([]gatekeeper.Namespace, 0, len(s.Definitions))
+	for _, d := range s.Definitions {
+		namespaces = append(namespaces, gatekeeper.Namespace{Name: d.Name})
+	}
+	return namespaces
+}
+
+// Relationships returns a relationship for every relation and permission of the schema, along with the condition of
+// the relation if it has one, none of which are entities yet.
+func (s *Schema) Relationships() []gatekeeper.Relationship {
+	var relationships []gatekeeper.Relationship
+	for _, d := range s.Definitions {
+		namespace := gatekeeper.Namespace{Name: d.Name}
+		for _, r := range d.Relations {
+			relationships = append(relationships, gatekeeper.Relationship{
+				Namespace: namespace,
+				Name:      r.Name,
+				Condition: r.Condition.source(),
+			})
+		}
+		for _, p := range d.Permissions {
+			relationships = append(relationships, gatekeeper.Relationship{Namespace: namespace, Name: p.Name})
+		}
+	}
+	return relationships
+}
+
+// Inheritances returns an inheritance from every member of a permission to the permission, which makes the holders of
+// the member holders of the permission as well.
+func (s *Schema) Inheritances() []gatekeeper.Inheritance {
+	var inheritances []gatekeeper.Inheritance
+	for _, d := range s.Definitions {
+		namespace := gatekeeper.Namespace{Name: d.Name}
+		for _, p := range d.Permissions {
+			for _, m := range p.Members {
+				inheritances = append(inheritances, gatekeeper.Inheritance{
+					Parent: gatekeeper.Relationship{Namespace: namespace, Name: m.Name},
+					Child:  gatekeeper.Relationship{Namespace: namespace, Name: p.Name},
+				})
+			}
+		}
+	}
+	return inheritances
+}
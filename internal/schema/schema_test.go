@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"errors"
+	"github.com/ernilsson/gatekeeper/internal"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	matrix := []struct {
+		name string
+		src  string
+		errs []string
+	}{
+		{
+			name: "given valid schema",
+			src: "definition user {}\n" +
+				"definition group { relation member: user | group#member }\n" +
+				"definition document { relation viewer: user | group#member; permission view = viewer + view }",
+		},
+		{
+			name: "given duplicate names",
+			src: "definition user {}\n" +
+				"definition user {}\n" +
+				"definition document { relation viewer: user; permission viewer = viewer }",
+			errs: []string{
+				"2:1: definition user is already defined at 1:1",
+				"3:46: document#viewer is already defined at 3:23",
+			},
+		},
+		{
+			name: "given undefined references",
+			src: "definition group { relation member: user }\n" +
+				"definition document { relation viewer: group#admin; permission view = viewer + owner }",
+			errs: []string{
+				"1:37: undefined subject type user",
+				"2:40: undefined subject type group#admin, group has no relation or permission admin",
+				"2:80: document has no relation or permission owner",
+			},
+		},
+		{
+			name: "given invalid conditions",
+			src: "definition user {}\n" +
+				"definition document {\n" +
+				"  relation owner: user if resource.level > 3;\n" +
+				"  relation viewer: user if\n" +
+				"    resource.label == \"public\" ||\n" +
+				"    request.ip\n" +
+				"}",
+			errs: []string{
+				"3:42: condition of document#owner: operator > does not apply to string and int",
+				"5:32: condition of document#viewer: operator || does not apply to bool and string",
+			},
+		},
+		{
+			name: "given syntax error",
+			src:  "definition user",
+			errs: []string{"1:16: expected '{', found end of schema"},
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			_, err := Compile(m.src)
+			if m.errs == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("got %v; want error wrapping %v", err, ErrInvalidSchema)
+			}
+			if errs := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(errs, m.errs) {
+				t.Fatalf("got %q; want %q", errs, m.errs)
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s, err := Compile("definition user {}\n" +
+		"definition document { relation owner: user; relation viewer: user if request.ip == \"::1\"; " +
+		"permission view = viewer + owner }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := gatekeeper.Namespace{Name: "document"}
+	namespaces := []gatekeeper.Namespace{{Name: "user"}, document}
+	if got := s.Namespaces(); !reflect.DeepEqual(got, namespaces) {
+		t.Fatalf("got namespaces %v; want %v", got, namespaces)
+	}
+	relationships := []gatekeeper.Relationship{
+		{Namespace: document, Name: "owner"},
+		{Namespace: document, Name: "viewer", Condition: `request.ip == "::1"`},
+		{Namespace: document, Name: "view"},
+	}
+	if got := s.Relationships(); !reflect.DeepEqual(got, relationships) {
+		t.Fatalf("got relationships %v; want %v", got, relationships)
+	}
+	inheritances := []gatekeeper.Inheritance{
+		{Parent: gatekeeper.Relationship{Namespace: document, Name: "viewer"}, Child: relationships[2]},
+		{Parent: relationships[0], Child: relationships[2]},
+	}
+	if got := s.Inheritances(); !reflect.DeepEqual(got, inheritances) {
+		t.Fatalf("got inheritances %v; want %v", got, inheritances)
+	}
+}
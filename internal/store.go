@@ -0,0 +1,69 @@
+package gatekeeper
+
+import (
+	"context"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"time"
+)
+
+// Store persists principals, namespaces, relationships and policies along with the events raised by them. Saving an
+// entity flushes the events it has raised and appends them to the events of the entity. Lookups of entities which do
+// not exist return an error wrapping ErrNotFound and saving an entity without an ID returns ErrMissingID. Relation
+// tuples are stored alongside the entities, as is every version of the schema.
+type Store interface {
+	TupleStore
+	SchemaStore
+	PolicyStore
+	Principal(ctx context.Context, id string) (*Principal, error)
+	SavePrincipal(ctx context.Context, p *Principal) error
+	Namespace(ctx context.Context, id string) (*Namespace, error)
+	SaveNamespace(ctx context.Context, n *Namespace) error
+	Relationship(ctx context.Context, id string) (*Relationship, error)
+	// Relationships returns every relationship within the namespace with the provided name, ordered by ID.
+	Relationships(ctx context.Context, namespace string) ([]*Relationship, error)
+	// SaveRelationship returns the error of Validate, without saving, if the relationship is invalid.
+	SaveRelationship(ctx context.Context, r *Relationship) error
+	// DeleteRelationship deletes the relationship with the provided ID, its events are kept.
+	DeleteRelationship(ctx context.Context, id string) error
+	// Events returns the events stored for the entity of the kind with the provided ID in the order they were raised.
+	Events(ctx context.Context, kind Kind, id string) ([]entity.Event, error)
+	Close() error
+}
+
+// Kind is the kind of an entity. Events are stored under the kind of their entity along with its ID, as entities of
+// different kinds may share an ID.
+type Kind string
+
+const (
+	PrincipalKind    Kind = "principal"
+	NamespaceKind    Kind = "namespace"
+	RelationshipKind Kind = "relationship"
+	PolicyKind       Kind = "policy"
+	SchemaKind       Kind = "schema"
+)
+
+// SchemaEntityID is the ID of the entity the events of the schema are stored under, with the SchemaKind.
+const SchemaEntityID = "schema"
+
+// SchemaVersion is a version of the schema defining the namespaces, relationships and inheritances of gatekeeper, held
+// as the source it was written in and compiled by the schema package.
+type SchemaVersion struct {
+	Version uint
+	Source  string
+	// Changes describe how the version differs from the previous version.
+	Changes []string
+	// Forced is true if the version was applied in spite of changes which are unsafe for the stored tuples.
+	Forced  bool
+	Created time.Time
+}
+
+// SchemaStore keeps every version of the schema, none of which are changed once written.
+type SchemaStore interface {
+	// WriteSchema stores the schema as the version following the latest version, starting from version one, and sets
+	// the version and creation time of v. A SchemaApplied event is appended to the events of SchemaEntityID along with
+	// it. The source is stored as is, it is on the caller to compile it first.
+	WriteSchema(ctx context.Context, v *SchemaVersion) error
+	// Schema returns the provided version of the schema, or the latest version if it is zero. An error wrapping
+	// ErrNotFound is returned if there is no such version.
+	Schema(ctx context.Context, version uint) (*SchemaVersion, error)
+}
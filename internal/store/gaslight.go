@@ -0,0 +1,490 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	"slices"
+	"time"
+)
+
+// Gaslight is a store backed by collections of a gaslight file. Entities are stored as JSON documents keyed by their
+// ID, events are keyed by the kind and ID of their entity followed by their version and relationships are additionally
+// indexed by the name of their namespace. An entity is saved along with its events in a single transaction. Relation
+// tuples are stored as keys of two collections, one ordered by object and one ordered by subject, and schema versions
+// are keyed by their version.
+type Gaslight struct {
+	db            *gaslight.DB
+	principals    *gaslight.TypedCollection[string, *gatekeeper.Principal]
+	namespaces    *gaslight.TypedCollection[string, *gatekeeper.Namespace]
+	relationships *gaslight.TypedCollection[string, *gatekeeper.Relationship]
+	policies      *gaslight.TypedCollection[string, *gatekeeper.Policy]
+	// index maps the tuple of a namespace name and relationship ID to an empty value.
+	index *gaslight.TypedCollection[gaslight.Tuple, string]
+	// operations maps the tuple of an operation and the ID of a policy covering it to an empty value.
+	operations *gaslight.TypedCollection[gaslight.Tuple, string]
+	events     *gaslight.TypedCollection[gaslight.Tuple, entity.Event]
+	// objects and subjects map the key of every tuple, ordered by object and by subject respectively, to an empty value.
+	objects  *gaslight.TypedCollection[gaslight.Tuple, string]
+	subjects *gaslight.TypedCollection[gaslight.Tuple, string]
+	schemas  *gaslight.TypedCollection[gaslight.Tuple, *gatekeeper.SchemaVersion]
+}
+
+// NewGaslight creates the collections of the store within the provided DB unless they already exist. The store takes
+// ownership of the DB, which is closed along with the store.
+func NewGaslight(db *gaslight.DB) (*Gaslight, error) {
+	collections := make(map[string]*gaslight.Collection)
+	names := []string{
+		"principals", "namespaces", "relationships", "relationships_by_namespace", "events", "tuples_by_object",
+		"tuples_by_subject", "schemas", "policies", "policies_by_operation",
+	}
+	for _, name := range names {
+		c, err := db.Collection(name)
+		if errors.Is(err, gaslight.ErrCollectionNotFound) {
+			c, err = db.CreateCollection(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		collections[name] = c
+	}
+	return &Gaslight{
+		db: db,
+		principals: gaslight.NewTypedCollection[string, *gatekeeper.Principal](
+			collections["principals"], gaslight.StringCodec{}, gaslight.JSONCodec[*gatekeeper.Principal]{}),
+		namespaces: gaslight.NewTypedCollection[string, *gatekeeper.Namespace](
+			collections["namespaces"], gaslight.StringCodec{}, gaslight.JSONCodec[*gatekeeper.Namespace]{}),
+		relationships: gaslight.NewTypedCollection[string, *gatekeeper.Relationship](
+			collections["relationships"], gaslight.StringCodec{}, gaslight.JSONCodec[*gatekeeper.Relationship]{}),
+		policies: gaslight.NewTypedCollection[string, *gatekeeper.Policy](
+			collections["policies"], gaslight.StringCodec{}, gaslight.JSONCodec[*gatekeeper.Policy]{}),
+		index: gaslight.NewTypedCollection[gaslight.Tuple, string](
+			collections["relationships_by_namespace"], gaslight.TupleCodec{}, gaslight.StringCodec{}),
+		operations: gaslight.NewTypedCollection[gaslight.Tuple, string](
+			collections["policies_by_operation"], gaslight.TupleCodec{}, gaslight.StringCodec{}),
+		events: gaslight.NewTypedCollection[gaslight.Tuple, entity.Event](
+			collections["events"], gaslight.TupleCodec{}, gaslight.JSONCodec[entity.Event]{}),
+		objects: gaslight.NewTypedCollection[gaslight.Tuple, string](
+			collections["tuples_by_object"], gaslight.TupleCodec{}, gaslight.StringCodec{}),
+		subjects: gaslight.NewTypedCollection[gaslight.Tuple, string](
+			collections["tuples_by_subject"], gaslight.TupleCodec{}, gaslight.StringCodec{}),
+		schemas: gaslight.NewTypedCollection[gaslight.Tuple, *gatekeeper.SchemaVersion](
+			collections["schemas"], gaslight.TupleCodec{}, gaslight.JSONCodec[*gatekeeper.SchemaVersion]{}),
+	}, nil
+}
+
+func (g *Gaslight) Principal(ctx context.Context, id string) (*gatekeeper.Principal, error) {
+	return get(ctx, g.principals, "principal", id)
+}
+
+func (g *Gaslight) SavePrincipal(ctx context.Context, p *gatekeeper.Principal) error {
+	return g.save(ctx, gatekeeper.PrincipalKind, p.Entity, func(tx *gaslight.Tx) error {
+		return g.principals.In(tx).Put(p.ID, p)
+	})
+}
+
+func (g *Gaslight) Namespace(ctx context.Context, id string) (*gatekeeper.Namespace, error) {
+	return get(ctx, g.namespaces, "namespace", id)
+}
+
+func (g *Gaslight) SaveNamespace(ctx context.Context, n *gatekeeper.Namespace) error {
+	return g.save(ctx, gatekeeper.NamespaceKind, n.Entity, func(tx *gaslight.Tx) error {
+		return g.namespaces.In(tx).Put(n.ID, n)
+	})
+}
+
+func (g *Gaslight) Relationship(ctx context.Context, id string) (*gatekeeper.Relationship, error) {
+	return get(ctx, g.relationships, "relationship", id)
+}
+
+func (g *Gaslight) Relationships(ctx context.Context, namespace string) ([]*gatekeeper.Relationship, error) {
+	var ids []string
+	err := g.index.ForEachPrefix(gaslight.Tuple{namespace}, func(key gaslight.Tuple, _ string) error {
+		ids = append(ids, key[1].(string))
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	relationships, found, err := g.relationships.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range relationships {
+		if !found[i] {
+			return nil, fmt.Errorf("relationship %s: indexed but %w", ids[i], gatekeeper.ErrNotFound)
+		}
+	}
+	return relationships, nil
+}
+
+func (g *Gaslight) SaveRelationship(ctx context.Context, r *gatekeeper.Relationship) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	return g.save(ctx, gatekeeper.RelationshipKind, r.Entity, func(tx *gaslight.Tx) error {
+		relationships, index := g.relationships.In(tx), g.index.In(tx)
+		// A relationship moved to another namespace must not be listed under its previous namespace
+		previous, err := relationships.Get(r.ID)
+		switch {
+		case errors.Is(err, gaslight.ErrItemNotFound):
+		case err != nil:
+			return err
+		case previous.Namespace.Name != r.Namespace.Name:
+			if err := index.Delete(gaslight.Tuple{previous.Namespace.Name, r.ID}); err != nil {
+				return err
+			}
+		}
+		if err := relationships.Put(r.ID, r); err != nil {
+			return err
+		}
+		return index.Put(gaslight.Tuple{r.Namespace.Name, r.ID}, "")
+	})
+}
+
+func (g *Gaslight) DeleteRelationship(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return g.db.Update(func(tx *gaslight.Tx) error {
+		relationships := g.relationships.In(tx)
+		r, err := relationships.Get(id)
+		if errors.Is(err, gaslight.ErrItemNotFound) {
+			return fmt.Errorf("relationship %s: %w", id, gatekeeper.ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		if err := relationships.Delete(id); err != nil {
+			return err
+		}
+		return g.index.In(tx).Delete(gaslight.Tuple{r.Namespace.Name, id})
+	})
+}
+
+func (g *Gaslight) Policy(ctx context.Context, id string) (*gatekeeper.Policy, error) {
+	return get(ctx, g.policies, "policy", id)
+}
+
+func (g *Gaslight) SavePolicy(ctx context.Context, p *gatekeeper.Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	raisePolicy(p)
+	return g.save(ctx, gatekeeper.PolicyKind, p.Entity, func(tx *gaslight.Tx) error {
+		policies, operations := g.policies.In(tx), g.operations.In(tx)
+		// A policy which no longer covers an operation must not be listed under it
+		previous, err := policies.Get(p.ID)
+		switch {
+		case errors.Is(err, gaslight.ErrItemNotFound):
+		case err != nil:
+			return err
+		default:
+			for _, op := range previous.Operations {
+				if slices.Contains(p.Operations, op) {
+					continue
+				}
+				if err := operations.Delete(gaslight.Tuple{op, p.ID}); err != nil {
+					return err
+				}
+			}
+		}
+		if err := policies.Put(p.ID, p); err != nil {
+			return err
+		}
+		keys := make([]gaslight.Tuple, 0, len(p.Operations))
+		for _, op := range p.Operations {
+			keys = append(keys, gaslight.Tuple{op, p.ID})
+		}
+		return operations.PutBatch(keys, make([]string, len(keys)))
+	})
+}
+
+func (g *Gaslight) Policies(ctx context.Context, operation string) ([]*gatekeeper.Policy, error) {
+	var ids []string
+	err := g.operations.ForEachPrefix(gaslight.Tuple{operation}, func(key gaslight.Tuple, _ string) error {
+		ids = append(ids, key[1].(string))
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	policies, found, err := g.policies.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range policies {
+		if !found[i] {
+			return nil, fmt.Errorf("policy %s: indexed but %w", ids[i], gatekeeper.ErrNotFound)
+		}
+	}
+	return policies, nil
+}
+
+func (g *Gaslight) Events(ctx context.Context, kind gatekeeper.Kind, id string) ([]entity.Event, error) {
+	var events []entity.Event
+	err := g.events.ForEachPrefix(gaslight.Tuple{string(kind), id}, func(_ gaslight.Tuple, event entity.Event) error {
+		events = append(events, event)
+		return ctx.Err()
+	})
+	return events, err
+}
+
+// WriteTuples writes the tuples to the object and subject indexes in a single transaction.
+func (g *Gaslight) WriteTuples(ctx context.Context, tuples ...gatekeeper.RelationTuple) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	objects, subjects := make([]gaslight.Tuple, 0, len(tuples)), make([]gaslight.Tuple, 0, len(tuples))
+	for _, t := range tuples {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		objects, subjects = append(objects, objectKey(t)), append(subjects, subjectKey(t))
+	}
+	empty := make([]string, len(tuples))
+	return g.db.Update(func(tx *gaslight.Tx) error {
+		if err := g.subjects.In(tx).PutBatch(subjects, empty); err != nil {
+			return err
+		}
+		return g.objects.In(tx).PutBatch(objects, empty)
+	})
+}
+
+// DeleteTuples removes the tuples from the object and subject indexes in a single transaction.
+func (g *Gaslight) DeleteTuples(ctx context.Context, tuples ...gatekeeper.RelationTuple) error {
+	return g.db.Update(func(tx *gaslight.Tx) error {
+		objects, subjects := g.objects.In(tx), g.subjects.In(tx)
+		for _, t := range tuples {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := objects.Delete(objectKey(t)); err != nil && !errors.Is(err, gaslight.ErrItemNotFound) {
+				return err
+			}
+			if err := subjects.Delete(subjectKey(t)); err != nil && !errors.Is(err, gaslight.ErrItemNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (g *Gaslight) ObjectTuples(ctx context.Context, namespace, object, relation string) ([]gatekeeper.RelationTuple, error) {
+	prefix := gaslight.Tuple{namespace, object}
+	if relation != "" {
+		prefix = append(prefix, relation)
+	}
+	var tuples []gatekeeper.RelationTuple
+	err := g.objects.ForEachPrefix(prefix, func(key gaslight.Tuple, _ string) error {
+		t, err := decodeTuple(key, 0, 3)
+		if err != nil {
+			return err
+		}
+		tuples = append(tuples, t)
+		return ctx.Err()
+	})
+	return tuples, err
+}
+
+func (g *Gaslight) SubjectTuples(ctx context.Context, s gatekeeper.Subject, namespace string) ([]gatekeeper.RelationTuple, error) {
+	prefix := gaslight.Tuple{s.Namespace, s.Object, s.Relation}
+	if namespace != "" {
+		prefix = append(prefix, namespace)
+	}
+	var tuples []gatekeeper.RelationTuple
+	err := g.subjects.ForEachPrefix(prefix, func(key gaslight.Tuple, _ string) error {
+		t, err := decodeTuple(key, 3, 0)
+		if err != nil {
+			return err
+		}
+		tuples = append(tuples, t)
+		return ctx.Err()
+	})
+	return tuples, err
+}
+
+// RelationTuples scans the tuples of the namespace in the object index and the tuples of subjects of the namespace in
+// the subject index.
+func (g *Gaslight) RelationTuples(ctx context.Context, namespace, relation string) ([]gatekeeper.RelationTuple, error) {
+	found := make(map[gatekeeper.RelationTuple]struct{})
+	err := g.objects.ForEachPrefix(gaslight.Tuple{namespace}, func(key gaslight.Tuple, _ string) error {
+		t, err := decodeTuple(key, 0, 3)
+		if err != nil {
+			return err
+		}
+		if relation == "" || t.Relation == relation {
+			found[t] = struct{}{}
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = g.subjects.ForEachPrefix(gaslight.Tuple{namespace}, func(key gaslight.Tuple, _ string) error {
+		t, err := decodeTuple(key, 3, 0)
+		if err != nil {
+			return err
+		}
+		if relation == "" || t.Subject.Relation == relation {
+			found[t] = struct{}{}
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	tuples := make([]gatekeeper.RelationTuple, 0, len(found))
+	for t := range found {
+		tuples = append(tuples, t)
+	}
+	slices.SortFunc(tuples, gatekeeper.CompareTuples)
+	return tuples, nil
+}
+
+// WriteSchema writes the SchemaApplied event and the version it records within the same transaction, like entities
+// are saved. The latest version is read within that transaction too, which keeps concurrent writes from being given
+// the same version.
+func (g *Gaslight) WriteSchema(ctx context.Context, v *gatekeeper.SchemaVersion) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var version uint
+	created := time.Now()
+	err := g.db.Update(func(tx *gaslight.Tx) error {
+		latest, err := latestSchema(ctx, g.schemas.In(tx))
+		if err != nil {
+			return err
+		}
+		version = latest + 1
+		stored := *v
+		stored.Version, stored.Created = version, created
+		event := schemaApplied(&stored)
+		key := gaslight.Tuple{string(gatekeeper.SchemaKind), gatekeeper.SchemaEntityID, uint64(version)}
+		if err := g.events.In(tx).Put(key, event); err != nil {
+			return err
+		}
+		return g.schemas.In(tx).Put(gaslight.Tuple{uint64(version)}, &stored)
+	})
+	if err != nil {
+		return err
+	}
+	v.Version, v.Created = version, created
+	return nil
+}
+
+func (g *Gaslight) Schema(ctx context.Context, version uint) (*gatekeeper.SchemaVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		latest, err := latestSchema(ctx, g.schemas)
+		if err != nil {
+			return nil, err
+		}
+		version = latest
+	}
+	v, err := g.schemas.Get(gaslight.Tuple{uint64(version)})
+	if errors.Is(err, gaslight.ErrItemNotFound) {
+		return nil, fmt.Errorf("schema version %d: %w", version, gatekeeper.ErrNotFound)
+	}
+	return v, err
+}
+
+// latestSchema returns the latest version of the schema, or zero if no version has been written. Collections can only
+// be iterated in ascending order, but the schema is expected to be changed rarely enough for that not to matter.
+func latestSchema(ctx context.Context, schemas *gaslight.TypedCollection[gaslight.Tuple, *gatekeeper.SchemaVersion]) (
+	uint, error) {
+	var latest uint
+	err := schemas.ForEachPrefix(gaslight.Tuple{}, func(key gaslight.Tuple, _ *gatekeeper.SchemaVersion) error {
+		latest = uint(key[0].(uint64))
+		return ctx.Err()
+	})
+	return latest, err
+}
+
+func (g *Gaslight) Close() error {
+	return g.db.Close()
+}
+
+// save validates the entity and then writes the events raised by it and calls store to write the entity itself, both
+// within the same transaction. The events are only flushed from the entity once it has been stored.
+func (g *Gaslight) save(ctx context.Context, kind gatekeeper.Kind, e *entity.Entity,
+	store func(tx *gaslight.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if e == nil || e.ID == "" {
+		return gatekeeper.ErrMissingID
+	}
+	events := e.Events()
+	err := g.db.Update(func(tx *gaslight.Tx) error {
+		if len(events) > 0 {
+			keys := make([]gaslight.Tuple, 0, len(events))
+			for _, event := range events {
+				keys = append(keys, gaslight.Tuple{string(kind), e.ID, uint64(event.Version)})
+			}
+			if err := g.events.In(tx).PutBatch(keys, events); err != nil {
+				return err
+			}
+		}
+		return store(tx)
+	})
+	if err != nil {
+		return err
+	}
+	e.Flush()
+	return nil
+}
+
+// get looks up the entity stored under the provided ID, translating a missing item into gatekeeper.ErrNotFound.
+func get[T any](ctx context.Context, c *gaslight.TypedCollection[string, T], kind, id string) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	v, err := c.Get(id)
+	if errors.Is(err, gaslight.ErrItemNotFound) {
+		return zero, fmt.Errorf("%s %s: %w", kind, id, gatekeeper.ErrNotFound)
+	}
+	return v, err
+}
+
+// objectKey returns the key of a tuple in the object index, the parts of the tuple in the order they are written.
+func objectKey(t gatekeeper.RelationTuple) gaslight.Tuple {
+	return gaslight.Tuple{t.Namespace, t.Object, t.Relation, t.Subject.Namespace, t.Subject.Object, t.Subject.Relation}
+}
+
+// subjectKey returns the key of a tuple in the subject index, the subject followed by the object and relation.
+func subjectKey(t gatekeeper.RelationTuple) gaslight.Tuple {
+	return gaslight.Tuple{t.Subject.Namespace, t.Subject.Object, t.Subject.Relation, t.Namespace, t.Object, t.Relation}
+}
+
+// decodeTuple reads a tuple from an index key holding the object and relation from the object offset and the subject
+// from the subject offset.
+func decodeTuple(key gaslight.Tuple, object, subject int) (gatekeeper.RelationTuple, error) {
+	if len(key) != 6 {
+		return gatekeeper.RelationTuple{}, fmt.Errorf("%w: index key %v", gatekeeper.ErrMalformedTuple, key)
+	}
+	parts := make([]string, len(key))
+	for i, element := range key {
+		part, ok := element.(string)
+		if !ok {
+			return gatekeeper.RelationTuple{}, fmt.Errorf("%w: index key %v", gatekeeper.ErrMalformedTuple, key)
+		}
+		parts[i] = part
+	}
+	return gatekeeper.RelationTuple{
+		Namespace: parts[object],
+		Object:    parts[object+1],
+		Relation:  parts[object+2],
+		Subject: gatekeeper.Subject{
+			Namespace: parts[subject],
+			Object:    parts[subject+1],
+			Relation:  parts[subject+2],
+		},
+	}, nil
+}
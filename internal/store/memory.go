@@ -0,0 +1,382 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a store which keeps every entity in memory, mainly intended for tests. Entities are copied when saved and
+// loaded, which means that changes to an entity are not visible to the store until it is saved again.
+type Memory struct {
+	mu            sync.RWMutex
+	principals    map[string]gatekeeper.Principal
+	namespaces    map[string]gatekeeper.Namespace
+	relationships map[string]gatekeeper.Relationship
+	policies      map[string]gatekeeper.Policy
+	events        map[eventKey][]entity.Event
+	// objects and subjects index every stored tuple by its object and by its subject.
+	objects  map[object]map[gatekeeper.RelationTuple]struct{}
+	subjects map[gatekeeper.Subject]map[gatekeeper.RelationTuple]struct{}
+	// schemas holds every version of the schema, version n at index n-1.
+	schemas []gatekeeper.SchemaVersion
+}
+
+// eventKey identifies the entity of the kind with the ID events are stored for.
+type eventKey struct {
+	kind gatekeeper.Kind
+	id   string
+}
+
+// object identifies an object within a namespace.
+type object struct {
+	namespace string
+	id        string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		principals:    make(map[string]gatekeeper.Principal),
+		namespaces:    make(map[string]gatekeeper.Namespace),
+		relationships: make(map[string]gatekeeper.Relationship),
+		policies:      make(map[string]gatekeeper.Policy),
+		events:        make(map[eventKey][]entity.Event),
+		objects:       make(map[object]map[gatekeeper.RelationTuple]struct{}),
+		subjects:      make(map[gatekeeper.Subject]map[gatekeeper.RelationTuple]struct{}),
+	}
+}
+
+func (m *Memory) Principal(ctx context.Context, id string) (*gatekeeper.Principal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.principals[id]
+	if !ok {
+		return nil, fmt.Errorf("principal %s: %w", id, gatekeeper.ErrNotFound)
+	}
+	return clonePrincipal(&p), nil
+}
+
+func (m *Memory) SavePrincipal(ctx context.Context, p *gatekeeper.Principal) error {
+	return m.save(ctx, gatekeeper.PrincipalKind, p.Entity, func() {
+		m.principals[p.ID] = *clonePrincipal(p)
+	})
+}
+
+func (m *Memory) Namespace(ctx context.Context, id string) (*gatekeeper.Namespace, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.namespaces[id]
+	if !ok {
+		return nil, fmt.Errorf("namespace %s: %w", id, gatekeeper.ErrNotFound)
+	}
+	return cloneNamespace(&n), nil
+}
+
+func (m *Memory) SaveNamespace(ctx context.Context, n *gatekeeper.Namespace) error {
+	return m.save(ctx, gatekeeper.NamespaceKind, n.Entity, func() {
+		m.namespaces[n.ID] = *cloneNamespace(n)
+	})
+}
+
+func (m *Memory) Relationship(ctx context.Context, id string) (*gatekeeper.Relationship, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.relationships[id]
+	if !ok {
+		return nil, fmt.Errorf("relationship %s: %w", id, gatekeeper.ErrNotFound)
+	}
+	return cloneRelationship(&r), nil
+}
+
+func (m *Memory) Relationships(ctx context.Context, namespace string) ([]*gatekeeper.Relationship, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var relationships []*gatekeeper.Relationship
+	for _, r := range m.relationships {
+		if r.Namespace.Name == namespace {
+			relationships = append(relationships, cloneRelationship(&r))
+		}
+	}
+	slices.SortFunc(relationships, func(a, b *gatekeeper.Relationship) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return relationships, nil
+}
+
+func (m *Memory) SaveRelationship(ctx context.Context, r *gatekeeper.Relationship) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	return m.save(ctx, gatekeeper.RelationshipKind, r.Entity, func() {
+		m.relationships[r.ID] = *cloneRelationship(r)
+	})
+}
+
+func (m *Memory) DeleteRelationship(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.relationships[id]; !ok {
+		return fmt.Errorf("relationship %s: %w", id, gatekeeper.ErrNotFound)
+	}
+	delete(m.relationships, id)
+	return nil
+}
+
+func (m *Memory) Policy(ctx context.Context, id string) (*gatekeeper.Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.policies[id]
+	if !ok {
+		return nil, fmt.Errorf("policy %s: %w", id, gatekeeper.ErrNotFound)
+	}
+	return clonePolicy(&p), nil
+}
+
+func (m *Memory) SavePolicy(ctx context.Context, p *gatekeeper.Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	raisePolicy(p)
+	return m.save(ctx, gatekeeper.PolicyKind, p.Entity, func() {
+		m.policies[p.ID] = *clonePolicy(p)
+	})
+}
+
+func (m *Memory) Policies(ctx context.Context, operation string) ([]*gatekeeper.Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var policies []*gatekeeper.Policy
+	for _, p := range m.policies {
+		if slices.Contains(p.Operations, operation) {
+			policies = append(policies, clonePolicy(&p))
+		}
+	}
+	slices.SortFunc(policies, func(a, b *gatekeeper.Policy) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return policies, nil
+}
+
+func (m *Memory) Events(ctx context.Context, kind gatekeeper.Kind, id string) ([]entity.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.events[eventKey{kind, id}]), nil
+}
+
+func (m *Memory) WriteTuples(ctx context.Context, tuples ...gatekeeper.RelationTuple) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, t := range tuples {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range tuples {
+		o := object{namespace: t.Namespace, id: t.Object}
+		if m.objects[o] == nil {
+			m.objects[o] = make(map[gatekeeper.RelationTuple]struct{})
+		}
+		m.objects[o][t] = struct{}{}
+		if m.subjects[t.Subject] == nil {
+			m.subjects[t.Subject] = make(map[gatekeeper.RelationTuple]struct{})
+		}
+		m.subjects[t.Subject][t] = struct{}{}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteTuples(ctx context.Context, tuples ...gatekeeper.RelationTuple) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range tuples {
+		o := object{namespace: t.Namespace, id: t.Object}
+		delete(m.objects[o], t)
+		if len(m.objects[o]) == 0 {
+			delete(m.objects, o)
+		}
+		delete(m.subjects[t.Subject], t)
+		if len(m.subjects[t.Subject]) == 0 {
+			delete(m.subjects, t.Subject)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ObjectTuples(ctx context.Context, namespace, id, relation string) ([]gatekeeper.RelationTuple, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tuples []gatekeeper.RelationTuple
+	for t := range m.objects[object{namespace: namespace, id: id}] {
+		if relation == "" || t.Relation == relation {
+			tuples = append(tuples, t)
+		}
+	}
+	slices.SortFunc(tuples, gatekeeper.CompareTuples)
+	return tuples, nil
+}
+
+func (m *Memory) SubjectTuples(ctx context.Context, s gatekeeper.Subject, namespace string) ([]gatekeeper.RelationTuple, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tuples []gatekeeper.RelationTuple
+	for t := range m.subjects[s] {
+		if namespace == "" || t.Namespace == namespace {
+			tuples = append(tuples, t)
+		}
+	}
+	slices.SortFunc(tuples, gatekeeper.CompareTuples)
+	return tuples, nil
+}
+
+func (m *Memory) RelationTuples(ctx context.Context, namespace, relation string) ([]gatekeeper.RelationTuple, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tuples []gatekeeper.RelationTuple
+	for o, ts := range m.objects {
+		for t := range ts {
+			object := o.namespace == namespace && (relation == "" || t.Relation == relation)
+			subject := t.Subject.Namespace == namespace && (relation == "" || t.Subject.Relation == relation)
+			if object || subject {
+				tuples = append(tuples, t)
+			}
+		}
+	}
+	slices.SortFunc(tuples, gatekeeper.CompareTuples)
+	return tuples, nil
+}
+
+func (m *Memory) WriteSchema(ctx context.Context, v *gatekeeper.SchemaVersion) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v.Version, v.Created = uint(len(m.schemas))+1, time.Now()
+	stored := *v
+	stored.Changes = slices.Clone(v.Changes)
+	m.schemas = append(m.schemas, stored)
+	key := eventKey{gatekeeper.SchemaKind, gatekeeper.SchemaEntityID}
+	m.events[key] = append(m.events[key], schemaApplied(v))
+	return nil
+}
+
+func (m *Memory) Schema(ctx context.Context, version uint) (*gatekeeper.SchemaVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if version == 0 {
+		version = uint(len(m.schemas))
+	}
+	if version == 0 || version > uint(len(m.schemas)) {
+		return nil, fmt.Errorf("schema version %d: %w", version, gatekeeper.ErrNotFound)
+	}
+	v := m.schemas[version-1]
+	v.Changes = slices.Clone(v.Changes)
+	return &v, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// save validates the entity and calls store while holding the lock of the store, followed by appending the events
+// raised by the entity.
+func (m *Memory) save(ctx context.Context, kind gatekeeper.Kind, e *entity.Entity, store func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if e == nil || e.ID == "" {
+		return gatekeeper.ErrMissingID
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	store()
+	key := eventKey{kind, e.ID}
+	m.events[key] = append(m.events[key], e.Flush()...)
+	return nil
+}
+
+// cloneEntity copies the exported state of an entity, raised events are not part of the copy.
+func cloneEntity(e *entity.Entity) *entity.Entity {
+	if e == nil {
+		return nil
+	}
+	return &entity.Entity{
+		ID:      e.ID,
+		Version: e.Version,
+	}
+}
+
+func clonePrincipal(p *gatekeeper.Principal) *gatekeeper.Principal {
+	c := *p
+	c.Entity = cloneEntity(p.Entity)
+	return &c
+}
+
+func cloneNamespace(n *gatekeeper.Namespace) *gatekeeper.Namespace {
+	c := *n
+	c.Entity = cloneEntity(n.Entity)
+	return &c
+}
+
+func cloneRelationship(r *gatekeeper.Relationship) *gatekeeper.Relationship {
+	c := *r
+	c.Entity = cloneEntity(r.Entity)
+	c.Namespace = *cloneNamespace(&r.Namespace)
+	return &c
+}
+
+func clonePolicy(p *gatekeeper.Policy) *gatekeeper.Policy {
+	c := *p
+	c.Entity = cloneEntity(p.Entity)
+	c.Subjects = slices.Clone(p.Subjects)
+	c.Operations = slices.Clone(p.Operations)
+	c.Resources = slices.Clone(p.Resources)
+	return &c
+}
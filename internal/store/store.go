@@ -0,0 +1,62 @@
+// Package store provides the implementations of gatekeeper.Store.
+package store
+
+import (
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	"slices"
+)
+
+const (
+	KindMemory   = "memory"
+	KindGaslight = "gaslight"
+)
+
+// Open creates a store of the provided kind. The gaslight store is backed by the gaslight file at the provided path,
+// which is created if it does not exist and opened with the provided options, while the path and options are ignored
+// by the memory store.
+func Open(kind, path string, opts ...gaslight.Option) (gatekeeper.Store, error) {
+	switch kind {
+	case KindMemory:
+		return NewMemory(), nil
+	case KindGaslight:
+		db, err := gaslight.Open(path, opts...)
+		if err != nil {
+			return nil, err
+		}
+		s, err := NewGaslight(db)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected %q or %q", kind, KindMemory, KindGaslight)
+	}
+}
+
+// schemaApplied returns the event recording that the schema version was written, versioned as the schema itself.
+func schemaApplied(v *gatekeeper.SchemaVersion) entity.Event {
+	event := entity.NewEvent("SchemaApplied", gatekeeper.SchemaApplied{
+		Version: v.Version,
+		Changes: slices.Clone(v.Changes),
+		Forced:  v.Forced,
+	})
+	event.Version, event.Created = v.Version, v.Created
+	return event
+}
+
+// raisePolicy raises the PolicyAllowed or PolicyDenied event on the policy depending on its effect, which records every
+// save of the policy in its events.
+func raisePolicy(p *gatekeeper.Policy) {
+	if p.Entity == nil || p.ID == "" {
+		return
+	}
+	if p.Effect == gatekeeper.Permit {
+		p.Raise(entity.NewEvent("PolicyAllowed", gatekeeper.PolicyAllowed{PolicyID: p.ID}))
+		return
+	}
+	p.Raise(entity.NewEvent("PolicyDenied", gatekeeper.PolicyDenied{PolicyID: p.ID}))
+}
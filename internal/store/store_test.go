@@ -0,0 +1,522 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	"github.com/ernilsson/gatekeeper/internal/gaslight"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stores returns a constructor for every implementation of gatekeeper.Store.
+func stores() []struct {
+	name string
+	open func(t *testing.T) gatekeeper.Store
+} {
+	return []struct {
+		name string
+		open func(t *testing.T) gatekeeper.Store
+	}{
+		{
+			name: "given memory store",
+			open: func(t *testing.T) gatekeeper.Store {
+				return NewMemory()
+			},
+		},
+		{
+			name: "given gaslight store",
+			open: func(t *testing.T) gatekeeper.Store {
+				s, err := Open(KindGaslight, filepath.Join(t.TempDir(), "gatekeeper.db"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			},
+		},
+	}
+}
+
+func TestStore(t *testing.T) {
+	for _, m := range stores() {
+		t.Run(m.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := m.open(t)
+			defer s.Close()
+
+			alice := &gatekeeper.Principal{
+				Entity:  &entity.Entity{ID: "alice"},
+				Subject: "user-1",
+				Created: time.Now().UTC(),
+			}
+			alice.Raise(entity.NewEvent("PrincipalEnrolled", gatekeeper.PrincipalEnrolled{
+				GroupID:     "eng",
+				PrincipalID: "alice",
+			}))
+			if err := s.SavePrincipal(ctx, alice); err != nil {
+				t.Fatal(err)
+			}
+			if len(alice.Events()) != 0 {
+				t.Fatalf("got %d unflushed events; want none", len(alice.Events()))
+			}
+			alice.Raise(entity.NewEvent("PrincipalEnrolled", gatekeeper.PrincipalEnrolled{
+				GroupID:     "ops",
+				PrincipalID: "alice",
+			}))
+			if err := s.SavePrincipal(ctx, alice); err != nil {
+				t.Fatal(err)
+			}
+			p, err := s.Principal(ctx, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Subject != "user-1" || p.Version != 2 {
+				t.Fatalf("got %s at version %d; want user-1 at version 2", p.Subject, p.Version)
+			}
+			events, err := s.Events(ctx, gatekeeper.PrincipalKind, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 2 || events[0].Version != 1 || events[1].Version != 2 {
+				t.Fatalf("got %v; want two events in order", events)
+			}
+			if _, err := s.Principal(ctx, "bob"); !errors.Is(err, gatekeeper.ErrNotFound) {
+				t.Fatalf("got %v; want %v", err, gatekeeper.ErrNotFound)
+			}
+			if err := s.SavePrincipal(ctx, &gatekeeper.Principal{}); !errors.Is(err, gatekeeper.ErrMissingID) {
+				t.Fatalf("got %v; want %v", err, gatekeeper.ErrMissingID)
+			}
+
+			documents := gatekeeper.Namespace{Entity: &entity.Entity{ID: "ns-1"}, Name: "documents"}
+			folders := gatekeeper.Namespace{Entity: &entity.Entity{ID: "ns-2"}, Name: "folders"}
+			for _, n := range []*gatekeeper.Namespace{&documents, &folders} {
+				if err := s.SaveNamespace(ctx, n); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n, err := s.Namespace(ctx, "ns-2"); err != nil || n.Name != "folders" {
+				t.Fatalf("got %v, %v; want folders", n, err)
+			}
+			relationships := []*gatekeeper.Relationship{
+				{Entity: &entity.Entity{ID: "rel-2"}, Namespace: documents, Name: "viewer"},
+				{Entity: &entity.Entity{ID: "rel-1"}, Namespace: documents, Name: "editor"},
+				{Entity: &entity.Entity{ID: "rel-3"}, Namespace: folders, Name: "viewer"},
+			}
+			for _, r := range relationships {
+				if err := s.SaveRelationship(ctx, r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Moving a relationship to another namespace removes it from the listing of its previous namespace
+			relationships[2].Namespace = documents
+			relationships[2].Name = "owner"
+			if err := s.SaveRelationship(ctx, relationships[2]); err != nil {
+				t.Fatal(err)
+			}
+			listed, err := s.Relationships(ctx, "documents")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listed) != 3 || listed[0].QualifiedName() != "documents:editor" || listed[2].Name != "owner" {
+				t.Fatalf("got %v; want editor, viewer and owner of documents", listed)
+			}
+			if listed, err := s.Relationships(ctx, "folders"); err != nil || len(listed) != 0 {
+				t.Fatalf("got %v, %v; want no relationships of folders", listed, err)
+			}
+			conditional := &gatekeeper.Relationship{
+				Entity:    &entity.Entity{ID: "rel-4"},
+				Namespace: folders,
+				Name:      "reader",
+				Condition: `request.ip in cidr("10.0.0.0/8")`,
+			}
+			if err := s.SaveRelationship(ctx, conditional); err != nil {
+				t.Fatal(err)
+			}
+			if r, err := s.Relationship(ctx, "rel-4"); err != nil || r.Condition != conditional.Condition {
+				t.Fatalf("got %v, %v; want relationship with condition %s", r, err, conditional.Condition)
+			}
+			invalid := &gatekeeper.Relationship{
+				Entity:    &entity.Entity{ID: "rel-5"},
+				Namespace: folders,
+				Name:      "writer",
+				Condition: `request.ip`,
+			}
+			if err := s.SaveRelationship(ctx, invalid); !errors.Is(err, condition.ErrInvalidCondition) {
+				t.Fatalf("got %v; want %v", err, condition.ErrInvalidCondition)
+			}
+			if _, err := s.Relationship(ctx, "rel-5"); !errors.Is(err, gatekeeper.ErrNotFound) {
+				t.Fatalf("got %v; want invalid relationship not to be saved", err)
+			}
+			if err := s.DeleteRelationship(ctx, "rel-1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Relationship(ctx, "rel-1"); !errors.Is(err, gatekeeper.ErrNotFound) {
+				t.Fatalf("got %v; want deleted relationship not to be found", err)
+			}
+			if listed, err := s.Relationships(ctx, "documents"); err != nil || len(listed) != 2 {
+				t.Fatalf("got %v, %v; want viewer and owner of documents", listed, err)
+			}
+			if err := s.DeleteRelationship(ctx, "rel-1"); !errors.Is(err, gatekeeper.ErrNotFound) {
+				t.Fatalf("got %v; want %v", err, gatekeeper.ErrNotFound)
+			}
+		})
+	}
+}
+
+// TestGaslight_Save saves a principal whose state exceeds the largest item a gaslight collection can hold, while the
+// event raised by it does not, and expects neither to be stored.
+func TestGaslight_Save(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(KindGaslight, filepath.Join(t.TempDir(), "gatekeeper.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	alice := &gatekeeper.Principal{
+		Entity:  &entity.Entity{ID: "alice"},
+		Subject: strings.Repeat("a", 64<<10),
+	}
+	alice.Raise(entity.NewEvent("PrincipalEnrolled", gatekeeper.PrincipalEnrolled{
+		GroupID:     "eng",
+		PrincipalID: "alice",
+	}))
+	if err := s.SavePrincipal(ctx, alice); !errors.Is(err, gaslight.ErrPageOverflow) {
+		t.Fatalf("got %v; want %v", err, gaslight.ErrPageOverflow)
+	}
+	events, err := s.Events(ctx, gatekeeper.PrincipalKind, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("got %d events; want none stored without the principal", len(events))
+	}
+	if len(alice.Events()) != 1 {
+		t.Fatalf("got %d unflushed events; want %d", len(alice.Events()), 1)
+	}
+}
+
+func TestStore_Tuples(t *testing.T) {
+	tuples := []string{
+		"documents:doc_1#viewer@user:alice",
+		"documents:doc_1#viewer@group:eng#member",
+		"documents:doc_1#editor@user:bob",
+		"documents:doc_10#viewer@user:alice",
+		"folders:folder_1#viewer@user:alice",
+		"group:eng#member@user:alice",
+	}
+	matrix := []struct {
+		name     string
+		lookup   func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error)
+		expected []string
+	}{
+		{
+			name: "given object",
+			lookup: func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error) {
+				return s.ObjectTuples(ctx, "documents", "doc_1", "")
+			},
+			expected: []string{
+				"documents:doc_1#editor@user:bob",
+				"documents:doc_1#viewer@group:eng#member",
+			},
+		},
+		{
+			name: "given object and relation",
+			lookup: func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error) {
+				return s.ObjectTuples(ctx, "documents", "doc_1", "editor")
+			},
+			expected: []string{
+				"documents:doc_1#editor@user:bob",
+			},
+		},
+		{
+			name: "given subject",
+			lookup: func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error) {
+				return s.SubjectTuples(ctx, gatekeeper.Subject{Namespace: "user", Object: "alice"}, "")
+			},
+			expected: []string{
+				"documents:doc_10#viewer@user:alice",
+				"folders:folder_1#viewer@user:alice",
+				"group:eng#member@user:alice",
+			},
+		},
+		{
+			name: "given subject and namespace",
+			lookup: func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error) {
+				return s.SubjectTuples(ctx, gatekeeper.Subject{Namespace: "user", Object: "alice"}, "folders")
+			},
+			expected: []string{
+				"folders:folder_1#viewer@user:alice",
+			},
+		},
+		{
+			name: "given userset subject",
+			lookup: func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error) {
+				return s.SubjectTuples(ctx, gatekeeper.Subject{Namespace: "group", Object: "eng", Relation: "member"}, "")
+			},
+			expected: []string{
+				"documents:doc_1#viewer@group:eng#member",
+			},
+		},
+		{
+			name: "given relation",
+			lookup: func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error) {
+				return s.RelationTuples(ctx, "group", "member")
+			},
+			expected: []string{
+				"documents:doc_1#viewer@group:eng#member",
+				"group:eng#member@user:alice",
+			},
+		},
+		{
+			name: "given namespace without relation",
+			lookup: func(ctx context.Context, s gatekeeper.Store) ([]gatekeeper.RelationTuple, error) {
+				return s.RelationTuples(ctx, "user", "")
+			},
+			expected: []string{
+				"documents:doc_1#editor@user:bob",
+				"documents:doc_10#viewer@user:alice",
+				"folders:folder_1#viewer@user:alice",
+				"group:eng#member@user:alice",
+			},
+		},
+	}
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := st.open(t)
+			defer s.Close()
+			parsed := make([]gatekeeper.RelationTuple, 0, len(tuples))
+			for _, tuple := range tuples {
+				p, err := gatekeeper.ParseTuple(tuple)
+				if err != nil {
+					t.Fatal(err)
+				}
+				parsed = append(parsed, p)
+			}
+			// The first tuple is written twice, which must not store it twice, and is then deleted
+			if err := s.WriteTuples(ctx, parsed...); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.WriteTuples(ctx, parsed[0]); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.DeleteTuples(ctx, parsed[0]); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.DeleteTuples(ctx, parsed[0]); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.WriteTuples(ctx, gatekeeper.RelationTuple{}); !errors.Is(err, gatekeeper.ErrMalformedTuple) {
+				t.Fatalf("got %v; want %v", err, gatekeeper.ErrMalformedTuple)
+			}
+			for _, m := range matrix {
+				t.Run(m.name, func(t *testing.T) {
+					found, err := m.lookup(ctx, s)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(found) != len(m.expected) {
+						t.Fatalf("got %v; want %v", found, m.expected)
+					}
+					for i := range found {
+						if found[i].String() != m.expected[i] {
+							t.Fatalf("got %v; want %v", found, m.expected)
+						}
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestStore_Schema(t *testing.T) {
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := st.open(t)
+			defer s.Close()
+			if _, err := s.Schema(ctx, 0); !errors.Is(err, gatekeeper.ErrNotFound) {
+				t.Fatalf("got %v; want %v before any version is written", err, gatekeeper.ErrNotFound)
+			}
+			for i := 1; i <= 3; i++ {
+				v := &gatekeeper.SchemaVersion{Source: fmt.Sprintf("// version %d", i), Changes: []string{"changed"}}
+				if err := s.WriteSchema(ctx, v); err != nil {
+					t.Fatal(err)
+				}
+				if v.Version != uint(i) {
+					t.Fatalf("got version %d; want %d", v.Version, i)
+				}
+			}
+			events, err := s.Events(ctx, gatekeeper.SchemaKind, gatekeeper.SchemaEntityID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 3 || events[2].Name != "SchemaApplied" || events[2].Version != 3 {
+				t.Fatalf("got events %v; want a SchemaApplied event for every version", events)
+			}
+			matrix := []struct {
+				version  uint
+				expected string
+				err      error
+			}{
+				{version: 0, expected: "// version 3"},
+				{version: 2, expected: "// version 2"},
+				{version: 4, err: gatekeeper.ErrNotFound},
+			}
+			for _, m := range matrix {
+				v, err := s.Schema(ctx, m.version)
+				if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+					t.Fatalf("got %v; want %v for version %d", err, m.err, m.version)
+				}
+				if err == nil && v.Source != m.expected {
+					t.Fatalf("got %q; want %q for version %d", v.Source, m.expected, m.version)
+				}
+			}
+		})
+	}
+}
+
+func TestStore_Events(t *testing.T) {
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := st.open(t)
+			defer s.Close()
+
+			principal := &gatekeeper.Principal{Entity: &entity.Entity{ID: gatekeeper.SchemaEntityID}}
+			principal.Raise(entity.NewEvent("PrincipalEnrolled", gatekeeper.PrincipalEnrolled{
+				GroupID:     "eng",
+				PrincipalID: principal.ID,
+			}))
+			if err := s.SavePrincipal(ctx, principal); err != nil {
+				t.Fatal(err)
+			}
+			namespace := &gatekeeper.Namespace{Entity: &entity.Entity{ID: gatekeeper.SchemaEntityID}, Name: "schema"}
+			namespace.Raise(entity.NewEvent("NamespaceRenamed", map[string]string{"name": "schema"}))
+			namespace.Raise(entity.NewEvent("NamespaceRenamed", map[string]string{"name": "schema"}))
+			if err := s.SaveNamespace(ctx, namespace); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.WriteSchema(ctx, &gatekeeper.SchemaVersion{Source: "// version 1"}); err != nil {
+				t.Fatal(err)
+			}
+			matrix := []struct {
+				name     string
+				kind     gatekeeper.Kind
+				expected []string
+			}{
+				{
+					name:     "given principal sharing the ID",
+					kind:     gatekeeper.PrincipalKind,
+					expected: []string{"PrincipalEnrolled"},
+				},
+				{
+					name:     "given namespace sharing the ID",
+					kind:     gatekeeper.NamespaceKind,
+					expected: []string{"NamespaceRenamed", "NamespaceRenamed"},
+				},
+				{name: "given schema", kind: gatekeeper.SchemaKind, expected: []string{"SchemaApplied"}},
+				{name: "given kind without entity", kind: gatekeeper.PolicyKind},
+			}
+			for _, m := range matrix {
+				t.Run(m.name, func(t *testing.T) {
+					events, err := s.Events(ctx, m.kind, gatekeeper.SchemaEntityID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					names := make([]string, 0, len(events))
+					for _, e := range events {
+						names = append(names, e.Name)
+					}
+					if !slices.Equal(names, m.expected) {
+						t.Fatalf("got %v; want %v", names, m.expected)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestStore_Policies(t *testing.T) {
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := st.open(t)
+			defer s.Close()
+			alice := gatekeeper.Subject{Namespace: "user", Object: "alice"}
+			policies := []*gatekeeper.Policy{
+				{
+					Entity:     &entity.Entity{ID: "p-2"},
+					Effect:     gatekeeper.Permit,
+					Subjects:   []gatekeeper.Subject{alice},
+					Operations: []string{"documents:viewer", "documents:editor"},
+				},
+				{
+					Entity:     &entity.Entity{ID: "p-1"},
+					Effect:     gatekeeper.Deny,
+					Subjects:   []gatekeeper.Subject{alice},
+					Operations: []string{"documents:viewer"},
+					Resources:  []string{"doc_1"},
+				},
+			}
+			for _, p := range policies {
+				if err := s.SavePolicy(ctx, p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// A policy which no longer covers an operation is no longer listed under it
+			policies[0].Operations = []string{"documents:editor"}
+			if err := s.SavePolicy(ctx, policies[0]); err != nil {
+				t.Fatal(err)
+			}
+			listed, err := s.Policies(ctx, "documents:viewer")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listed) != 1 || listed[0].ID != "p-1" || listed[0].Resources[0] != "doc_1" {
+				t.Fatalf("got %v; want p-1 covering doc_1", listed)
+			}
+			if listed, err := s.Policies(ctx, "documents:editor"); err != nil || len(listed) != 1 || listed[0].ID != "p-2" {
+				t.Fatalf("got %v, %v; want p-2", listed, err)
+			}
+			if p, err := s.Policy(ctx, "p-2"); err != nil || p.Effect != gatekeeper.Permit || p.Subjects[0] != alice {
+				t.Fatalf("got %v, %v; want p-2 permitting alice", p, err)
+			}
+			for id, expected := range map[string][]string{
+				"p-1": {"PolicyDenied"},
+				"p-2": {"PolicyAllowed", "PolicyAllowed"},
+			} {
+				events, err := s.Events(ctx, gatekeeper.PolicyKind, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				names := make([]string, 0, len(events))
+				for _, e := range events {
+					names = append(names, e.Name)
+				}
+				if !slices.Equal(names, expected) {
+					t.Fatalf("got events %v of %s; want %v", names, id, expected)
+				}
+			}
+			invalid := &gatekeeper.Policy{Entity: &entity.Entity{ID: "p-3"}, Effect: "allow"}
+			if err := s.SavePolicy(ctx, invalid); !errors.Is(err, gatekeeper.ErrInvalidPolicy) {
+				t.Fatalf("got %v; want %v", err, gatekeeper.ErrInvalidPolicy)
+			}
+			if _, err := s.Policy(ctx, "p-3"); !errors.Is(err, gatekeeper.ErrNotFound) {
+				t.Fatalf("got %v; want %v", err, gatekeeper.ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("postgres", ""); err == nil {
+		t.Fatal("got nil; want error for unknown store")
+	}
+}
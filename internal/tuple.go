@@ -0,0 +1,129 @@
+package gatekeeper
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrMalformedTuple = errors.New("malformed tuple")
+)
+
+// Subject is who a relation tuple is about. A subject is either a direct subject, such as user:alice, or a userset,
+// such as group:eng#member, which stands for every subject holding the relation on the object.
+type Subject struct {
+	Namespace string
+	Object    string
+	// Relation is empty for direct subjects.
+	Relation string
+}
+
+// Userset returns true if the subject stands for every subject holding a relation on an object.
+func (s Subject) Userset() bool {
+	return s.Relation != ""
+}
+
+func (s Subject) String() string {
+	if s.Userset() {
+		return fmt.Sprintf("%s:%s#%s", s.Namespace, s.Object, s.Relation)
+	}
+	return fmt.Sprintf("%s:%s", s.Namespace, s.Object)
+}
+
+// RelationTuple states that the subject holds the relation on the object of the namespace. Tuples are written as
+// namespace:object#relation@subject, such as documents:doc_1#viewer@group:eng#member.
+type RelationTuple struct {
+	Namespace string
+	Object    string
+	Relation  string
+	Subject   Subject
+}
+
+func (t RelationTuple) String() string {
+	return fmt.Sprintf("%s:%s#%s@%s", t.Namespace, t.Object, t.Relation, t.Subject)
+}
+
+// Validate returns an error wrapping ErrMalformedTuple if a part of the tuple is missing or holds one of the characters
+// separating the parts of a tuple.
+func (t RelationTuple) Validate() error {
+	parts := []struct {
+		name, value string
+		optional    bool
+	}{
+		{name: "namespace", value: t.Namespace},
+		{name: "object", value: t.Object},
+		{name: "relation", value: t.Relation},
+		{name: "subject namespace", value: t.Subject.Namespace},
+		{name: "subject object", value: t.Subject.Object},
+		{name: "subject relation", value: t.Subject.Relation, optional: true},
+	}
+	for _, part := range parts {
+		if part.value == "" && !part.optional {
+			return fmt.Errorf("%w: %s is missing", ErrMalformedTuple, part.name)
+		}
+		if strings.ContainsAny(part.value, ":#@") {
+			return fmt.Errorf("%w: %s %q holds a separator", ErrMalformedTuple, part.name, part.value)
+		}
+	}
+	return nil
+}
+
+// ParseTuple parses a tuple written as namespace:object#relation@subject, where the subject is either written as
+// namespace:object or as the userset namespace:object#relation.
+func ParseTuple(s string) (RelationTuple, error) {
+	object, subject, ok := strings.Cut(s, "@")
+	if !ok {
+		return RelationTuple{}, fmt.Errorf("%w: %q has no subject", ErrMalformedTuple, s)
+	}
+	var t RelationTuple
+	var relation bool
+	t.Namespace, object, _ = strings.Cut(object, ":")
+	t.Object, t.Relation, relation = strings.Cut(object, "#")
+	if !relation {
+		return RelationTuple{}, fmt.Errorf("%w: %q has no relation", ErrMalformedTuple, s)
+	}
+	t.Subject.Namespace, subject, _ = strings.Cut(subject, ":")
+	t.Subject.Object, t.Subject.Relation, _ = strings.Cut(subject, "#")
+	if err := t.Validate(); err != nil {
+		return RelationTuple{}, fmt.Errorf("%q: %w", s, err)
+	}
+	return t, nil
+}
+
+// CompareTuples orders tuples by namespace, object, relation and subject, which is the order tuples of an object are
+// returned in by a TupleStore.
+func CompareTuples(a, b RelationTuple) int {
+	return cmp.Or(
+		strings.Compare(a.Namespace, b.Namespace),
+		strings.Compare(a.Object, b.Object),
+		strings.Compare(a.Relation, b.Relation),
+		strings.Compare(a.Subject.Namespace, b.Subject.Namespace),
+		strings.Compare(a.Subject.Object, b.Subject.Object),
+		strings.Compare(a.Subject.Relation, b.Subject.Relation),
+	)
+}
+
+// TupleStore persists relation tuples indexed both by object and by subject, which makes it efficient to look up who
+// holds a relation on an object as well as which objects a subject holds a relation on.
+type TupleStore interface {
+	// WriteTuples stores the provided tuples, writing a tuple which is already stored has no effect. An error wrapping
+	// ErrMalformedTuple is returned, and nothing is written, if any of the tuples is invalid.
+	WriteTuples(ctx context.Context, tuples ...RelationTuple) error
+	// DeleteTuples removes the provided tuples, deleting a tuple which is not stored has no effect.
+	DeleteTuples(ctx context.Context, tuples ...RelationTuple) error
+	// ObjectTuples returns the tuples of the object holding the provided relation, or any relation if it is empty,
+	// ordered by relation and subject.
+	ObjectTuples(ctx context.Context, namespace, object, relation string) ([]RelationTuple, error)
+	// SubjectTuples returns the tuples of the subject on objects of the provided namespace, or any namespace if it is
+	// empty, ordered by namespace, object and relation. Only tuples naming the subject itself are returned, the
+	// tuples of usersets the subject is a member of are not.
+	SubjectTuples(ctx context.Context, subject Subject, namespace string) ([]RelationTuple, error)
+	// RelationTuples returns the tuples referencing the relation of the namespace, either as the relation held on an
+	// object or as the relation of a userset subject, or every tuple referencing the namespace if the relation is empty.
+	// Tuples are ordered as by CompareTuples. Every tuple of the namespace is visited, which makes it intended for
+	// schema changes rather than checks.
+	RelationTuples(ctx context.Context, namespace, relation string) ([]RelationTuple, error)
+}
@@ -0,0 +1,75 @@
+package gatekeeper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseTuple(t *testing.T) {
+	matrix := []struct {
+		name     string
+		tuple    string
+		expected RelationTuple
+		err      error
+	}{
+		{
+			name:  "given direct subject",
+			tuple: "documents:doc_1#viewer@user:alice",
+			expected: RelationTuple{
+				Namespace: "documents",
+				Object:    "doc_1",
+				Relation:  "viewer",
+				Subject:   Subject{Namespace: "user", Object: "alice"},
+			},
+		},
+		{
+			name:  "given userset subject",
+			tuple: "documents:doc_1#viewer@group:eng#member",
+			expected: RelationTuple{
+				Namespace: "documents",
+				Object:    "doc_1",
+				Relation:  "viewer",
+				Subject:   Subject{Namespace: "group", Object: "eng", Relation: "member"},
+			},
+		},
+		{
+			name:  "given missing subject",
+			tuple: "documents:doc_1#viewer",
+			err:   ErrMalformedTuple,
+		},
+		{
+			name:  "given missing relation",
+			tuple: "documents:doc_1@user:alice",
+			err:   ErrMalformedTuple,
+		},
+		{
+			name:  "given missing namespace",
+			tuple: "doc_1#viewer@user:alice",
+			err:   ErrMalformedTuple,
+		},
+		{
+			name:  "given subject without namespace",
+			tuple: "documents:doc_1#viewer@alice",
+			err:   ErrMalformedTuple,
+		},
+		{
+			name:  "given separator within part",
+			tuple: "documents:doc_1#viewer@user:alice#member#owner",
+			err:   ErrMalformedTuple,
+		},
+	}
+	for _, m := range matrix {
+		t.Run(m.name, func(t *testing.T) {
+			tuple, err := ParseTuple(m.tuple)
+			if !errors.Is(err, m.err) || (err != nil && m.err == nil) {
+				t.Fatalf("got %v; want %v", err, m.err)
+			}
+			if tuple != m.expected {
+				t.Fatalf("got %v; want %v", tuple, m.expected)
+			}
+			if err == nil && tuple.String() != m.tuple {
+				t.Fatalf("got %s; want %s", tuple, m.tuple)
+			}
+		})
+	}
+}
@@ -2,25 +2,219 @@ package grpc
 
 import (
 	"context"
-	"crypto/tls"
-	"github.com/ernilsson/gatekeeper/internal/pb"
+	"errors"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/condition"
+	gatekeeperv1 "github.com/ernilsson/gatekeeper/internal/pb/gatekeeper/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
 )
 
-func Start(port string) error {
-	lis, err := tls.Listen("tcp", ":"+port, &tls.Config{})
+// Start serves the gRPC API on the provided port until the listener fails. Transport security is configured through
+// the provided server options, such as grpc.Creds, and the API is served in plaintext without them.
+func Start(port string, store gatekeeper.Store, checker *gatekeeper.Checker, policies *gatekeeper.Policies,
+	opts ...grpc.ServerOption) error {
+	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		return err
 	}
-	srv := grpc.NewServer()
-	pb.RegisterAuthorizationServer(srv, authorization{})
-	return srv.Serve(lis)
+	return NewServer(store, checker, policies, opts...).Serve(lis)
+}
+
+// NewServer creates a gRPC server exposing the API on top of the provided store, with relations evaluated by the
+// provided checker and policies combined by the provided policies.
+func NewServer(store gatekeeper.Store, checker *gatekeeper.Checker, policies *gatekeeper.Policies,
+	opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(opts...)
+	gatekeeperv1.RegisterAuthorizationServer(srv, authorization{
+		store:     store,
+		checker:   checker,
+		policies:  policies,
+		resources: newPager[string](),
+		subjects:  newPager[gatekeeper.Subject](),
+	})
+	return srv
 }
 
 type authorization struct {
-	pb.AuthorizationServer
+	gatekeeperv1.UnimplementedAuthorizationServer
+	store     gatekeeper.Store
+	checker   *gatekeeper.Checker
+	policies  *gatekeeper.Policies
+	resources *pager[string]
+	subjects  *pager[gatekeeper.Subject]
+}
+
+func (a authorization) Authorize(ctx context.Context, msg *gatekeeperv1.AuthorizeRequest) (*gatekeeperv1.AuthorizeResponse, error) {
+	attrs := condition.Attributes{
+		Resource: decodeAttributes(msg.GetResourceAttributes()),
+		Request:  decodeAttributes(msg.GetRequestAttributes()),
+	}
+	decision, err := gatekeeper.Authorize(ctx, a.store, a.policies, msg.GetPrincipalId(), msg.GetOperation(),
+		msg.GetResource(), attrs)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	res := &gatekeeperv1.AuthorizeResponse{Granted: decision.Granted}
+	if c := decision.Challenge; c != nil {
+		res.Challenge = &gatekeeperv1.Challenge{Namespace: c.Namespace.Name, Relation: c.Name}
+	}
+	return res, nil
 }
 
-func (a authorization) Authorize(ctx context.Context, msg *pb.AuthorizationRequest) (*pb.AuthorizationResponse, error) {
-	panic("not implemented")
+// decodeAttributes maps the names of the attributes to their values, the last value of an attribute listed more than
+// once wins.
+func decodeAttributes(attributes []*gatekeeperv1.Attribute) map[string]string {
+	values := make(map[string]string, len(attributes))
+	for _, a := range attributes {
+		values[a.GetName()] = a.GetValue()
+	}
+	return values
+}
+
+func (a authorization) Decide(ctx context.Context, msg *gatekeeperv1.DecideRequest) (*gatekeeperv1.DecideResponse, error) {
+	attrs := condition.Attributes{
+		Resource: decodeAttributes(msg.GetResourceAttributes()),
+		Request:  decodeAttributes(msg.GetRequestAttributes()),
+	}
+	permitted, err := a.policies.Decide(ctx, decodeSubject(msg.GetSubject()), msg.GetOperation(), msg.GetResource(),
+		attrs)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gatekeeperv1.DecideResponse{Permitted: permitted}, nil
+}
+
+func (a authorization) Expand(ctx context.Context, msg *gatekeeperv1.ExpandRequest) (*gatekeeperv1.ExpandResponse, error) {
+	tree, err := a.checker.Expand(ctx, msg.GetNamespace(), msg.GetObject(), msg.GetRelation())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gatekeeperv1.ExpandResponse{Tree: encodeTree(tree)}, nil
+}
+
+// resourcesRequest identifies a lookup of resources, which the cursors of its results are only accepted along with.
+type resourcesRequest struct {
+	namespace, relation string
+	subject             gatekeeper.Subject
+}
+
+func (a authorization) LookupResources(msg *gatekeeperv1.LookupResourcesRequest, stream gatekeeperv1.Authorization_LookupResourcesServer) error {
+	size, err := pageSize(msg.GetPageSize())
+	if err != nil {
+		return err
+	}
+	req := resourcesRequest{
+		namespace: msg.GetNamespace(),
+		relation:  msg.GetRelation(),
+		subject:   decodeSubject(msg.GetSubject()),
+	}
+	var l *lookup[string]
+	if msg.GetCursor() == "" {
+		l, err = a.resources.start(req, func(ctx context.Context, fn func(string) error) error {
+			return a.checker.LookupResources(ctx, req.namespace, req.relation, req.subject, fn)
+		})
+	} else {
+		l, err = a.resources.resume(msg.GetCursor(), req)
+	}
+	if err != nil {
+		return err
+	}
+	return a.resources.page(stream.Context(), l, size, func(object string, cursor string) error {
+		return stream.Send(&gatekeeperv1.LookupResourcesResponse{Object: object, Cursor: cursor})
+	})
+}
+
+// subjectsRequest identifies a lookup of subjects, which the cursors of its results are only accepted along with.
+type subjectsRequest struct {
+	namespace, object, relation, subjectNamespace string
+}
+
+func (a authorization) LookupSubjects(msg *gatekeeperv1.LookupSubjectsRequest, stream gatekeeperv1.Authorization_LookupSubjectsServer) error {
+	size, err := pageSize(msg.GetPageSize())
+	if err != nil {
+		return err
+	}
+	req := subjectsRequest{
+		namespace:        msg.GetNamespace(),
+		object:           msg.GetObject(),
+		relation:         msg.GetRelation(),
+		subjectNamespace: msg.GetSubjectNamespace(),
+	}
+	var l *lookup[gatekeeper.Subject]
+	if msg.GetCursor() == "" {
+		l, err = a.subjects.start(req, func(ctx context.Context, fn func(gatekeeper.Subject) error) error {
+			return a.checker.LookupSubjects(ctx, req.namespace, req.object, req.relation, req.subjectNamespace, fn)
+		})
+	} else {
+		l, err = a.subjects.resume(msg.GetCursor(), req)
+	}
+	if err != nil {
+		return err
+	}
+	return a.subjects.page(stream.Context(), l, size, func(s gatekeeper.Subject, cursor string) error {
+		return stream.Send(&gatekeeperv1.LookupSubjectsResponse{Subject: encodeSubject(s), Cursor: cursor})
+	})
+}
+
+func encodeTree(tree *gatekeeper.UsersetTree) *gatekeeperv1.UsersetTree {
+	msg := &gatekeeperv1.UsersetTree{
+		Userset:   encodeSubject(tree.Userset),
+		Subjects:  make([]*gatekeeperv1.Subject, 0, len(tree.Subjects)),
+		Usersets:  make([]*gatekeeperv1.UsersetTree, 0, len(tree.Usersets)),
+		Inherited: make([]*gatekeeperv1.UsersetTree, 0, len(tree.Inherited)),
+		Cycle:     tree.Cycle,
+	}
+	for _, s := range tree.Subjects {
+		msg.Subjects = append(msg.Subjects, encodeSubject(s))
+	}
+	for _, t := range tree.Usersets {
+		msg.Usersets = append(msg.Usersets, encodeTree(t))
+	}
+	for _, t := range tree.Inherited {
+		msg.Inherited = append(msg.Inherited, encodeTree(t))
+	}
+	return msg
+}
+
+func encodeSubject(s gatekeeper.Subject) *gatekeeperv1.Subject {
+	return &gatekeeperv1.Subject{
+		Namespace: s.Namespace,
+		Object:    s.Object,
+		Relation:  s.Relation,
+	}
+}
+
+func decodeSubject(msg *gatekeeperv1.Subject) gatekeeper.Subject {
+	return gatekeeper.Subject{
+		Namespace: msg.GetNamespace(),
+		Object:    msg.GetObject(),
+		Relation:  msg.GetRelation(),
+	}
+}
+
+// toStatus translates the errors of gatekeeper into gRPC status errors, errors without a status of their own are
+// reported as internal errors.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, gatekeeper.ErrNoOperationFound), errors.Is(err, gatekeeper.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, gatekeeper.ErrNoExplicitPolicy):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, gatekeeper.ErrMalformedTuple), errors.Is(err, condition.ErrMissingAttribute),
+		errors.Is(err, condition.ErrInvalidAttribute):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, gatekeeper.ErrConditionalRelation):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, gatekeeper.ErrMaxDepth):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
 }
@@ -0,0 +1,654 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"github.com/ernilsson/gatekeeper/internal"
+	"github.com/ernilsson/gatekeeper/internal/entity"
+	gatekeeperv1 "github.com/ernilsson/gatekeeper/internal/pb/gatekeeper/v1"
+	"github.com/ernilsson/gatekeeper/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// stores returns a constructor for every implementation of gatekeeper.Store.
+func stores() []struct {
+	name string
+	open func(t *testing.T) gatekeeper.Store
+} {
+	return []struct {
+		name string
+		open func(t *testing.T) gatekeeper.Store
+	}{
+		{
+			name: "memory",
+			open: func(t *testing.T) gatekeeper.Store {
+				return store.NewMemory()
+			},
+		},
+		{
+			name: "gaslight",
+			open: func(t *testing.T) gatekeeper.Store {
+				s, err := store.Open(store.KindGaslight, filepath.Join(t.TempDir(), "gatekeeper.db"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			},
+		},
+	}
+}
+
+func TestAuthorization_Authorize(t *testing.T) {
+	internal := []*gatekeeperv1.Attribute{{Name: "classification", Value: "internal"}}
+	matrix := []struct {
+		name      string
+		principal string
+		operation string
+		object    string
+		resource  []*gatekeeperv1.Attribute
+		request   []*gatekeeperv1.Attribute
+		granted   bool
+		challenge *gatekeeperv1.Challenge
+		code      codes.Code
+	}{
+		{
+			name:      "given principal permitted by policy without tuples",
+			principal: "alice",
+			operation: "documents:viewer",
+			object:    "doc_5",
+			granted:   true,
+		},
+		{
+			name:      "given principal denied by policy in spite of tuple",
+			principal: "alice",
+			operation: "documents:viewer",
+			object:    "doc_2",
+			granted:   false,
+		},
+		{
+			name:      "given principal holding relation without policy",
+			principal: "bob",
+			operation: "documents:editor",
+			object:    "doc_1",
+			granted:   true,
+		},
+		{
+			name:      "given principal holding inherited relation without policy",
+			principal: "bob",
+			operation: "documents:viewer",
+			object:    "doc_1",
+			granted:   true,
+		},
+		{
+			name:      "given principal neither permitted nor holding relation",
+			principal: "bob",
+			operation: "documents:viewer",
+			object:    "doc_2",
+			code:      codes.PermissionDenied,
+		},
+		{
+			name:      "given missing resource",
+			principal: "alice",
+			operation: "documents:viewer",
+			code:      codes.InvalidArgument,
+		},
+		{
+			name:      "given unknown operation",
+			principal: "alice",
+			operation: "documents:owner",
+			object:    "doc_1",
+			code:      codes.NotFound,
+		},
+		{
+			name:      "given malformed operation",
+			principal: "alice",
+			operation: "viewer",
+			object:    "doc_1",
+			code:      codes.NotFound,
+		},
+		{
+			name:      "given unknown principal",
+			principal: "mallory",
+			operation: "documents:viewer",
+			object:    "doc_1",
+			code:      codes.NotFound,
+		},
+		{
+			name:      "given attributes satisfying condition",
+			principal: "bob",
+			operation: "documents:reader",
+			object:    "doc_4",
+			resource:  internal,
+			request:   []*gatekeeperv1.Attribute{{Name: "ip", Value: "10.0.0.1"}},
+			granted:   true,
+		},
+		{
+			name:      "given attributes not satisfying condition",
+			principal: "bob",
+			operation: "documents:reader",
+			object:    "doc_4",
+			resource:  []*gatekeeperv1.Attribute{{Name: "classification", Value: "secret"}},
+			request:   []*gatekeeperv1.Attribute{{Name: "ip", Value: "10.0.0.1"}},
+			granted:   false,
+			challenge: &gatekeeperv1.Challenge{Namespace: "documents", Relation: "reader"},
+		},
+		{
+			name:      "given attributes not satisfying condition of relation not held",
+			principal: "alice",
+			operation: "documents:reader",
+			object:    "doc_4",
+			resource:  []*gatekeeperv1.Attribute{{Name: "classification", Value: "secret"}},
+			request:   []*gatekeeperv1.Attribute{{Name: "ip", Value: "10.0.0.1"}},
+			code:      codes.PermissionDenied,
+		},
+		{
+			name:      "given attribute missing from condition",
+			principal: "bob",
+			operation: "documents:reader",
+			object:    "doc_4",
+			resource:  internal,
+			code:      codes.InvalidArgument,
+		},
+	}
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			client := serve(t, seed(t, st.open(t)))
+			for _, m := range matrix {
+				t.Run(m.name, func(t *testing.T) {
+					res, err := client.Authorize(context.Background(), &gatekeeperv1.AuthorizeRequest{
+						PrincipalId:        m.principal,
+						Operation:          m.operation,
+						Resource:           m.object,
+						ResourceAttributes: m.resource,
+						RequestAttributes:  m.request,
+					})
+					if code := status.Code(err); code != m.code {
+						t.Fatalf("got %v (%v); want %v", code, err, m.code)
+					}
+					if err == nil && res.GetGranted() != m.granted {
+						t.Fatalf("got granted %t; want %t", res.GetGranted(), m.granted)
+					}
+					if err == nil && !proto.Equal(res.GetChallenge(), m.challenge) {
+						t.Fatalf("got challenge %v; want %v", res.GetChallenge(), m.challenge)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestAuthorization_Decide(t *testing.T) {
+	matrix := []struct {
+		name       string
+		subject    string
+		resource   string
+		attributes []*gatekeeperv1.Attribute
+		permitted  bool
+		code       codes.Code
+	}{
+		{
+			name:      "given member of permitted group",
+			subject:   "alice",
+			resource:  "doc_1",
+			permitted: true,
+		},
+		{
+			name:      "given denied resource overriding group",
+			subject:   "alice",
+			resource:  "doc_2",
+			permitted: false,
+		},
+		{
+			name:     "given subject without policy",
+			subject:  "bob",
+			resource: "doc_1",
+			code:     codes.PermissionDenied,
+		},
+		{
+			name:       "given member of conditional userset with attributes satisfying condition",
+			subject:    "bob",
+			resource:   "doc_4",
+			attributes: []*gatekeeperv1.Attribute{{Name: "ip", Value: "10.0.0.1"}},
+			permitted:  true,
+		},
+		{
+			name:       "given member of conditional userset with attributes not satisfying condition",
+			subject:    "bob",
+			resource:   "doc_4",
+			attributes: []*gatekeeperv1.Attribute{{Name: "ip", Value: "192.168.0.1"}},
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:     "given member of conditional userset without attributes",
+			subject:  "bob",
+			resource: "doc_4",
+			code:     codes.InvalidArgument,
+		},
+	}
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			client := serve(t, seed(t, st.open(t)))
+			for _, m := range matrix {
+				t.Run(m.name, func(t *testing.T) {
+					res, err := client.Decide(context.Background(), &gatekeeperv1.DecideRequest{
+						Subject:            &gatekeeperv1.Subject{Namespace: "principal", Object: m.subject},
+						Operation:          "documents:viewer",
+						Resource:           m.resource,
+						ResourceAttributes: []*gatekeeperv1.Attribute{{Name: "classification", Value: "internal"}},
+						RequestAttributes:  m.attributes,
+					})
+					if code := status.Code(err); code != m.code {
+						t.Fatalf("got %v (%v); want %v", code, err, m.code)
+					}
+					if err == nil && res.GetPermitted() != m.permitted {
+						t.Fatalf("got permitted %t; want %t", res.GetPermitted(), m.permitted)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestAuthorization_Expand(t *testing.T) {
+	matrix := []struct {
+		name     string
+		request  *gatekeeperv1.ExpandRequest
+		expected *gatekeeperv1.UsersetTree
+		code     codes.Code
+	}{
+		{
+			name:    "given relation with usersets and inherited relation",
+			request: &gatekeeperv1.ExpandRequest{Namespace: "documents", Object: "doc_1", Relation: "viewer"},
+			expected: &gatekeeperv1.UsersetTree{
+				Userset: &gatekeeperv1.Subject{Namespace: "documents", Object: "doc_1", Relation: "viewer"},
+				Usersets: []*gatekeeperv1.UsersetTree{
+					{
+						Userset:  &gatekeeperv1.Subject{Namespace: "group", Object: "eng", Relation: "member"},
+						Subjects: []*gatekeeperv1.Subject{{Namespace: "principal", Object: "alice"}},
+					},
+				},
+				Inherited: []*gatekeeperv1.UsersetTree{
+					{
+						Userset:  &gatekeeperv1.Subject{Namespace: "documents", Object: "doc_1", Relation: "editor"},
+						Subjects: []*gatekeeperv1.Subject{{Namespace: "principal", Object: "bob"}},
+					},
+				},
+			},
+		},
+		{
+			name:    "given missing relation",
+			request: &gatekeeperv1.ExpandRequest{Namespace: "documents", Object: "doc_1"},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name:    "given conditional relation",
+			request: &gatekeeperv1.ExpandRequest{Namespace: "documents", Object: "doc_4", Relation: "reader"},
+			code:    codes.FailedPrecondition,
+		},
+	}
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			s := seed(t, st.open(t))
+			client := serve(t, s)
+			for _, m := range matrix {
+				t.Run(m.name, func(t *testing.T) {
+					res, err := client.Expand(context.Background(), m.request)
+					if code := status.Code(err); code != m.code {
+						t.Fatalf("got %v (%v); want %v", code, err, m.code)
+					}
+					if err == nil && !proto.Equal(res.GetTree(), m.expected) {
+						t.Fatalf("got %v; want %v", res.GetTree(), m.expected)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestAuthorization_LookupResources(t *testing.T) {
+	alice := &gatekeeperv1.Subject{Namespace: "principal", Object: "alice"}
+	bob := &gatekeeperv1.Subject{Namespace: "principal", Object: "bob"}
+	matrix := []struct {
+		name     string
+		request  *gatekeeperv1.LookupResourcesRequest
+		expected []string
+		code     codes.Code
+	}{
+		{
+			name:     "given no page size",
+			request:  &gatekeeperv1.LookupResourcesRequest{Namespace: "documents", Relation: "viewer", Subject: alice},
+			expected: []string{"doc_2", "doc_3", "doc_1"},
+		},
+		{
+			name: "given page size",
+			request: &gatekeeperv1.LookupResourcesRequest{
+				Namespace: "documents", Relation: "viewer", Subject: alice, PageSize: 2,
+			},
+			expected: []string{"doc_2", "doc_3"},
+		},
+		{
+			name: "given malformed cursor",
+			request: &gatekeeperv1.LookupResourcesRequest{
+				Namespace: "documents", Relation: "viewer", Subject: alice, Cursor: "%",
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name:    "given missing subject",
+			request: &gatekeeperv1.LookupResourcesRequest{Namespace: "documents", Relation: "viewer"},
+			code:    codes.InvalidArgument,
+		},
+		{
+			name: "given conditional relation",
+			request: &gatekeeperv1.LookupResourcesRequest{
+				Namespace: "documents", Relation: "reader", Subject: bob,
+			},
+			code: codes.FailedPrecondition,
+		},
+	}
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			s := seed(t, st.open(t))
+			client := serve(t, s)
+			for _, m := range matrix {
+				t.Run(m.name, func(t *testing.T) {
+					stream, err := client.LookupResources(context.Background(), m.request)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var objects []string
+					for {
+						res, err := stream.Recv()
+						if errors.Is(err, io.EOF) {
+							break
+						}
+						if code := status.Code(err); code != m.code {
+							t.Fatalf("got %v (%v); want %v", code, err, m.code)
+						}
+						if err != nil {
+							return
+						}
+						if res.GetCursor() == "" {
+							t.Fatalf("got no cursor for %s", res.GetObject())
+						}
+						objects = append(objects, res.GetObject())
+					}
+					if m.code != codes.OK {
+						t.Fatalf("got %v; want %v", codes.OK, m.code)
+					}
+					if !reflect.DeepEqual(objects, m.expected) {
+						t.Fatalf("got %v; want %v", objects, m.expected)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestAuthorization_LookupSubjects(t *testing.T) {
+	matrix := []struct {
+		name     string
+		request  *gatekeeperv1.LookupSubjectsRequest
+		expected []string
+		code     codes.Code
+	}{
+		{
+			name:     "given no page size",
+			request:  &gatekeeperv1.LookupSubjectsRequest{Namespace: "documents", Object: "doc_1", Relation: "viewer"},
+			expected: []string{"principal:alice", "principal:bob"},
+		},
+		{
+			name: "given page size",
+			request: &gatekeeperv1.LookupSubjectsRequest{
+				Namespace: "documents", Object: "doc_1", Relation: "viewer", PageSize: 1,
+			},
+			expected: []string{"principal:alice"},
+		},
+		{
+			name: "given subject namespace without subjects",
+			request: &gatekeeperv1.LookupSubjectsRequest{
+				Namespace: "documents", Object: "doc_1", Relation: "viewer", SubjectNamespace: "service",
+			},
+		},
+		{
+			name: "given negative page size",
+			request: &gatekeeperv1.LookupSubjectsRequest{
+				Namespace: "documents", Object: "doc_1", Relation: "viewer", PageSize: -1,
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name:    "given conditional relation",
+			request: &gatekeeperv1.LookupSubjectsRequest{Namespace: "documents", Object: "doc_4", Relation: "reader"},
+			code:    codes.FailedPrecondition,
+		},
+	}
+	for _, st := range stores() {
+		t.Run(st.name, func(t *testing.T) {
+			s := seed(t, st.open(t))
+			client := serve(t, s)
+			for _, m := range matrix {
+				t.Run(m.name, func(t *testing.T) {
+					stream, err := client.LookupSubjects(context.Background(), m.request)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var subjects []string
+					for {
+						res, err := stream.Recv()
+						if errors.Is(err, io.EOF) {
+							break
+						}
+						if code := status.Code(err); code != m.code {
+							t.Fatalf("got %v (%v); want %v", code, err, m.code)
+						}
+						if err != nil {
+							return
+						}
+						subject := decodeSubject(res.GetSubject()).String()
+						if res.GetCursor() == "" {
+							t.Fatalf("got no cursor for %s", subject)
+						}
+						subjects = append(subjects, subject)
+					}
+					if m.code != codes.OK {
+						t.Fatalf("got %v; want %v", codes.OK, m.code)
+					}
+					if !reflect.DeepEqual(subjects, m.expected) {
+						t.Fatalf("got %v; want %v", subjects, m.expected)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestAuthorization_LookupResources_Pages(t *testing.T) {
+	client := serve(t, seed(t, store.NewMemory()))
+	ctx := context.Background()
+	request := &gatekeeperv1.LookupResourcesRequest{
+		Namespace: "documents",
+		Relation:  "viewer",
+		Subject:   &gatekeeperv1.Subject{Namespace: "principal", Object: "alice"},
+		PageSize:  1,
+	}
+	// page requests a page of the lookup and returns its objects along with the cursor of the last of them
+	page := func(cursor string) ([]string, string, error) {
+		req := proto.Clone(request).(*gatekeeperv1.LookupResourcesRequest)
+		req.Cursor = cursor
+		stream, err := client.LookupResources(ctx, req)
+		if err != nil {
+			return nil, "", err
+		}
+		var objects []string
+		for {
+			res, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return objects, cursor, nil
+			}
+			if err != nil {
+				return objects, cursor, err
+			}
+			objects, cursor = append(objects, res.GetObject()), res.GetCursor()
+		}
+	}
+	var objects, cursors []string
+	for cursor := ""; ; {
+		page, next, err := page(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		objects, cursors, cursor = append(objects, page...), append(cursors, next), next
+	}
+	if expected := []string{"doc_2", "doc_3", "doc_1"}; !reflect.DeepEqual(objects, expected) {
+		t.Fatalf("got %v; want %v", objects, expected)
+	}
+	// The lookup is gone once complete, and can only be resumed after the last object sent while in progress
+	if _, _, err := page(cursors[len(cursors)-1]); status.Code(err) != codes.NotFound {
+		t.Fatalf("got %v; want %v for the cursor of a completed lookup", err, codes.NotFound)
+	}
+	_, first, err := page("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := page(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := page(first); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("got %v; want %v for the cursor of an earlier page", err, codes.FailedPrecondition)
+	}
+	_, first, err = page("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Relation = "editor"
+	if _, _, err := page(first); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v; want %v for the cursor of another lookup", err, codes.InvalidArgument)
+	}
+}
+
+// seed stores the documents namespace with a viewer, an editor and a conditional reader relationship, along with the
+// principals alice and bob. The tuples make the members of the eng group viewers of doc_1, with alice as the only
+// member, and bob an editor of doc_1. Alice is also a viewer of doc_2 and an editor of doc_3, while bob is a reader of
+// doc_4. The policies permit the members of the eng group to view documents, except for alice viewing doc_2, and the
+// readers of doc_4 to view it.
+func seed(t *testing.T, s gatekeeper.Store) gatekeeper.Store {
+	t.Helper()
+	t.Cleanup(func() { _ = s.Close() })
+	ctx := context.Background()
+	documents := gatekeeper.Namespace{Entity: &entity.Entity{ID: "ns-1"}, Name: "documents"}
+	if err := s.SaveNamespace(ctx, &documents); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*gatekeeper.Relationship{
+		{Entity: &entity.Entity{ID: "rel-1"}, Namespace: documents, Name: "viewer"},
+		{Entity: &entity.Entity{ID: "rel-2"}, Namespace: documents, Name: "editor"},
+		{
+			Entity:    &entity.Entity{ID: "rel-3"},
+			Namespace: documents,
+			Name:      "reader",
+			Condition: `resource.classification != "secret" && request.ip in cidr("10.0.0.0/8")`,
+		},
+	} {
+		if err := s.SaveRelationship(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice := &gatekeeper.Principal{Entity: &entity.Entity{ID: "alice"}}
+	bob := &gatekeeper.Principal{Entity: &entity.Entity{ID: "bob"}}
+	for _, p := range []*gatekeeper.Principal{alice, bob} {
+		if err := s.SavePrincipal(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tuples []gatekeeper.RelationTuple
+	for _, tuple := range []string{
+		"documents:doc_1#viewer@group:eng#member",
+		"documents:doc_1#editor@principal:bob",
+		"group:eng#member@principal:alice",
+		"documents:doc_2#viewer@principal:alice",
+		"documents:doc_3#editor@principal:alice",
+		"documents:doc_4#reader@principal:bob",
+	} {
+		parsed, err := gatekeeper.ParseTuple(tuple)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tuples = append(tuples, parsed)
+	}
+	if err := s.WriteTuples(ctx, tuples...); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*gatekeeper.Policy{
+		{
+			Entity:     &entity.Entity{ID: "policy-1"},
+			Effect:     gatekeeper.Permit,
+			Subjects:   []gatekeeper.Subject{{Namespace: "group", Object: "eng", Relation: "member"}},
+			Operations: []string{"documents:viewer"},
+		},
+		{
+			Entity:     &entity.Entity{ID: "policy-2"},
+			Effect:     gatekeeper.Deny,
+			Subjects:   []gatekeeper.Subject{{Namespace: "principal", Object: "alice"}},
+			Operations: []string{"documents:viewer"},
+			Resources:  []string{"doc_2"},
+		},
+		{
+			Entity:     &entity.Entity{ID: "policy-3"},
+			Effect:     gatekeeper.Permit,
+			Subjects:   []gatekeeper.Subject{{Namespace: "documents", Object: "doc_4", Relation: "reader"}},
+			Operations: []string{"documents:viewer"},
+			Resources:  []string{"doc_4"},
+		},
+	} {
+		if err := s.SavePolicy(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// serve starts a server on a loopback socket and returns a client connected to it.
+func serve(t *testing.T, s gatekeeper.Store) gatekeeperv1.AuthorizationClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relationships, err := s.Relationships(context.Background(), "documents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker, err := gatekeeper.NewChecker(s, []gatekeeper.Inheritance{{
+		Parent: gatekeeper.Relationship{Namespace: gatekeeper.Namespace{Name: "documents"}, Name: "editor"},
+		Child:  gatekeeper.Relationship{Namespace: gatekeeper.Namespace{Name: "documents"}, Name: "viewer"},
+	}}, gatekeeper.WithConditions(relationships...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, err := gatekeeper.NewPolicies(s, checker, gatekeeper.DenyOverrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(s, checker, policies)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return gatekeeperv1.NewAuthorizationClient(conn)
+}
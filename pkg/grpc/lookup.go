@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	// maxLookups is the number of lookups which may be running or paused at once.
+	maxLookups = 1024
+	// lookupTTL is how long a lookup stays paused between two pages before it is cancelled.
+	lookupTTL = time.Minute
+)
+
+// pager streams the results of lookups a page at a time. A lookup is paused once its page has been sent and resumed
+// by the request for the next page, which continues the lookup where it left off rather than running it again.
+type pager[T any] struct {
+	mu     sync.Mutex
+	paused map[string]*lookup[T]
+	// active is the number of lookups running or paused.
+	active int
+}
+
+func newPager[T any]() *pager[T] {
+	return &pager[T]{paused: make(map[string]*lookup[T])}
+}
+
+// lookup runs in a goroutine of its own, which hands its results over one at a time as they are received.
+type lookup[T any] struct {
+	id string
+	// request identifies what is looked up, a cursor is only accepted along with the request it was issued for.
+	request any
+	results chan T
+	// err is set before results is closed.
+	err    error
+	cancel context.CancelFunc
+	// sent is the number of results sent, the cursor of the last of them resumes the lookup.
+	sent   uint64
+	expiry *time.Timer
+}
+
+// start starts looking up the results of the request, where run calls fn with every result as it is found.
+func (p *pager[T]) start(request any, run func(ctx context.Context, fn func(T) error) error) (*lookup[T], error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	p.mu.Lock()
+	if p.active >= maxLookups {
+		p.mu.Unlock()
+		return nil, status.Errorf(codes.ResourceExhausted, "%d lookups are already in progress", maxLookups)
+	}
+	p.active++
+	p.mu.Unlock()
+	// The lookup outlives the request starting it, it is cancelled once abandoned by the requests of its pages
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &lookup[T]{id: hex.EncodeToString(id), request: request, results: make(chan T), cancel: cancel}
+	go func() {
+		l.err = run(ctx, func(result T) error {
+			select {
+			case l.results <- result:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(l.results)
+		cancel()
+		p.mu.Lock()
+		p.active--
+		p.mu.Unlock()
+	}()
+	return l, nil
+}
+
+// resume takes the lookup paused after the result the cursor was sent with, which must be the last result sent.
+func (p *pager[T]) resume(cursor string, request any) (*lookup[T], error) {
+	id, sent, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.paused[id]
+	switch {
+	case !ok:
+		return nil, status.Errorf(codes.NotFound, "the lookup of cursor %q has completed or expired", cursor)
+	case l.request != request:
+		return nil, status.Errorf(codes.InvalidArgument, "cursor %q belongs to another lookup", cursor)
+	case l.sent != sent:
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"cursor %q is not of the last result sent, the lookup can only be resumed after it", cursor)
+	}
+	delete(p.paused, id)
+	l.expiry.Stop()
+	return l, nil
+}
+
+// page sends up to size results of the lookup as they are received, each with the cursor to resume the lookup after
+// it. The lookup is paused once the page is full, and cancelled if it cannot be sent.
+func (p *pager[T]) page(ctx context.Context, l *lookup[T], size int, send func(result T, cursor string) error) error {
+	for n := 0; n < size; n++ {
+		select {
+		case <-ctx.Done():
+			l.cancel()
+			return toStatus(ctx.Err())
+		case result, ok := <-l.results:
+			if !ok {
+				if l.err != nil {
+					return toStatus(l.err)
+				}
+				return nil
+			}
+			l.sent++
+			if err := send(result, encodeCursor(l.id, l.sent)); err != nil {
+				l.cancel()
+				return err
+			}
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused[l.id] = l
+	l.expiry = time.AfterFunc(lookupTTL, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		// The lookup may have been resumed while the timer fired
+		if p.paused[l.id] == l {
+			delete(p.paused, l.id)
+			l.cancel()
+		}
+	})
+	return nil
+}
+
+// pageSize returns the number of results of a page of the requested size.
+func pageSize(size int32) (int, error) {
+	switch {
+	case size < 0:
+		return 0, status.Errorf(codes.InvalidArgument, "negative page size %d", size)
+	case size == 0:
+		return defaultPageSize, nil
+	default:
+		return min(int(size), maxPageSize), nil
+	}
+}
+
+// encodeCursor makes the lookup and the number of results sent before the cursor opaque to clients, which keeps them
+// from depending on either.
+func encodeCursor(id string, sent uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s.%d", id, sent)))
+}
+
+func decodeCursor(cursor string) (string, uint64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, status.Errorf(codes.InvalidArgument, "malformed cursor %q", cursor)
+	}
+	id, n, _ := strings.Cut(string(decoded), ".")
+	sent, err := strconv.ParseUint(n, 10, 64)
+	if err != nil {
+		return "", 0, status.Errorf(codes.InvalidArgument, "malformed cursor %q", cursor)
+	}
+	return id, sent, nil
+}